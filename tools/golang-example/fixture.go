@@ -0,0 +1,37 @@
+package main
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/rpc"
+    "hedera-golang-example-project/fixture"
+)
+
+// fixtureTransport returns the transport for the clients: a recorder with
+// recordPath, a replayer with replayPath and the default transport
+// otherwise.
+func fixtureTransport(recordPath, replayPath string) (http.RoundTripper, *fixture.Recorder, error) {
+    switch {
+    case recordPath != "":
+        recorder := fixture.NewRecorder(http.DefaultTransport)
+        return recorder, recorder, nil
+    case replayPath != "":
+        replayer, err := fixture.Load(replayPath)
+        if err != nil {
+            return nil, nil, err
+        }
+        return replayer, nil, nil
+    }
+    return http.DefaultTransport, nil, nil
+}
+
+// dial connects to the endpoint over the given transport.
+func dial(endpointUrl string, transport http.RoundTripper) (*ethclient.Client, error) {
+    rpcClient, err := rpc.DialOptions(context.Background(), endpointUrl, rpc.WithHTTPClient(&http.Client{Transport: transport}))
+    if err != nil {
+        return nil, err
+    }
+    return ethclient.NewClient(rpcClient), nil
+}
@@ -7,6 +7,7 @@ import (
     "fmt"
     "log"
     "math/big"
+    "net/http"
     "os"
     "strings"
 
@@ -29,13 +30,16 @@ const (
 )
 
 func main() {
+    // The operator key may come from the environment instead.
     err := godotenv.Load()
-    if err != nil {
+    if err != nil && os.Getenv("OPERATOR_PRIVATE_KEY") == "" {
         log.Fatalf("Error loading .env file")
     }
 
     mainnet := flag.Bool("mainnet", false, "Use mainnet network")
     previewnet := flag.Bool("previewnet", false, "Use previewnet network")
+    recordPath := flag.String("record", "", "Record every JSON-RPC call and response to the given fixture file")
+    replayPath := flag.String("replay", "", "Answer JSON-RPC calls from the given fixture file instead of the network")
     privateKeyHex := os.Getenv("OPERATOR_PRIVATE_KEY")
 
     flag.Parse()
@@ -55,7 +59,11 @@ func main() {
         chainId = testnetChainId
     }
 
-    client, auth, fromAddress := initialise(endpointUrl, chainId, privateKeyHex)
+    transport, recorder, err := fixtureTransport(*recordPath, *replayPath)
+    if err != nil {
+        log.Fatal(err)
+    }
+    client, auth, fromAddress := initialise(endpointUrl, chainId, privateKeyHex, transport)
     fmt.Printf("Using address: %s\n", fromAddress.Hex())
 
     balance, err := client.BalanceAt(context.Background(), fromAddress, nil)
@@ -72,10 +80,16 @@ func main() {
 
     result := greet(instance)
     fmt.Printf("Greet method returned: %s\n", result)
+
+    if recorder != nil {
+        if err := recorder.Save(*recordPath); err != nil {
+            log.Fatalf("Failed to save fixture: %v", err)
+        }
+    }
 }
 
-func initialise(endpointUrl string, chainId int, privateKeyHex string) (*ethclient.Client, *bind.TransactOpts, common.Address) {
-    client, err := ethclient.Dial(endpointUrl)
+func initialise(endpointUrl string, chainId int, privateKeyHex string, transport http.RoundTripper) (*ethclient.Client, *bind.TransactOpts, common.Address) {
+    client, err := dial(endpointUrl, transport)
     if err != nil {
         log.Fatal(err)
     }
@@ -3,7 +3,9 @@ package main
 import (
     "context"
     "crypto/ecdsa"
+    "flag"
     "math/big"
+    "net/http"
     "os"
     "strings"
     "testing"
@@ -18,24 +20,56 @@ import (
     "github.com/stretchr/testify/require"
 
     greeter "hedera-golang-example-project/contracts"
+    "hedera-golang-example-project/fixture"
 )
 
 var (
     endpointUrl string
     chainId     int
+
+    endpointFlag = flag.String("endpoint", "", "JSON-RPC endpoint to test against instead of testnet, e.g. a mock relay")
+    chainIdFlag  = flag.Int("chainid", 0, "chain ID of the endpoint given with -endpoint")
+    recordFlag   = flag.String("record", "", "record every JSON-RPC call and response to the given fixture file")
+    replayFlag   = flag.String("replay", "", "answer JSON-RPC calls from the given fixture file instead of the network")
+
+    transport http.RoundTripper
 )
 
 func init() {
-    err := godotenv.Load()
-    if err != nil {
-        log.Fatalf("Error loading .env file")
-    }
     endpointUrl = testnetEndpoint
     chainId = testnetChainId
 }
 
+func TestMain(m *testing.M) {
+    flag.Parse()
+    // The operator key may come from the environment instead, as when a
+    // fixture is replayed in CI.
+    if err := godotenv.Load(); err != nil && os.Getenv("OPERATOR_PRIVATE_KEY") == "" {
+        log.Fatalf("Error loading .env file")
+    }
+    if *endpointFlag != "" {
+        endpointUrl = *endpointFlag
+    }
+    if *chainIdFlag != 0 {
+        chainId = *chainIdFlag
+    }
+    var recorder *fixture.Recorder
+    var err error
+    transport, recorder, err = fixtureTransport(*recordFlag, *replayFlag)
+    if err != nil {
+        log.Fatal(err)
+    }
+    code := m.Run()
+    if recorder != nil {
+        if err := recorder.Save(*recordFlag); err != nil {
+            log.Fatalf("Failed to save fixture: %v", err)
+        }
+    }
+    os.Exit(code)
+}
+
 func setup(t *testing.T) (*ethclient.Client, *ecdsa.PrivateKey, *bind.TransactOpts, common.Address) {
-    client, err := ethclient.Dial(endpointUrl)
+    client, err := dial(endpointUrl, transport)
     require.NoError(t, err)
 
     privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(os.Getenv("OPERATOR_PRIVATE_KEY"), "0x"))
@@ -0,0 +1,294 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+    // relayBatchMaxSize is the relay's default BATCH_REQUESTS_MAX_SIZE.
+    relayBatchMaxSize = 100
+    batchDisabledCode = -32202
+)
+
+// batchDisallowedMethods is the relay's default
+// BATCH_REQUESTS_DISALLOWED_METHODS.
+var batchDisallowedMethods = []string{
+    "debug_traceTransaction",
+    "eth_newFilter",
+    "eth_uninstallFilter",
+    "eth_getFilterChanges",
+    "eth_getFilterLogs",
+    "eth_newBlockFilter",
+    "eth_newPendingTransactionFilter",
+}
+
+// registerBatchCases adds the batch cases. The relay names batches
+// batch_request in its metrics and logs.
+func registerBatchCases(r *runner, h *harness) {
+    r.add("batch_request (mixed)", func() error {
+        return testBatchMixed(h)
+    })
+    r.add("batch_request (response order)", func() error {
+        return testBatchResponseOrder(h)
+    })
+    r.add("batch_request (empty)", func() error {
+        return testBatchEmpty(h)
+    })
+    r.add("batch_request (over size limit)", func() error {
+        return testBatchOverSizeLimit(h)
+    })
+    r.add("batch_request (disallowed methods)", func() error {
+        return testBatchDisallowedMethods(h)
+    })
+}
+
+// batchCall sends a batch. A case is skipped when the relay has batches
+// disabled.
+func batchCall(h *harness, batch []rpc.BatchElem) error {
+    err := h.client.Client().BatchCallContext(context.Background(), batch)
+    if rpcErr, ok := asRpcError(err); ok && rpcErr.Code == batchDisabledCode {
+        return skipf("batch requests are disabled on the relay: %v", err)
+    }
+    return err
+}
+
+// testBatchMixed checks that every call of a batch is answered on its own:
+// the valid calls with their results and the invalid ones with their own
+// errors.
+func testBatchMixed(h *harness) error {
+    var chainId hexutil.Big
+    var balance hexutil.Big
+    var unknown, invalid json.RawMessage
+    batch := []rpc.BatchElem{
+        {Method: "eth_chainId", Result: &chainId},
+        {Method: "eth_unknownMethod", Result: &unknown},
+        {Method: "eth_getBalance", Args: []interface{}{h.fromAddress, "latest"}, Result: &balance},
+        {Method: "eth_getBalance", Args: []interface{}{"0xinvalid", "latest"}, Result: &invalid},
+    }
+    if err := batchCall(h, batch); err != nil {
+        return fmt.Errorf("Failed to send batch: %v", err)
+    }
+
+    for _, i := range []int{0, 2} {
+        if batch[i].Error != nil {
+            return fmt.Errorf("Batched %s failed: %v", batch[i].Method, batch[i].Error)
+        }
+    }
+    if chainId.ToInt().Cmp(h.chainId) != 0 {
+        return fmt.Errorf("Batched eth_chainId returned %s, expected %s", chainId.String(), hexutil.EncodeBig(h.chainId))
+    }
+    single, err := h.client.BalanceAt(context.Background(), h.fromAddress, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    if balance.ToInt().Cmp(single) != 0 {
+        return fmt.Errorf("Batched eth_getBalance returned %s, expected %s", balance.ToInt().String(), single.String())
+    }
+
+    if err := expectRpcError(batch[1].Error, -32601, "Method eth_unknownMethod not found"); err != nil {
+        return err
+    }
+    if rpcErr, ok := asRpcError(batch[3].Error); !ok || rpcErr.Code != -32602 {
+        return fmt.Errorf("Expected batched eth_getBalance with an invalid address to fail with -32602, got %v", batch[3].Error)
+    }
+    fmt.Printf("Mixed batch answered with errors %q and %q for the invalid calls\n", batch[1].Error, batch[3].Error)
+    return nil
+}
+
+// batchResponseIds are the IDs of the raw batch of testBatchResponseOrder:
+// out of sequence and of mixed types, as JSON-RPC allows.
+var batchResponseIds = []json.RawMessage{
+    json.RawMessage(`"block-b"`),
+    json.RawMessage(`7`),
+    json.RawMessage(`"0x1"`),
+    json.RawMessage(`2`),
+    json.RawMessage(`"block-a"`),
+}
+
+// testBatchResponseOrder sends a raw batch of block queries and matches each
+// response to its request by ID alone, whatever order the responses come
+// back in.
+func testBatchResponseOrder(h *harness) error {
+    latest, err := h.client.BlockNumber(context.Background())
+    if err != nil {
+        return fmt.Errorf("Failed to get block number: %v", err)
+    }
+    expected := map[string]uint64{}
+    requests := make([]map[string]interface{}, 0, len(batchResponseIds))
+    for i, id := range batchResponseIds {
+        number := uint64(0)
+        if latest >= uint64(i) {
+            number = latest - uint64(i)
+        }
+        expected[string(id)] = number
+        requests = append(requests, map[string]interface{}{
+            "jsonrpc": "2.0",
+            "id":      id,
+            "method":  "eth_getBlockByNumber",
+            "params":  []interface{}{hexutil.EncodeUint64(number), false},
+        })
+    }
+    body, err := json.Marshal(requests)
+    if err != nil {
+        return fmt.Errorf("Failed to encode batch: %v", err)
+    }
+    status, respBody, err := postRaw(h, body)
+    if err != nil {
+        return fmt.Errorf("Failed to send batch: %v", err)
+    }
+    var responses []struct {
+        Id     json.RawMessage `json:"id"`
+        Result *struct {
+            Number *hexutil.Big `json:"number"`
+        } `json:"result"`
+        Error *rpcError `json:"error"`
+    }
+    if err := json.Unmarshal(respBody, &responses); err != nil {
+        if rpcErr, ok := decodeRpcError(respBody); ok && rpcErr.Code == batchDisabledCode {
+            return skipf("batch requests are disabled on the relay: %s", rpcErr.Message)
+        }
+        return fmt.Errorf("Expected an array of responses with HTTP status %d, got %s", status, string(respBody))
+    }
+    if len(responses) != len(requests) {
+        return fmt.Errorf("Batch of %d requests was answered with %d responses", len(requests), len(responses))
+    }
+    inOrder := true
+    for i, response := range responses {
+        id := string(response.Id)
+        number, ok := expected[id]
+        if !ok {
+            return fmt.Errorf("Batch response has ID %s, which no request has or which was answered twice", id)
+        }
+        delete(expected, id)
+        if response.Error != nil {
+            return fmt.Errorf("Batched eth_getBlockByNumber with ID %s failed: %d %s", id, response.Error.Code, response.Error.Message)
+        }
+        if response.Result == nil || response.Result.Number == nil || response.Result.Number.ToInt().Uint64() != number {
+            return fmt.Errorf("Batch response with ID %s is not block %d, which its request asked for", id, number)
+        }
+        inOrder = inOrder && id == string(batchResponseIds[i])
+    }
+    fmt.Printf("Batch of %d blocks with mixed IDs answered by ID, in request order: %t\n", len(responses), inOrder)
+    return nil
+}
+
+// testBatchEmpty checks that the relay answers an empty batch with an empty
+// array and HTTP 200, as it answers every batch it accepts.
+func testBatchEmpty(h *harness) error {
+    status, respBody, err := postRaw(h, []byte("[]"))
+    if err != nil {
+        return fmt.Errorf("Failed to send empty batch: %v", err)
+    }
+    if rpcErr, ok := decodeRpcError(respBody); ok && rpcErr.Code == batchDisabledCode {
+        return skipf("batch requests are disabled on the relay: %s", rpcErr.Message)
+    }
+    if status != http.StatusOK || string(bytes.TrimSpace(respBody)) != "[]" {
+        return fmt.Errorf("Expected an empty batch to be answered with [] and HTTP status %d, got %d %s", http.StatusOK, status, string(respBody))
+    }
+    fmt.Println("Empty batch answered with []")
+    return nil
+}
+
+// postRaw posts a raw JSON-RPC body to the relay and returns the HTTP status
+// and body of the answer.
+func postRaw(h *harness, body []byte) (int, []byte, error) {
+    req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.endpoint, bytes.NewReader(body))
+    if err != nil {
+        return 0, nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    client := &http.Client{Transport: h.transport}
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, nil, err
+    }
+    defer resp.Body.Close()
+    respBody, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return 0, nil, err
+    }
+    return resp.StatusCode, respBody, nil
+}
+
+// decodeRpcError decodes a response that is a single error, such as the
+// relay's answer to a whole batch it rejects.
+func decodeRpcError(body []byte) (*rpcError, bool) {
+    var response struct {
+        Error *rpcError `json:"error"`
+    }
+    if json.Unmarshal(body, &response) != nil || response.Error == nil {
+        return nil, false
+    }
+    return response.Error, true
+}
+
+// testBatchOverSizeLimit checks that a batch over the limit is rejected as
+// a whole.
+func testBatchOverSizeLimit(h *harness) error {
+    size := relayBatchMaxSize + 1
+    batch := make([]rpc.BatchElem, size)
+    for i := range batch {
+        batch[i] = rpc.BatchElem{Method: "eth_chainId", Result: new(hexutil.Big)}
+    }
+    err := batchCall(h, batch)
+    if err == nil {
+        return fmt.Errorf("Expected a batch of %d calls to be rejected", size)
+    }
+    var skip *skipError
+    if errors.As(err, &skip) {
+        return err
+    }
+    return h.expectRelayError(err, -32203, fmt.Sprintf("Batch request amount %d exceeds max %d", size, relayBatchMaxSize))
+}
+
+// testBatchDisallowedMethods checks that methods disallowed in batches are
+// rejected one by one, without failing the rest of the batch.
+func testBatchDisallowedMethods(h *harness) error {
+    batch := []rpc.BatchElem{{Method: "eth_chainId", Result: new(hexutil.Big)}}
+    for _, method := range batchDisallowedMethods {
+        batch = append(batch, rpc.BatchElem{Method: method, Result: new(json.RawMessage)})
+    }
+    if err := batchCall(h, batch); err != nil {
+        return fmt.Errorf("Failed to send batch: %v", err)
+    }
+    if batch[0].Error != nil {
+        return fmt.Errorf("Batched eth_chainId failed: %v", batch[0].Error)
+    }
+    for _, elem := range batch[1:] {
+        if err := expectRpcError(elem.Error, -32007, fmt.Sprintf("Method %s is not permitted as part of batch requests", elem.Method)); err != nil {
+            return err
+        }
+    }
+    fmt.Printf("%d disallowed methods rejected in batch\n", len(batchDisallowedMethods))
+    return nil
+}
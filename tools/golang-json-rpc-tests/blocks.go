@@ -0,0 +1,242 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "reflect"
+    "sort"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// rpcBlockLinks holds the fields of a block that tie it to its transactions
+// and its parent.
+type rpcBlockLinks struct {
+    Number       *hexutil.Big  `json:"number"`
+    Hash         common.Hash   `json:"hash"`
+    ParentHash   common.Hash   `json:"parentHash"`
+    Transactions []common.Hash `json:"transactions"`
+}
+
+// rpcTransactionPosition holds the fields of a transaction that place it in
+// a block.
+type rpcTransactionPosition struct {
+    Hash             common.Hash     `json:"hash"`
+    BlockHash        *common.Hash    `json:"blockHash"`
+    BlockNumber      *hexutil.Big    `json:"blockNumber"`
+    TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// blockRange is an inclusive range of block numbers.
+type blockRange struct {
+    from *big.Int
+    to   *big.Int
+}
+
+// parseBlockRange parses a range written as from-to, with decimal or
+// 0x-prefixed numbers.
+func parseBlockRange(value string) (*blockRange, error) {
+    if value == "" {
+        return nil, nil
+    }
+    bounds := strings.SplitN(value, "-", 2)
+    if len(bounds) != 2 {
+        return nil, fmt.Errorf("Invalid block range %q, expected from-to", value)
+    }
+    numbers, err := parseBlockNumbers(bounds[0] + "," + bounds[1])
+    if err != nil {
+        return nil, err
+    }
+    if numbers[0].Cmp(numbers[1]) > 0 {
+        return nil, fmt.Errorf("Invalid block range %q, from is after to", value)
+    }
+    return &blockRange{from: numbers[0], to: numbers[1]}, nil
+}
+
+// registerBlockCases walks the blocks of the write cases, from the parent of
+// the transfer block to the contract block, and the given range.
+func registerBlockCases(r *runner, h *harness, extra *blockRange) {
+    r.add("eth_getBlockByNumber (consistency, write blocks)", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        if h.contractReceipt == nil {
+            return skipf("contract was not deployed")
+        }
+        from := new(big.Int).Set(h.transferReceipt.BlockNumber)
+        if from.Sign() > 0 {
+            from.Sub(from, big.NewInt(1))
+        }
+        to := h.contractReceipt.BlockNumber
+        if to.Cmp(from) < 0 {
+            from, to = to, from
+        }
+        return checkBlockRange(h, blockRange{from: from, to: to})
+    })
+    if extra != nil {
+        r.add(fmt.Sprintf("eth_getBlockByNumber (consistency, blocks %s-%s)", extra.from.String(), extra.to.String()), func() error {
+            return checkBlockRange(h, *extra)
+        })
+    }
+}
+
+// checkBlockRange checks every block of the range on its own and that each
+// one's parentHash is the hash of the block before it. Every mismatch is
+// reported, one per line.
+func checkBlockRange(h *harness, blocks blockRange) error {
+    var diffs []string
+    var previous *rpcBlockLinks
+    for number := new(big.Int).Set(blocks.from); number.Cmp(blocks.to) <= 0; number.Add(number, big.NewInt(1)) {
+        block, blockDiffs, err := checkBlock(h, number)
+        if err != nil {
+            return err
+        }
+        for _, diff := range blockDiffs {
+            diffs = append(diffs, fmt.Sprintf("block %s: %s", number.String(), diff))
+        }
+        if previous != nil && block.ParentHash != previous.Hash {
+            diffs = append(diffs, fmt.Sprintf("block %s: parentHash is %s, expected hash %s of block %s", number.String(), block.ParentHash.Hex(), previous.Hash.Hex(), previous.Number.String()))
+        }
+        previous = block
+    }
+    if len(diffs) > 0 {
+        return fmt.Errorf("Blocks %s to %s are inconsistent:\n    %s", blocks.from.String(), blocks.to.String(), strings.Join(diffs, "\n    "))
+    }
+    fmt.Printf("Blocks %s to %s are consistent\n", blocks.from.String(), blocks.to.String())
+    return nil
+}
+
+// checkBlock checks eth_getBlockByNumber and eth_getBlockByHash return the
+// same block, that both transaction count methods match its transactions
+// and that each transaction fetched by index points back to the block.
+func checkBlock(h *harness, number *big.Int) (*rpcBlockLinks, []string, error) {
+    ctx := context.Background()
+    rpcClient := h.client.Client()
+    blockNumber := hexutil.EncodeBig(number)
+
+    var byNumber json.RawMessage
+    if err := rpcClient.CallContext(ctx, &byNumber, "eth_getBlockByNumber", blockNumber, false); err != nil {
+        return nil, nil, fmt.Errorf("Failed to get block by number: %v", err)
+    }
+    var block *rpcBlockLinks
+    if err := json.Unmarshal(byNumber, &block); err != nil {
+        return nil, nil, fmt.Errorf("Failed to decode block %s: %v", number.String(), err)
+    }
+    if block == nil {
+        return nil, nil, fmt.Errorf("Block %s not found", number.String())
+    }
+    var byHash json.RawMessage
+    if err := rpcClient.CallContext(ctx, &byHash, "eth_getBlockByHash", block.Hash, false); err != nil {
+        return nil, nil, fmt.Errorf("Failed to get block by hash: %v", err)
+    }
+    diffs, err := blockFieldDiffs(byNumber, byHash)
+    if err != nil {
+        return nil, nil, err
+    }
+    if block.Number == nil || block.Number.ToInt().Cmp(number) != 0 {
+        diffs = append(diffs, fmt.Sprintf("number is %v", block.Number))
+    }
+
+    for _, count := range []struct {
+        method string
+        param  interface{}
+    }{
+        {"eth_getBlockTransactionCountByNumber", blockNumber},
+        {"eth_getBlockTransactionCountByHash", block.Hash},
+    } {
+        var txCount hexutil.Uint64
+        if err := rpcClient.CallContext(ctx, &txCount, count.method, count.param); err != nil {
+            return nil, nil, fmt.Errorf("Failed to call %s: %v", count.method, err)
+        }
+        if int(txCount) != len(block.Transactions) {
+            diffs = append(diffs, fmt.Sprintf("%s is %d, block has %d transactions", count.method, txCount, len(block.Transactions)))
+        }
+    }
+
+    for i, txHash := range block.Transactions {
+        index := hexutil.EncodeUint64(uint64(i))
+        for _, lookup := range []struct {
+            method string
+            param  interface{}
+        }{
+            {"eth_getTransactionByBlockNumberAndIndex", blockNumber},
+            {"eth_getTransactionByBlockHashAndIndex", block.Hash},
+        } {
+            var tx *rpcTransactionPosition
+            if err := rpcClient.CallContext(ctx, &tx, lookup.method, lookup.param, index); err != nil {
+                return nil, nil, fmt.Errorf("Failed to call %s: %v", lookup.method, err)
+            }
+            if tx == nil {
+                diffs = append(diffs, fmt.Sprintf("%s returned null for index %d", lookup.method, i))
+                continue
+            }
+            if tx.Hash != txHash {
+                diffs = append(diffs, fmt.Sprintf("%s index %d is %s, expected %s", lookup.method, i, tx.Hash.Hex(), txHash.Hex()))
+            }
+            if tx.BlockHash == nil || *tx.BlockHash != block.Hash {
+                diffs = append(diffs, fmt.Sprintf("%s index %d has blockHash %v, expected %s", lookup.method, i, tx.BlockHash, block.Hash.Hex()))
+            }
+            if tx.BlockNumber == nil || tx.BlockNumber.ToInt().Cmp(number) != 0 {
+                diffs = append(diffs, fmt.Sprintf("%s index %d has blockNumber %v, expected %s", lookup.method, i, tx.BlockNumber, blockNumber))
+            }
+            if tx.TransactionIndex == nil || int(*tx.TransactionIndex) != i {
+                diffs = append(diffs, fmt.Sprintf("%s index %d has transactionIndex %v", lookup.method, i, tx.TransactionIndex))
+            }
+        }
+    }
+    return block, diffs, nil
+}
+
+// blockFieldDiffs lists the fields two encodings of a block disagree on.
+func blockFieldDiffs(byNumber, byHash json.RawMessage) ([]string, error) {
+    var numberFields, hashFields map[string]interface{}
+    if err := json.Unmarshal(byNumber, &numberFields); err != nil {
+        return nil, fmt.Errorf("Failed to decode block by number: %v", err)
+    }
+    if err := json.Unmarshal(byHash, &hashFields); err != nil {
+        return nil, fmt.Errorf("Failed to decode block by hash: %v", err)
+    }
+    if hashFields == nil {
+        return []string{"eth_getBlockByHash returned null"}, nil
+    }
+    names := map[string]bool{}
+    for name := range numberFields {
+        names[name] = true
+    }
+    for name := range hashFields {
+        names[name] = true
+    }
+    var diffs []string
+    for name := range names {
+        if !reflect.DeepEqual(numberFields[name], hashFields[name]) {
+            diffs = append(diffs, fmt.Sprintf("%s is %v by number, %v by hash", name, numberFields[name], hashFields[name]))
+        }
+    }
+    sort.Strings(diffs)
+    return diffs, nil
+}
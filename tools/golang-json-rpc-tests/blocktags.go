@@ -0,0 +1,393 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// greetings are the greeting the Greeter is deployed with, then the ones
+// set one after another. Each fits in a single storage slot.
+var greetings = []string{"Hello", "Hola", "Bonjour"}
+
+// latestTags name the latest block on Hedera, which has no pending
+// transactions and reaches finality as soon as a block is written.
+var latestTags = []string{"latest", "pending", "safe", "finalized"}
+
+// greeterState is the state of the Greeter and the operator account at a
+// block the block tag cases recorded.
+type greeterState struct {
+    name     string
+    number   uint64
+    hash     common.Hash
+    deployed bool
+    greeting string
+    balance  *big.Int
+}
+
+// blockParam is a way of naming a recorded block. object is set for the
+// EIP-1898 objects, and unknownKey names a key the relay does not accept in
+// them.
+type blockParam struct {
+    name       string
+    param      func(state greeterState) interface{}
+    object     bool
+    unknownKey string
+}
+
+// blockParams name a block by number, and by the EIP-1898 block number and
+// block hash objects.
+var blockParams = []blockParam{
+    {name: "number", param: func(state greeterState) interface{} {
+        return hexutil.Uint64(state.number)
+    }},
+    {name: "blockNumber", object: true, param: func(state greeterState) interface{} {
+        return map[string]interface{}{"blockNumber": hexutil.Uint64(state.number)}
+    }},
+    {name: "blockHash", object: true, param: func(state greeterState) interface{} {
+        return map[string]interface{}{"blockHash": state.hash}
+    }},
+    {name: "blockHash, requireCanonical false", object: true, unknownKey: "requireCanonical", param: func(state greeterState) interface{} {
+        return map[string]interface{}{"blockHash": state.hash, "requireCanonical": false}
+    }},
+    {name: "blockHash, requireCanonical true", object: true, unknownKey: "requireCanonical", param: func(state greeterState) interface{} {
+        return map[string]interface{}{"blockHash": state.hash, "requireCanonical": true}
+    }},
+}
+
+// rejection is the message of the -32602 error the relay's validator
+// answers query at the block parameter with, or "" when it accepts it.
+// Unlike geth, the relay takes block objects only for eth_call, and knows
+// no requireCanonical.
+func (param blockParam) rejection(query stateQuery) string {
+    if !param.object {
+        return ""
+    }
+    if !hedera.BlockObjectMethods[query.method] {
+        return fmt.Sprintf("Invalid parameter %d: The value passed is not valid: [object Object]. %s OR %s", query.blockIndex, hedera.BlockNumberError, hedera.BlockHashError)
+    }
+    if param.unknownKey != "" {
+        return fmt.Sprintf("Invalid parameter '%s' for BlockHashObject: Unknown parameter", param.unknownKey)
+    }
+    return ""
+}
+
+// stateQuery reads part of the recorded state at a block parameter, and
+// gives the value it must have in a state. Values are compared as strings;
+// expected returns false when any value is acceptable. blockIndex is the
+// index of the block parameter, and latestNearHead is
+// set when the relay answers for a block within hedera.LatestBlockTolerance
+// of the head with the latest value instead.
+type stateQuery struct {
+    method         string
+    blockIndex     int
+    read           func(s *blockTagScenario, block interface{}) (string, error)
+    expected       func(s *blockTagScenario, state greeterState) (string, bool)
+    latestNearHead bool
+}
+
+var stateQueries = []stateQuery{
+    {
+        method:     "eth_getBalance",
+        blockIndex: 1,
+        read: func(s *blockTagScenario, block interface{}) (string, error) {
+            var balance hexutil.Big
+            if err := s.h.client.Client().CallContext(context.Background(), &balance, "eth_getBalance", s.h.fromAddress, block); err != nil {
+                return "", err
+            }
+            return balance.ToInt().String(), nil
+        },
+        expected: func(s *blockTagScenario, state greeterState) (string, bool) {
+            if state.balance == nil {
+                return "", false
+            }
+            return state.balance.String(), true
+        },
+        latestNearHead: true,
+    },
+    {
+        method:     "eth_getCode",
+        blockIndex: 1,
+        read: func(s *blockTagScenario, block interface{}) (string, error) {
+            var code hexutil.Bytes
+            if err := s.h.client.Client().CallContext(context.Background(), &code, "eth_getCode", s.address, block); err != nil {
+                return "", err
+            }
+            return code.String(), nil
+        },
+        expected: func(s *blockTagScenario, state greeterState) (string, bool) {
+            if !state.deployed {
+                return "0x", true
+            }
+            return s.code.String(), true
+        },
+    },
+    {
+        method:     "eth_getStorageAt",
+        blockIndex: 2,
+        read: func(s *blockTagScenario, block interface{}) (string, error) {
+            var value hexutil.Bytes
+            if err := s.h.client.Client().CallContext(context.Background(), &value, "eth_getStorageAt", s.address, common.Hash{}, block); err != nil {
+                return "", err
+            }
+            return common.BytesToHash(value).Hex(), nil
+        },
+        expected: func(s *blockTagScenario, state greeterState) (string, bool) {
+            return shortStringSlot(state.greeting).Hex(), true
+        },
+    },
+    {
+        method:     "eth_call",
+        blockIndex: 1,
+        read: func(s *blockTagScenario, block interface{}) (string, error) {
+            data, err := s.greeter.abi.Pack("greet")
+            if err != nil {
+                return "", fmt.Errorf("Failed to pack greet call: %v", err)
+            }
+            call := map[string]interface{}{"from": s.h.fromAddress, "to": s.address, "data": hexutil.Bytes(data)}
+            var result hexutil.Bytes
+            if err := s.h.client.Client().CallContext(context.Background(), &result, "eth_call", call, block); err != nil {
+                return "", err
+            }
+            if len(result) == 0 {
+                return "", nil
+            }
+            values, err := s.greeter.abi.Unpack("greet", result)
+            if err != nil {
+                return "", fmt.Errorf("Failed to unpack greet result %s: %v", result.String(), err)
+            }
+            return fmt.Sprintf("%q", values[0]), nil
+        },
+        expected: func(s *blockTagScenario, state greeterState) (string, bool) {
+            if !state.deployed {
+                return "", true
+            }
+            return fmt.Sprintf("%q", state.greeting), true
+        },
+    },
+}
+
+// shortStringSlot is the storage slot of a Solidity string shorter than 32
+// bytes: its bytes left-aligned, with twice its length in the last byte.
+func shortStringSlot(value string) common.Hash {
+    var slot common.Hash
+    copy(slot[:], value)
+    slot[common.HashLength-1] = byte(2 * len(value))
+    return slot
+}
+
+// blockTagScenario records the Greeter's history, from the block before
+// its deployment to the block of each setGreeting, and reads it back with
+// every block tag and block parameter.
+type blockTagScenario struct {
+    h       *harness
+    greeter *contract
+    address common.Address
+    code    hexutil.Bytes
+    states  []greeterState
+}
+
+func registerBlockTagCases(r *runner, h *harness) {
+    s := &blockTagScenario{h: h}
+    r.add("eth_sendRawTransaction (Greeter history)", s.record)
+    for _, query := range stateQueries {
+        query := query
+        r.add(fmt.Sprintf("%s (earliest)", query.method), func() error {
+            if len(s.states) == 0 {
+                return skipf("Greeter history was not recorded")
+            }
+            return s.check(query, "earliest", "earliest", greeterState{name: "genesis"})
+        })
+        for _, tag := range latestTags {
+            tag := tag
+            r.add(fmt.Sprintf("%s (%s)", query.method, tag), func() error {
+                if len(s.states) == 0 {
+                    return skipf("Greeter history was not recorded")
+                }
+                return s.check(query, tag, tag, s.states[len(s.states)-1])
+            })
+        }
+        for _, param := range blockParams {
+            param := param
+            r.add(fmt.Sprintf("%s (%s)", query.method, param.name), func() error {
+                if len(s.states) == 0 {
+                    return skipf("Greeter history was not recorded")
+                }
+                if message := param.rejection(query); message != "" {
+                    return s.checkRejected(query, param, message)
+                }
+                for _, state := range s.states {
+                    if err := s.check(query, fmt.Sprintf("%s of block %d", param.name, state.number), param.param(state), state); err != nil {
+                        return err
+                    }
+                }
+                return nil
+            })
+        }
+    }
+}
+
+// record deploys the Greeter and sets each greeting, noting the latest
+// block and the operator balance before the deployment and after every
+// transaction.
+func (s *blockTagScenario) record() error {
+    greeter, err := loadContract("Greeter")
+    if err != nil {
+        return err
+    }
+    s.greeter = greeter
+
+    before, err := s.latest("before deployment", false, "")
+    if err != nil {
+        return err
+    }
+    address, receipt, err := greeter.deploy(s.h, greetings[0])
+    if err != nil {
+        return err
+    }
+    s.address = address
+    if receipt.BlockNumber.Uint64() <= before.number {
+        return fmt.Errorf("Deployment block %s is not after block %d", receipt.BlockNumber.String(), before.number)
+    }
+    code, err := s.h.client.CodeAt(context.Background(), address, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get code at address: %v", err)
+    }
+    if len(code) == 0 {
+        return fmt.Errorf("Greeter at %s has no code", address.Hex())
+    }
+    s.code = code
+    deployed, err := s.mined(before, "after deployment", receipt.BlockNumber, receipt.BlockHash, greetings[0])
+    if err != nil {
+        return err
+    }
+    states := []greeterState{before, deployed}
+
+    for _, greeting := range greetings[1:] {
+        receipt, err := greeter.transact(s.h, address, "setGreeting", greeting)
+        if err != nil {
+            return err
+        }
+        state, err := s.mined(states[len(states)-1], fmt.Sprintf("after setGreeting(%q)", greeting), receipt.BlockNumber, receipt.BlockHash, greeting)
+        if err != nil {
+            return err
+        }
+        states = append(states, state)
+    }
+    s.states = states
+    for _, state := range states {
+        fmt.Printf("Block %d %s: greeting %q, balance %s\n", state.number, state.name, state.greeting, state.balance.String())
+    }
+    return nil
+}
+
+// latest records the latest block with the operator balance.
+func (s *blockTagScenario) latest(name string, deployed bool, greeting string) (greeterState, error) {
+    ctx := context.Background()
+    var block rpcBlockLinks
+    if err := s.h.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", "latest", false); err != nil {
+        return greeterState{}, fmt.Errorf("Failed to get latest block: %v", err)
+    }
+    if block.Number == nil {
+        return greeterState{}, fmt.Errorf("Latest block has no number")
+    }
+    balance, err := s.h.client.BalanceAt(ctx, s.h.fromAddress, nil)
+    if err != nil {
+        return greeterState{}, fmt.Errorf("Failed to get balance: %v", err)
+    }
+    return greeterState{
+        name:     name,
+        number:   block.Number.ToInt().Uint64(),
+        hash:     block.Hash,
+        deployed: deployed,
+        greeting: greeting,
+        balance:  balance,
+    }, nil
+}
+
+// mined records the block a transaction was mined in. The operator balance
+// is read at the latest block, which holds no later transaction of the
+// operator, and must have dropped by the gas the transaction paid.
+func (s *blockTagScenario) mined(previous greeterState, name string, number *big.Int, hash common.Hash, greeting string) (greeterState, error) {
+    state, err := s.latest(name, true, greeting)
+    if err != nil {
+        return greeterState{}, err
+    }
+    state.number = number.Uint64()
+    state.hash = hash
+    if state.balance.Cmp(previous.balance) >= 0 {
+        return greeterState{}, fmt.Errorf("Balance %s is %s, expected less than %s %s", name, state.balance.String(), previous.name, previous.balance.String())
+    }
+    return state, nil
+}
+
+// check reads a query at a block parameter, described by label, and
+// compares it with the state.
+func (s *blockTagScenario) check(query stateQuery, label string, block interface{}, state greeterState) error {
+    actual, err := query.read(s, block)
+    if err != nil {
+        return fmt.Errorf("Failed to call %s at %s %s: %v", query.method, label, state.name, err)
+    }
+    expected, ok := query.expected(s, state)
+    if ok && actual != expected && query.latestNearHead {
+        if _, tag := block.(string); !tag {
+            // The head is read after the call, so a block the relay took
+            // as latest is still near it.
+            head, err := s.h.client.BlockNumber(context.Background())
+            if err != nil {
+                return fmt.Errorf("Failed to get block number: %v", err)
+            }
+            if state.number+hedera.LatestBlockTolerance >= head {
+                latest, _ := query.expected(s, s.states[len(s.states)-1])
+                if actual == latest {
+                    fmt.Printf("%s at %s %s is the latest value, as block %d is within the relay's tolerance of head %d\n", query.method, label, state.name, state.number, head)
+                    return nil
+                }
+            }
+        }
+    }
+    if ok && actual != expected {
+        return fmt.Errorf("%s at %s %s is %s, expected %s", query.method, label, state.name, actual, expected)
+    }
+    if len(actual) > 66 {
+        actual = fmt.Sprintf("%s... (%d bytes)", actual[:66], (len(actual)-2)/2)
+    }
+    fmt.Printf("%s at %s %s: %s\n", query.method, label, state.name, actual)
+    return nil
+}
+
+// checkRejected calls a query at a block parameter the relay does not take
+// for it, at the last recorded block, and expects its -32602 error.
+func (s *blockTagScenario) checkRejected(query stateQuery, param blockParam, message string) error {
+    state := s.states[len(s.states)-1]
+    _, err := query.read(s, param.param(state))
+    if err := s.h.expectRelayError(err, -32602, message); err != nil {
+        return fmt.Errorf("%s at %s of block %d: %v", query.method, param.name, state.number, err)
+    }
+    fmt.Printf("%s at %s of block %d is rejected: %s\n", query.method, param.name, state.number, message)
+    return nil
+}
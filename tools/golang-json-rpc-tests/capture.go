@@ -0,0 +1,121 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "net/http"
+    "sync"
+)
+
+// rpcCall is a single JSON-RPC request sent while a case was running,
+// together with the raw response the relay returned for it.
+type rpcCall struct {
+    Method   string          `json:"method"`
+    Params   json.RawMessage `json:"params,omitempty"`
+    Response json.RawMessage `json:"response,omitempty"`
+}
+
+type rpcMessage struct {
+    Method string          `json:"method"`
+    Params json.RawMessage `json:"params"`
+}
+
+// rpcCapture is an http.RoundTripper that remembers the JSON-RPC calls made
+// over it until they are taken, so they can be attached to a case result.
+// WebSocket connections do not go through it.
+type rpcCapture struct {
+    transport http.RoundTripper
+
+    mu    sync.Mutex
+    calls []rpcCall
+}
+
+func newRpcCapture() *rpcCapture {
+    return &rpcCapture{transport: http.DefaultTransport}
+}
+
+func (c *rpcCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+    var reqBody []byte
+    if req.Body != nil {
+        body, err := io.ReadAll(req.Body)
+        req.Body.Close()
+        if err != nil {
+            return nil, err
+        }
+        reqBody = body
+        req.Body = io.NopCloser(bytes.NewReader(body))
+    }
+
+    resp, err := c.transport.RoundTrip(req)
+    if err != nil {
+        c.record(reqBody, nil)
+        return nil, err
+    }
+    respBody, err := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if err != nil {
+        return nil, err
+    }
+    resp.Body = io.NopCloser(bytes.NewReader(respBody))
+    c.record(reqBody, respBody)
+    return resp, nil
+}
+
+func (c *rpcCapture) record(reqBody, respBody []byte) {
+    var messages []rpcMessage
+    if err := json.Unmarshal(reqBody, &messages); err != nil {
+        var message rpcMessage
+        if err := json.Unmarshal(reqBody, &message); err != nil {
+            return
+        }
+        messages = []rpcMessage{message}
+    }
+
+    var response json.RawMessage
+    if json.Valid(respBody) {
+        response = respBody
+    } else if len(respBody) > 0 {
+        response, _ = json.Marshal(string(respBody))
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for _, message := range messages {
+        c.calls = append(c.calls, rpcCall{
+            Method:   message.Method,
+            Params:   message.Params,
+            Response: response,
+        })
+    }
+}
+
+// take returns the calls recorded since the previous take and resets the
+// capture.
+func (c *rpcCapture) take() []rpcCall {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    calls := c.calls
+    c.calls = nil
+    return calls
+}
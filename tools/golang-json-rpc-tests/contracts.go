@@ -0,0 +1,136 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "encoding/hex"
+    "fmt"
+    "math/big"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/accounts/abi"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+    contractDeployGas = 1_000_000
+    contractCallGas   = 200_000
+)
+
+// contract is a compiled contract from the contracts folder, stored as
+// <name>.abi and <name>.bin next to its source.
+type contract struct {
+    abi      abi.ABI
+    bytecode []byte
+}
+
+func loadContract(name string) (*contract, error) {
+    abiFile, err := os.ReadFile(filepath.Join(artifacts.Dir, name+".abi"))
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read %s ABI: %v", name, err)
+    }
+    parsed, err := abi.JSON(strings.NewReader(string(abiFile)))
+    if err != nil {
+        return nil, fmt.Errorf("Failed to parse %s ABI: %v", name, err)
+    }
+    binFile, err := os.ReadFile(filepath.Join(artifacts.Dir, name+".bin"))
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read %s bytecode: %v", name, err)
+    }
+    bytecode, err := hex.DecodeString(strings.TrimSpace(string(binFile)))
+    if err != nil {
+        return nil, fmt.Errorf("Failed to decode %s bytecode: %v", name, err)
+    }
+    return &contract{abi: parsed, bytecode: bytecode}, nil
+}
+
+// deploy deploys the contract from the operator account, with the
+// constructor arguments appended to its bytecode, and waits for it to be
+// mined.
+func (c *contract) deploy(h *harness, args ...interface{}) (common.Address, *types.Receipt, error) {
+    packed, err := c.abi.Pack("", args...)
+    if err != nil {
+        return common.Address{}, nil, fmt.Errorf("Failed to pack constructor arguments: %v", err)
+    }
+    nonce, err := h.client.PendingNonceAt(context.Background(), h.fromAddress)
+    if err != nil {
+        return common.Address{}, nil, fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    receipt, err := h.sendTransaction(nonce, nil, append(append([]byte{}, c.bytecode...), packed...), contractDeployGas)
+    if err != nil {
+        return common.Address{}, nil, err
+    }
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return common.Address{}, nil, fmt.Errorf("Contract deployment %s failed", receipt.TxHash.Hex())
+    }
+    return crypto.CreateAddress(h.fromAddress, nonce), receipt, nil
+}
+
+// transact calls a contract method in a transaction and waits for it to be
+// mined.
+func (c *contract) transact(h *harness, address common.Address, method string, args ...interface{}) (*types.Receipt, error) {
+    data, err := c.abi.Pack(method, args...)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to pack %s call: %v", method, err)
+    }
+    nonce, err := h.client.PendingNonceAt(context.Background(), h.fromAddress)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    receipt, err := h.sendTransaction(nonce, &address, data, contractCallGas)
+    if err != nil {
+        return nil, err
+    }
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return nil, fmt.Errorf("Call to %s in %s failed", method, receipt.TxHash.Hex())
+    }
+    return receipt, nil
+}
+
+// sendTransaction signs and sends an EIP-2930 transaction from the operator
+// account and waits for its receipt.
+func (h *harness) sendTransaction(nonce uint64, to *common.Address, data []byte, gas uint64) (*types.Receipt, error) {
+    gasPrice, err := h.client.SuggestGasPrice(context.Background())
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    signedTx, err := types.SignNewTx(h.privateKey, types.NewEIP2930Signer(h.chainId), &types.AccessListTx{
+        ChainID:  h.chainId,
+        Nonce:    nonce,
+        GasPrice: gasPrice,
+        Gas:      gas,
+        To:       to,
+        Value:    big.NewInt(0),
+        Data:     data,
+    })
+    if err != nil {
+        return nil, fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    if err := h.client.SendTransaction(context.Background(), signedTx); err != nil {
+        return nil, fmt.Errorf("Failed to send transaction: %v", err)
+    }
+    return waitForTransaction(h.client, signedTx)
+}
@@ -0,0 +1,365 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "reflect"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/ethclient"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const (
+    callTracer   = "callTracer"
+    opcodeLogger = "opcodeLogger"
+)
+
+// traceOptions is the second debug_traceTransaction param, naming the tracer
+// and its config.
+type traceOptions struct {
+    Tracer       string      `json:"tracer"`
+    TracerConfig interface{} `json:"tracerConfig,omitempty"`
+}
+
+type callTracerConfig struct {
+    OnlyTopCall bool `json:"onlyTopCall"`
+}
+
+type opcodeLoggerConfig struct {
+    EnableMemory   bool `json:"enableMemory,omitempty"`
+    DisableStack   bool `json:"disableStack,omitempty"`
+    DisableStorage bool `json:"disableStorage,omitempty"`
+}
+
+// callFrame is a call traced by the callTracer. Input and output are kept as
+// strings since the relay may return them empty rather than as 0x.
+type callFrame struct {
+    Type         string          `json:"type"`
+    From         common.Address  `json:"from"`
+    To           *common.Address `json:"to"`
+    Value        *hexutil.Big    `json:"value"`
+    Gas          hexutil.Uint64  `json:"gas"`
+    GasUsed      hexutil.Uint64  `json:"gasUsed"`
+    Input        string          `json:"input"`
+    Output       string          `json:"output"`
+    Error        string          `json:"error"`
+    RevertReason string          `json:"revertReason"`
+    Calls        []callFrame     `json:"calls"`
+}
+
+// opcodeTrace is the result of the opcodeLogger. Disabled stack, memory and
+// storage are null on the relay and omitted by go-ethereum, so both decode to
+// nil.
+type opcodeTrace struct {
+    Gas         uint64      `json:"gas"`
+    Failed      bool        `json:"failed"`
+    ReturnValue string      `json:"returnValue"`
+    StructLogs  []structLog `json:"structLogs"`
+}
+
+type structLog struct {
+    Pc      uint64             `json:"pc"`
+    Op      string             `json:"op"`
+    Gas     uint64             `json:"gas"`
+    GasCost uint64             `json:"gasCost"`
+    Depth   int                `json:"depth"`
+    Stack   *[]string          `json:"stack"`
+    Memory  *[]string          `json:"memory"`
+    Storage *map[string]string `json:"storage"`
+}
+
+func traceCall(client *ethclient.Client, txHash common.Hash, config callTracerConfig) (*callFrame, error) {
+    var frame callFrame
+    if err := traceTransaction(client, txHash, traceOptions{Tracer: callTracer, TracerConfig: config}, &frame); err != nil {
+        return nil, err
+    }
+    return &frame, nil
+}
+
+func traceOpcodes(client *ethclient.Client, txHash common.Hash, config opcodeLoggerConfig) (*opcodeTrace, error) {
+    var trace opcodeTrace
+    if err := traceTransaction(client, txHash, traceOptions{Tracer: opcodeLogger, TracerConfig: config}, &trace); err != nil {
+        return nil, err
+    }
+    return &trace, nil
+}
+
+// traceTransaction calls debug_traceTransaction, which the relay only serves
+// with DEBUG_API_ENABLED set.
+func traceTransaction(client *ethclient.Client, txHash common.Hash, options traceOptions, result interface{}) error {
+    err := client.Client().CallContext(context.Background(), result, "debug_traceTransaction", txHash, options)
+    if rpcErr, ok := asRpcError(err); ok && rpcErr.Code == unsupportedMethodCode {
+        return skipf("debug API is disabled, set DEBUG_API_ENABLED on the relay")
+    }
+    if err != nil {
+        return fmt.Errorf("Failed to trace transaction with %s: %v", options.Tracer, err)
+    }
+    return nil
+}
+
+func registerDebugCases(r *runner, h *harness) {
+    r.add("debug_traceTransaction (callTracer, transfer)", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testTraceTransferCalls(h.client, h.transferTx, h.transferReceipt, h.fromAddress)
+    })
+    r.add("debug_traceTransaction (callTracer, contract creation)", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        return testTraceCreationCalls(h.client, h.contractTx, h.contractReceipt, h.fromAddress, h.contractAddress)
+    })
+    r.add("debug_traceTransaction (opcodeLogger, transfer)", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testTraceTransferOpcodes(h.client, h.transferTx)
+    })
+    r.add("debug_traceTransaction (opcodeLogger, contract creation)", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        return testTraceCreationOpcodes(h.client, h.contractTx, h.contractAddress)
+    })
+}
+
+// testTraceTransferCalls checks the dummy transfer is traced as a single
+// call from the operator to itself.
+func testTraceTransferCalls(client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt, from common.Address) error {
+    frame, err := traceCall(client, tx.Hash(), callTracerConfig{})
+    if err != nil {
+        return err
+    }
+    if err := checkTopFrame(frame, "CALL", tx, receipt, from, *tx.To()); err != nil {
+        return err
+    }
+    if input := strings.TrimPrefix(frame.Input, "0x"); input != common.Bytes2Hex(tx.Data()) {
+        return fmt.Errorf("Call input is %q, expected %q", frame.Input, hexutil.Encode(tx.Data()))
+    }
+    if frame.Calls != nil {
+        return fmt.Errorf("Transfer has %d subcalls, expected none", len(frame.Calls))
+    }
+    fmt.Printf("Transfer traced as %s from %s, gas %d, gas used %d\n", frame.Type, frame.From.Hex(), frame.Gas, frame.GasUsed)
+    return nil
+}
+
+// testTraceCreationCalls traces the contract creation with and without
+// onlyTopCall. Both must describe the same top frame, and only the full
+// trace may have subcalls.
+func testTraceCreationCalls(client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt, from common.Address, contractAddress common.Address) error {
+    full, err := traceCall(client, tx.Hash(), callTracerConfig{OnlyTopCall: false})
+    if err != nil {
+        return err
+    }
+    if err := checkTopFrame(full, "CREATE", tx, receipt, from, contractAddress); err != nil {
+        return err
+    }
+    code, err := client.CodeAt(context.Background(), contractAddress, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get code at address: %v", err)
+    }
+    if output := strings.TrimPrefix(full.Output, "0x"); output != common.Bytes2Hex(code) {
+        return fmt.Errorf("Creation output is not the deployed code: got %q", full.Output)
+    }
+    if err := checkSubcalls(full.Calls, "calls"); err != nil {
+        return err
+    }
+
+    top, err := traceCall(client, tx.Hash(), callTracerConfig{OnlyTopCall: true})
+    if err != nil {
+        return err
+    }
+    if top.Calls != nil {
+        return fmt.Errorf("onlyTopCall trace has %d subcalls, expected none", len(top.Calls))
+    }
+    full.Calls = nil
+    if !reflect.DeepEqual(*top, *full) {
+        return fmt.Errorf("onlyTopCall changed the top frame: got %+v, expected %+v", *top, *full)
+    }
+    fmt.Printf("Contract creation traced as %s of %s, gas %d, gas used %d\n", top.Type, top.To.Hex(), top.Gas, top.GasUsed)
+    return nil
+}
+
+// checkTopFrame checks the frame of the transaction itself: its value is the
+// transaction's in tinybars, its gas is the gas limit and its gas used is the
+// gas used of the receipt.
+func checkTopFrame(frame *callFrame, callType string, tx *types.Transaction, receipt *types.Receipt, from common.Address, to common.Address) error {
+    if frame.Type != callType {
+        return fmt.Errorf("Call type is %s, expected %s", frame.Type, callType)
+    }
+    if frame.From != from {
+        return fmt.Errorf("Call is from %s, expected %s", frame.From.Hex(), from.Hex())
+    }
+    if frame.To == nil || *frame.To != to {
+        return fmt.Errorf("Call is to %v, expected %s", frame.To, to.Hex())
+    }
+    if frame.Value == nil {
+        return fmt.Errorf("Call value is missing")
+    }
+    // The relay reports the amount of the mirror node, in tinybars.
+    expected := new(big.Int).Quo(tx.Value(), big.NewInt(hedera.WeibarsPerTinybar))
+    if value := frame.Value.ToInt(); value.Cmp(expected) != 0 {
+        if tx.Value().Sign() != 0 && value.Cmp(tx.Value()) == 0 {
+            return fmt.Errorf("Call value %s is in weibars, expected %s tinybars", value.String(), expected.String())
+        }
+        return fmt.Errorf("Call value is %s, expected %s tinybars", value.String(), expected.String())
+    }
+    if uint64(frame.Gas) != tx.Gas() {
+        return fmt.Errorf("Call gas is %d, expected the gas limit %d", frame.Gas, tx.Gas())
+    }
+    if uint64(frame.GasUsed) != receipt.GasUsed {
+        return fmt.Errorf("Call gas used is %d, expected %d from the receipt", frame.GasUsed, receipt.GasUsed)
+    }
+    if frame.Error != "" {
+        return fmt.Errorf("Call of a successful transaction has error %q", frame.Error)
+    }
+    return nil
+}
+
+func checkSubcalls(calls []callFrame, path string) error {
+    for i, call := range calls {
+        name := fmt.Sprintf("%s[%d]", path, i)
+        if call.Type == "" {
+            return fmt.Errorf("Subcall %s has no type", name)
+        }
+        if call.GasUsed > call.Gas {
+            return fmt.Errorf("Subcall %s used %d gas out of %d", name, call.GasUsed, call.Gas)
+        }
+        if err := checkSubcalls(call.Calls, name+".calls"); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// testTraceTransferOpcodes checks the dummy transfer, which runs no code, is
+// traced without any opcodes.
+func testTraceTransferOpcodes(client *ethclient.Client, tx *types.Transaction) error {
+    trace, err := traceOpcodes(client, tx.Hash(), opcodeLoggerConfig{})
+    if err != nil {
+        return err
+    }
+    if trace.Failed {
+        return fmt.Errorf("Transfer trace is marked as failed")
+    }
+    if trace.Gas == 0 || trace.Gas > tx.Gas() {
+        return fmt.Errorf("Transfer trace gas is %d, expected at most the gas limit %d", trace.Gas, tx.Gas())
+    }
+    if len(trace.StructLogs) != 0 {
+        return fmt.Errorf("Transfer trace has %d opcodes, expected none", len(trace.StructLogs))
+    }
+    fmt.Printf("Transfer traced with gas %d and no opcodes\n", trace.Gas)
+    return nil
+}
+
+// testTraceCreationOpcodes traces the contract creation with the default
+// options, which include stack and storage but not memory, then with
+// disableStorage.
+func testTraceCreationOpcodes(client *ethclient.Client, tx *types.Transaction, contractAddress common.Address) error {
+    trace, err := traceOpcodes(client, tx.Hash(), opcodeLoggerConfig{})
+    if err != nil {
+        return err
+    }
+    if err := checkOpcodeTrace(client, trace, tx, contractAddress); err != nil {
+        return err
+    }
+    stored, err := client.StorageAt(context.Background(), contractAddress, common.Hash{}, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get storage at address: %v", err)
+    }
+    withStack, withStorage := false, false
+    for _, log := range trace.StructLogs {
+        if log.Memory != nil {
+            return fmt.Errorf("Opcode %s at pc %d has memory, which is disabled by default", log.Op, log.Pc)
+        }
+        if log.Stack != nil {
+            withStack = true
+        }
+        if log.Op == "SSTORE" && log.Storage != nil {
+            for key, value := range *log.Storage {
+                if common.HexToHash(key) == (common.Hash{}) && common.HexToHash(value) == common.BytesToHash(stored) {
+                    withStorage = true
+                }
+            }
+        }
+    }
+    if !withStack {
+        return fmt.Errorf("No opcode has a stack, which is enabled by default")
+    }
+    if !withStorage {
+        return fmt.Errorf("No SSTORE has the stored value %s in slot 0", hexutil.Encode(stored))
+    }
+
+    trace, err = traceOpcodes(client, tx.Hash(), opcodeLoggerConfig{DisableStorage: true})
+    if err != nil {
+        return err
+    }
+    if err := checkOpcodeTrace(client, trace, tx, contractAddress); err != nil {
+        return err
+    }
+    for _, log := range trace.StructLogs {
+        if log.Storage != nil {
+            return fmt.Errorf("Opcode %s at pc %d has storage with disableStorage set", log.Op, log.Pc)
+        }
+    }
+    fmt.Printf("Contract creation traced with %d opcodes, gas %d\n", len(trace.StructLogs), trace.Gas)
+    return nil
+}
+
+// checkOpcodeTrace checks the trace succeeded and returned the deployed
+// code, and that gas only goes down while the constructor runs.
+func checkOpcodeTrace(client *ethclient.Client, trace *opcodeTrace, tx *types.Transaction, contractAddress common.Address) error {
+    if trace.Failed {
+        return fmt.Errorf("Contract creation trace is marked as failed")
+    }
+    if trace.Gas == 0 || trace.Gas > tx.Gas() {
+        return fmt.Errorf("Contract creation trace gas is %d, expected at most the gas limit %d", trace.Gas, tx.Gas())
+    }
+    code, err := client.CodeAt(context.Background(), contractAddress, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get code at address: %v", err)
+    }
+    if trace.ReturnValue != common.Bytes2Hex(code) {
+        return fmt.Errorf("Contract creation trace did not return the deployed code: got %q", trace.ReturnValue)
+    }
+    if len(trace.StructLogs) == 0 {
+        return fmt.Errorf("Contract creation trace has no opcodes")
+    }
+    first := trace.StructLogs[0]
+    if first.Pc != 0 || first.Depth != 1 {
+        return fmt.Errorf("First opcode is at pc %d and depth %d, expected pc 0 and depth 1", first.Pc, first.Depth)
+    }
+    for i := 1; i < len(trace.StructLogs); i++ {
+        previous, current := trace.StructLogs[i-1], trace.StructLogs[i]
+        if current.Depth == 1 && previous.Depth == 1 && current.Gas > previous.Gas {
+            return fmt.Errorf("Gas went up from %d to %d at pc %d", previous.Gas, current.Gas, current.Pc)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,387 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "math/big"
+    "strings"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/eth/ethconfig"
+    "github.com/ethereum/go-ethereum/ethclient/simulated"
+    "github.com/ethereum/go-ethereum/node"
+    "github.com/ethereum/go-ethereum/params"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// hederaDeviations are the differences from the reference EVM that are
+// expected on Hedera, keyed by the field they show up in.
+var hederaDeviations = map[string]string{
+    "receipt.gasUsed":           "Hedera charges at least 80% of the gas limit",
+    "receipt.cumulativeGasUsed": "Hedera charges at least 80% of the gas limit and blocks hold transactions of other accounts",
+    "eth_estimateGas":           "the relay estimates through the mirror node, which prices Hedera's own costs",
+}
+
+// diffReferenceBalance funds the operator on the reference chain, where
+// only the operator's transactions are replayed.
+var diffReferenceBalance = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(params.Ether))
+
+// difference is a value the relay returned that the reference EVM does not
+// agree with.
+type difference struct {
+    field     string
+    relay     string
+    reference string
+}
+
+// diffSession replays every transaction it sends to the relay on a
+// go-ethereum simulated backend. The backend starts with the operator at
+// the nonce it has on the relay, so the same signed transactions are valid
+// on both chains as long as the operator sends nothing else in between.
+type diffSession struct {
+    h     *harness
+    allow map[string]string
+
+    backend *simulated.Backend
+    // Addresses of the contracts deployed on both chains.
+    sampleContract common.Address
+    logsContract   common.Address
+    logs           *contract
+}
+
+// registerDiffCases adds the differential cases. The returned session must
+// be closed once the cases ran.
+func registerDiffCases(r *runner, h *harness, allow []string) *diffSession {
+    s := &diffSession{h: h, allow: map[string]string{}}
+    for field, reason := range hederaDeviations {
+        s.allow[field] = reason
+    }
+    for _, field := range allow {
+        s.allow[field] = "allowed with --diff-allow"
+    }
+    r.add("eth_sendRawTransaction (differential, transfer)", s.testTransfer)
+    r.add("eth_sendRawTransaction (differential, contract creation)", s.testContractCreation)
+    r.add("eth_sendRawTransaction (differential, logs)", s.testLogs)
+    r.add("eth_sendRawTransaction (differential, revert)", s.testRevert)
+    return s
+}
+
+func (s *diffSession) close() {
+    if s.backend != nil {
+        s.backend.Close()
+    }
+}
+
+// start creates the reference chain with the chain ID of the relay, so
+// transactions signed for the relay replay unchanged.
+func (s *diffSession) start() error {
+    if s.backend != nil {
+        return nil
+    }
+    nonce, err := s.h.client.PendingNonceAt(context.Background(), s.h.fromAddress)
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    alloc := types.GenesisAlloc{
+        s.h.fromAddress: {Balance: diffReferenceBalance, Nonce: nonce},
+    }
+    s.backend = simulated.NewBackend(alloc, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+        config := *ethConf.Genesis.Config
+        config.ChainID = new(big.Int).Set(s.h.chainId)
+        ethConf.Genesis.Config = &config
+        ethConf.NetworkId = s.h.chainId.Uint64()
+    })
+    return nil
+}
+
+// send signs a transaction from the operator, waits for the relay to mine
+// it and replays it on the reference chain.
+func (s *diffSession) send(to *common.Address, value *big.Int, data []byte, gas uint64) (*types.Receipt, *types.Receipt, error) {
+    if err := s.start(); err != nil {
+        return nil, nil, err
+    }
+    ctx := context.Background()
+    nonce, err := s.h.client.PendingNonceAt(ctx, s.h.fromAddress)
+    if err != nil {
+        return nil, nil, fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := s.h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return nil, nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    signedTx, err := types.SignNewTx(s.h.privateKey, types.NewEIP2930Signer(s.h.chainId), &types.AccessListTx{
+        ChainID:  s.h.chainId,
+        Nonce:    nonce,
+        GasPrice: gasPrice,
+        Gas:      gas,
+        To:       to,
+        Value:    value,
+        Data:     data,
+    })
+    if err != nil {
+        return nil, nil, fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    if err := s.h.client.SendTransaction(ctx, signedTx); err != nil {
+        return nil, nil, fmt.Errorf("Failed to send transaction: %v", err)
+    }
+    receipt, err := waitForTransaction(s.h.client, signedTx)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    reference := s.backend.Client()
+    if err := reference.SendTransaction(ctx, signedTx); err != nil {
+        return nil, nil, fmt.Errorf("Reference EVM rejected transaction %s: %v", signedTx.Hash().Hex(), err)
+    }
+    s.backend.Commit()
+    referenceReceipt, err := reference.TransactionReceipt(ctx, signedTx.Hash())
+    if err != nil {
+        return nil, nil, fmt.Errorf("Failed to get reference receipt of %s: %v", signedTx.Hash().Hex(), err)
+    }
+    return receipt, referenceReceipt, nil
+}
+
+// check reports every difference, and fails on those that are not allowed.
+func (s *diffSession) check(diffs []difference) error {
+    var failed []string
+    for _, d := range diffs {
+        if reason, ok := s.allow[d.field]; ok {
+            fmt.Printf("Allowed difference in %s (%s): relay %s, reference %s\n", d.field, reason, d.relay, d.reference)
+            continue
+        }
+        fmt.Printf("Difference in %s: relay %s, reference %s\n", d.field, d.relay, d.reference)
+        failed = append(failed, fmt.Sprintf("%s: relay %s, reference %s", d.field, d.relay, d.reference))
+    }
+    if len(failed) > 0 {
+        return fmt.Errorf("Differences from the reference EVM: %s", strings.Join(failed, "; "))
+    }
+    return nil
+}
+
+// compareReceipts compares the execution results of a receipt. Fields that
+// depend on the block, such as its hash, number and log indexes, are not
+// compared.
+func compareReceipts(receipt, reference *types.Receipt) []difference {
+    var diffs []difference
+    add := func(field string, relay, ref interface{}) {
+        r, f := fmt.Sprint(relay), fmt.Sprint(ref)
+        if r != f {
+            diffs = append(diffs, difference{field: field, relay: r, reference: f})
+        }
+    }
+    add("receipt.type", receipt.Type, reference.Type)
+    add("receipt.status", receipt.Status, reference.Status)
+    add("receipt.contractAddress", receipt.ContractAddress.Hex(), reference.ContractAddress.Hex())
+    add("receipt.gasUsed", receipt.GasUsed, reference.GasUsed)
+    add("receipt.cumulativeGasUsed", receipt.CumulativeGasUsed, reference.CumulativeGasUsed)
+    add("receipt.effectiveGasPrice", receipt.EffectiveGasPrice, reference.EffectiveGasPrice)
+    add("receipt.logsBloom", hexutil.Encode(receipt.Bloom[:]), hexutil.Encode(reference.Bloom[:]))
+    add("receipt.logs", formatLogs(receipt.Logs), formatLogs(reference.Logs))
+    return diffs
+}
+
+// formatLogs renders the address, topics and data of each log.
+func formatLogs(logs []*types.Log) string {
+    formatted := make([]string, len(logs))
+    for i, log := range logs {
+        topics := make([]string, len(log.Topics))
+        for j, topic := range log.Topics {
+            topics[j] = topic.Hex()
+        }
+        formatted[i] = fmt.Sprintf("{%s [%s] %s}", log.Address.Hex(), strings.Join(topics, " "), hexutil.Encode(log.Data))
+    }
+    return "[" + strings.Join(formatted, " ") + "]"
+}
+
+// compareState compares the code and the given storage slots of a contract
+// at the latest block.
+func (s *diffSession) compareState(address common.Address, slots ...common.Hash) ([]difference, error) {
+    ctx := context.Background()
+    reference := s.backend.Client()
+    var diffs []difference
+    code, err := s.h.client.CodeAt(ctx, address, nil)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get code: %v", err)
+    }
+    referenceCode, err := reference.CodeAt(ctx, address, nil)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get reference code: %v", err)
+    }
+    if !bytes.Equal(code, referenceCode) {
+        diffs = append(diffs, difference{field: "eth_getCode", relay: hexutil.Encode(code), reference: hexutil.Encode(referenceCode)})
+    }
+    for _, slot := range slots {
+        value, err := s.h.client.StorageAt(ctx, address, slot, nil)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to get storage: %v", err)
+        }
+        referenceValue, err := reference.StorageAt(ctx, address, slot, nil)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to get reference storage: %v", err)
+        }
+        if !bytes.Equal(value, referenceValue) {
+            diffs = append(diffs, difference{field: "eth_getStorageAt", relay: hexutil.Encode(value), reference: hexutil.Encode(referenceValue)})
+        }
+    }
+    return diffs, nil
+}
+
+// compareCall compares the eth_call result and the eth_estimateGas of msg.
+// A call that fails must fail on both.
+func (s *diffSession) compareCall(msg ethereum.CallMsg) []difference {
+    ctx := context.Background()
+    reference := s.backend.Client()
+    var diffs []difference
+
+    result, err := s.h.client.CallContract(ctx, msg, nil)
+    referenceResult, referenceErr := reference.CallContract(ctx, msg, nil)
+    relayCall, referenceCall := formatCall(result, err), formatCall(referenceResult, referenceErr)
+    if (err == nil) != (referenceErr == nil) || (err == nil && relayCall != referenceCall) {
+        diffs = append(diffs, difference{field: "eth_call", relay: relayCall, reference: referenceCall})
+    }
+
+    gas, err := s.h.client.EstimateGas(ctx, msg)
+    referenceGas, referenceErr := reference.EstimateGas(ctx, msg)
+    if (err == nil) != (referenceErr == nil) || gas != referenceGas {
+        diffs = append(diffs, difference{field: "eth_estimateGas", relay: formatEstimate(gas, err), reference: formatEstimate(referenceGas, referenceErr)})
+    }
+    return diffs
+}
+
+func formatCall(result []byte, err error) string {
+    if err != nil {
+        return fmt.Sprintf("error %q", err.Error())
+    }
+    return hexutil.Encode(result)
+}
+
+func formatEstimate(gas uint64, err error) string {
+    if err != nil {
+        return fmt.Sprintf("error %q", err.Error())
+    }
+    return fmt.Sprint(gas)
+}
+
+func (s *diffSession) testTransfer() error {
+    receipt, reference, err := s.send(&s.h.fromAddress, big.NewInt(hedera.WeibarsPerTinybar), nil, 21000)
+    if err != nil {
+        return err
+    }
+    return s.check(compareReceipts(receipt, reference))
+}
+
+// testContractCreation deploys SampleContract and compares its code, its
+// storedValue slot and the result of calling storedValue().
+func (s *diffSession) testContractCreation() error {
+    bytecode, err := sampleContractBytecode()
+    if err != nil {
+        return err
+    }
+    receipt, reference, err := s.send(nil, big.NewInt(0), bytecode, contractDeployGas)
+    if err != nil {
+        return err
+    }
+    diffs := compareReceipts(receipt, reference)
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return s.check(diffs)
+    }
+    s.sampleContract = receipt.ContractAddress
+    state, err := s.compareState(s.sampleContract, common.Hash{})
+    if err != nil {
+        return err
+    }
+    diffs = append(diffs, state...)
+    diffs = append(diffs, s.compareCall(ethereum.CallMsg{
+        From: s.h.fromAddress,
+        To:   &s.sampleContract,
+        Data: crypto.Keccak256([]byte("storedValue()"))[:4],
+    })...)
+    return s.check(diffs)
+}
+
+// testLogs deploys the Logs contract and emits an event with every number
+// of topics.
+func (s *diffSession) testLogs() error {
+    logs, err := loadContract("Logs")
+    if err != nil {
+        return err
+    }
+    receipt, reference, err := s.send(nil, big.NewInt(0), logs.bytecode, contractDeployGas)
+    if err != nil {
+        return err
+    }
+    diffs := compareReceipts(receipt, reference)
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return s.check(diffs)
+    }
+    s.logs = logs
+    s.logsContract = receipt.ContractAddress
+
+    calls := []struct {
+        method string
+        args   []interface{}
+    }{
+        {"log0", []interface{}{big.NewInt(1)}},
+        {"log1", []interface{}{big.NewInt(1)}},
+        {"log2", []interface{}{big.NewInt(1), big.NewInt(2)}},
+        {"log3", []interface{}{big.NewInt(1), big.NewInt(2), big.NewInt(3)}},
+        {"log4", []interface{}{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)}},
+    }
+    for _, call := range calls {
+        data, err := logs.abi.Pack(call.method, call.args...)
+        if err != nil {
+            return fmt.Errorf("Failed to pack %s call: %v", call.method, err)
+        }
+        receipt, reference, err := s.send(&s.logsContract, big.NewInt(0), data, contractCallGas)
+        if err != nil {
+            return err
+        }
+        diffs = append(diffs, compareReceipts(receipt, reference)...)
+    }
+    return s.check(diffs)
+}
+
+// testRevert calls a function the Logs contract does not have, which has to
+// revert both as a call and as a transaction.
+func (s *diffSession) testRevert() error {
+    if s.logs == nil {
+        return skipf("Logs contract was not deployed")
+    }
+    data := []byte{0xde, 0xad, 0xbe, 0xef}
+    diffs := s.compareCall(ethereum.CallMsg{From: s.h.fromAddress, To: &s.logsContract, Data: data})
+    receipt, reference, err := s.send(&s.logsContract, big.NewInt(0), data, contractCallGas)
+    if err != nil {
+        return err
+    }
+    if receipt.Status != types.ReceiptStatusFailed {
+        return fmt.Errorf("Transaction %s did not revert", receipt.TxHash.Hex())
+    }
+    diffs = append(diffs, compareReceipts(receipt, reference)...)
+    return s.check(diffs)
+}
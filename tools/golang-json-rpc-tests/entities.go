@@ -0,0 +1,231 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "hedera-json-rpc-golang-tests-project/entity"
+)
+
+const (
+    mainnetMirrorNode    = "https://mainnet-public.mirrornode.hedera.com"
+    testnetMirrorNode    = "https://testnet.mirrornode.hedera.com"
+    previewnetMirrorNode = "https://previewnet.mirrornode.hedera.com"
+    localMirrorNode      = "http://localhost:5551"
+)
+
+// entityScenario checks the operator and a contract resolve to the same
+// entity whether addressed by their EVM address or by the long-zero form
+// of the entity ID the mirror node reports.
+type entityScenario struct {
+    h         *harness
+    mirrorUrl string
+    client    *http.Client
+}
+
+func registerEntityCases(r *runner, h *harness, mirrorUrl string) {
+    s := &entityScenario{h: h, mirrorUrl: strings.TrimSuffix(mirrorUrl, "/"), client: &http.Client{Transport: h.mirror, Timeout: 10 * time.Second}}
+    r.add("eth_getBalance (long-zero operator)", func() error {
+        longZero, err := s.longZero("accounts", h.fromAddress)
+        if err != nil {
+            return err
+        }
+        return s.compareBalances(h.fromAddress, longZero)
+    })
+    r.add("eth_getTransactionCount (long-zero operator)", func() error {
+        longZero, err := s.longZero("accounts", h.fromAddress)
+        if err != nil {
+            return err
+        }
+        ctx := context.Background()
+        block, err := s.latestBlock()
+        if err != nil {
+            return err
+        }
+        nonce, err := h.client.NonceAt(ctx, h.fromAddress, block)
+        if err != nil {
+            return fmt.Errorf("Failed to get transaction count: %v", err)
+        }
+        longZeroNonce, err := h.client.NonceAt(ctx, longZero, block)
+        if err != nil {
+            return fmt.Errorf("Failed to get transaction count of %s: %v", longZero.Hex(), err)
+        }
+        if nonce != longZeroNonce {
+            return fmt.Errorf("Transaction count of %s is %d, of %s %d", h.fromAddress.Hex(), nonce, longZero.Hex(), longZeroNonce)
+        }
+        return nil
+    })
+    r.add("eth_getCode (long-zero contract)", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        longZero, err := s.longZero("contracts", h.contractAddress)
+        if err != nil {
+            return err
+        }
+        ctx := context.Background()
+        code, err := h.client.CodeAt(ctx, h.contractAddress, nil)
+        if err != nil {
+            return fmt.Errorf("Failed to get code at address: %v", err)
+        }
+        longZeroCode, err := h.client.CodeAt(ctx, longZero, nil)
+        if err != nil {
+            return fmt.Errorf("Failed to get code at %s: %v", longZero.Hex(), err)
+        }
+        if len(code) == 0 || string(code) != string(longZeroCode) {
+            return fmt.Errorf("Code at %s has %d bytes, at %s %d bytes", h.contractAddress.Hex(), len(code), longZero.Hex(), len(longZeroCode))
+        }
+        return nil
+    })
+    r.add("eth_getBalance (long-zero contract)", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        longZero, err := s.longZero("contracts", h.contractAddress)
+        if err != nil {
+            return err
+        }
+        return s.compareBalances(h.contractAddress, longZero)
+    })
+    r.add("eth_getLogs (long-zero contract)", s.testLogs)
+}
+
+// longZero returns the long-zero address of the account or contract with
+// the given EVM address.
+func (s *entityScenario) longZero(kind string, address common.Address) (common.Address, error) {
+    id, err := s.lookupEntity(kind, address)
+    if err != nil {
+        return common.Address{}, err
+    }
+    longZero := id.LongZeroAddress()
+    fmt.Printf("%s is %s, long-zero address %s\n", address.Hex(), id.String(), longZero.Hex())
+    return longZero, nil
+}
+
+// lookupEntity asks the mirror node for the entity ID of an EVM address and
+// checks it maps the entity back to the same address.
+func (s *entityScenario) lookupEntity(kind string, address common.Address) (entity.ID, error) {
+    if s.mirrorUrl == "" {
+        return entity.ID{}, skipf("no mirror node to look up entity IDs")
+    }
+    idField := map[string]string{"accounts": "account", "contracts": "contract_id"}[kind]
+    res, err := s.client.Get(fmt.Sprintf("%s/api/v1/%s/%s", s.mirrorUrl, kind, address.Hex()))
+    if err != nil {
+        return entity.ID{}, fmt.Errorf("Failed to look up %s on the mirror node: %v", address.Hex(), err)
+    }
+    defer res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        return entity.ID{}, fmt.Errorf("Mirror node returned %s for %s", res.Status, address.Hex())
+    }
+    var body map[string]interface{}
+    if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+        return entity.ID{}, fmt.Errorf("Failed to decode mirror node response: %v", err)
+    }
+    idString, _ := body[idField].(string)
+    id, err := entity.Parse(idString)
+    if err != nil {
+        return entity.ID{}, err
+    }
+    evmAddress, _ := body["evm_address"].(string)
+    if !common.IsHexAddress(evmAddress) || common.HexToAddress(evmAddress) != address {
+        return entity.ID{}, fmt.Errorf("Mirror node maps %s to EVM address %q, expected %s", id.String(), evmAddress, address.Hex())
+    }
+    return id, nil
+}
+
+func (s *entityScenario) latestBlock() (*big.Int, error) {
+    number, err := s.h.client.BlockNumber(context.Background())
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get block number: %v", err)
+    }
+    return new(big.Int).SetUint64(number), nil
+}
+
+// compareBalances reads both balances at the same block so a transfer in
+// between cannot tell them apart.
+func (s *entityScenario) compareBalances(address, longZero common.Address) error {
+    block, err := s.latestBlock()
+    if err != nil {
+        return err
+    }
+    ctx := context.Background()
+    balance, err := s.h.client.BalanceAt(ctx, address, block)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    longZeroBalance, err := s.h.client.BalanceAt(ctx, longZero, block)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance of %s: %v", longZero.Hex(), err)
+    }
+    if balance.Cmp(longZeroBalance) != 0 {
+        return fmt.Errorf("Balance of %s is %s, of %s %s", address.Hex(), balance.String(), longZero.Hex(), longZeroBalance.String())
+    }
+    return nil
+}
+
+// testLogs emits an event and checks it is found by the contract's EVM
+// address, its long-zero address and both together.
+func (s *entityScenario) testLogs() error {
+    if s.mirrorUrl == "" {
+        return skipf("no mirror node to look up entity IDs")
+    }
+    logs, err := loadContract("Logs")
+    if err != nil {
+        return err
+    }
+    address, _, err := logs.deploy(s.h)
+    if err != nil {
+        return err
+    }
+    receipt, err := logs.transact(s.h, address, "log1", big.NewInt(1))
+    if err != nil {
+        return err
+    }
+    longZero, err := s.longZero("contracts", address)
+    if err != nil {
+        return err
+    }
+
+    for _, addresses := range [][]common.Address{{address}, {longZero}, {address, longZero}} {
+        found, err := s.h.client.FilterLogs(context.Background(), ethereum.FilterQuery{
+            FromBlock: receipt.BlockNumber,
+            ToBlock:   receipt.BlockNumber,
+            Addresses: addresses,
+        })
+        if err != nil {
+            return fmt.Errorf("Failed to get logs of %v: %v", addresses, err)
+        }
+        if len(found) != 1 || found[0].TxHash != receipt.TxHash {
+            return fmt.Errorf("Logs of %v are %d, expected the single log of %s", addresses, len(found), receipt.TxHash.Hex())
+        }
+    }
+    return nil
+}
@@ -0,0 +1,100 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+// Package entity converts between Hedera entity IDs, written
+// shard.realm.num, and their long-zero EVM addresses.
+//
+// A long-zero address is the entity ID packed into 20 bytes: 4 bytes of
+// shard, 8 of realm and 8 of num. On the shard 0 realm 0 networks in use
+// the first 12 bytes are zero, hence the name.
+package entity
+
+import (
+    "encoding/binary"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+)
+
+// ID identifies an account, contract, token or file on a Hedera network.
+type ID struct {
+    Shard uint64
+    Realm uint64
+    Num   uint64
+}
+
+// Parse parses an entity ID written shard.realm.num, e.g. 0.0.1234.
+func Parse(s string) (ID, error) {
+    parts := strings.Split(s, ".")
+    if len(parts) != 3 {
+        return ID{}, fmt.Errorf("Invalid entity ID %q, expected shard.realm.num", s)
+    }
+    var numbers [3]uint64
+    for i, part := range parts {
+        n, err := strconv.ParseUint(part, 10, 64)
+        if err != nil {
+            return ID{}, fmt.Errorf("Invalid entity ID %q: %v", s, err)
+        }
+        numbers[i] = n
+    }
+    id := ID{Shard: numbers[0], Realm: numbers[1], Num: numbers[2]}
+    if id.Shard > math.MaxUint32 || id.Realm > math.MaxInt64 || id.Num > math.MaxInt64 {
+        return ID{}, fmt.Errorf("Invalid entity ID %q, out of range", s)
+    }
+    return id, nil
+}
+
+func (id ID) String() string {
+    return fmt.Sprintf("%d.%d.%d", id.Shard, id.Realm, id.Num)
+}
+
+// LongZeroAddress returns the EVM address of the entity that is derived
+// from its ID rather than from a key.
+func (id ID) LongZeroAddress() common.Address {
+    var address common.Address
+    binary.BigEndian.PutUint32(address[0:4], uint32(id.Shard))
+    binary.BigEndian.PutUint64(address[4:12], id.Realm)
+    binary.BigEndian.PutUint64(address[12:20], id.Num)
+    return address
+}
+
+// IsLongZero reports whether the address has zero shard and realm, which
+// no ECDSA alias or CREATE address has in practice.
+func IsLongZero(address common.Address) bool {
+    for _, b := range address[:12] {
+        if b != 0 {
+            return false
+        }
+    }
+    return true
+}
+
+// FromLongZeroAddress returns the entity ID of a long-zero address. It
+// returns false for other addresses.
+func FromLongZeroAddress(address common.Address) (ID, bool) {
+    if !IsLongZero(address) {
+        return ID{}, false
+    }
+    return ID{Num: binary.BigEndian.Uint64(address[12:20])}, true
+}
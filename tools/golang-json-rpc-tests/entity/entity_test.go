@@ -0,0 +1,62 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package entity
+
+import (
+    "testing"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+    id, err := Parse("0.0.1234")
+    require.NoError(t, err)
+    assert.Equal(t, ID{Num: 1234}, id)
+    assert.Equal(t, "0.0.1234", id.String())
+
+    for _, invalid := range []string{"", "1234", "0.0", "0.0.x", "0.0.-1", "4294967296.0.1"} {
+        _, err := Parse(invalid)
+        assert.Error(t, err, invalid)
+    }
+}
+
+func TestLongZeroAddress(t *testing.T) {
+    id := ID{Num: 1234}
+    address := id.LongZeroAddress()
+    assert.Equal(t, common.HexToAddress("0x00000000000000000000000000000000000004d2"), address)
+    assert.True(t, IsLongZero(address))
+
+    parsed, ok := FromLongZeroAddress(address)
+    require.True(t, ok)
+    assert.Equal(t, id, parsed)
+
+    withShard := ID{Shard: 1, Realm: 2, Num: 3}.LongZeroAddress()
+    assert.Equal(t, common.HexToAddress("0x0000000100000000000000020000000000000003"), withShard)
+    assert.False(t, IsLongZero(withShard))
+}
+
+func TestFromLongZeroAddressRejectsAliases(t *testing.T) {
+    _, ok := FromLongZeroAddress(common.HexToAddress("0x67d8d32e9bf1a9968a5ff53b87d777aa8ebbee69"))
+    assert.False(t, ok)
+}
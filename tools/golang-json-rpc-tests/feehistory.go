@@ -0,0 +1,269 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// relayFeeHistoryMaxResults is the relay's default FEE_HISTORY_MAX_RESULTS.
+const relayFeeHistoryMaxResults = 10
+
+// rpcFeeHistory is an eth_feeHistory result.
+type rpcFeeHistory struct {
+    OldestBlock   *hexutil.Big     `json:"oldestBlock"`
+    BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+    GasUsedRatio  []float64        `json:"gasUsedRatio"`
+    Reward        [][]*hexutil.Big `json:"reward"`
+}
+
+// feeHistoryPercentiles are the rewardPercentiles every block count is
+// requested with. nil omits the parameter.
+var feeHistoryPercentiles = [][]float64{
+    nil,
+    {},
+    {50},
+    {10, 50, 90},
+    {0, 25, 50, 75, 100},
+}
+
+// registerFeeHistoryCases validates eth_feeHistory for several block counts,
+// newest blocks and percentiles, the block count cap and invalid input.
+func registerFeeHistoryCases(r *runner, h *harness, maxResults uint64) {
+    blockCounts := []uint64{1, 2, 5, maxResults, maxResults + 5}
+    for _, newest := range []string{"latest", "pending", "earliest", "transfer block"} {
+        newest := newest
+        r.add(fmt.Sprintf("eth_feeHistory (newest %s)", newest), func() error {
+            tag := newest
+            if newest == "transfer block" {
+                if err := h.requireTransfer(); err != nil {
+                    return err
+                }
+                tag = hexutil.EncodeBig(h.transferReceipt.BlockNumber)
+            }
+            var diffs []string
+            for _, blockCount := range blockCounts {
+                for _, percentiles := range feeHistoryPercentiles {
+                    requestDiffs, err := checkFeeHistory(h, blockCount, tag, percentiles, maxResults)
+                    if err != nil {
+                        return err
+                    }
+                    for _, diff := range requestDiffs {
+                        diffs = append(diffs, fmt.Sprintf("blockCount %d, percentiles %v: %s", blockCount, percentiles, diff))
+                    }
+                }
+            }
+            if len(diffs) > 0 {
+                return fmt.Errorf("Fee history for newest block %s is inconsistent:\n    %s", tag, strings.Join(diffs, "\n    "))
+            }
+            return nil
+        })
+    }
+    r.add("eth_feeHistory (block count cap)", func() error {
+        var history rpcFeeHistory
+        err := h.client.Client().CallContext(context.Background(), &history, "eth_feeHistory", hexutil.Uint64(maxResults*10), "latest", []float64{50})
+        if err != nil {
+            return fmt.Errorf("Failed to get fee history: %v", err)
+        }
+        if uint64(len(history.GasUsedRatio)) != maxResults {
+            return fmt.Errorf("Fee history of %d blocks returned %d, expected the cap of %d", maxResults*10, len(history.GasUsedRatio), maxResults)
+        }
+        return nil
+    })
+
+    invalid := []struct {
+        name    string
+        params  func() ([]interface{}, error)
+        code    int
+        message string
+    }{
+        {"invalid block count", func() ([]interface{}, error) {
+            return []interface{}{"ten", "latest"}, nil
+        }, -32602, ""},
+        {"invalid percentiles", func() ([]interface{}, error) {
+            return []interface{}{"0x1", "latest", "0x32"}, nil
+        }, -32602, ""},
+        {"beyond head block", func() ([]interface{}, error) {
+            head, err := h.client.BlockNumber(context.Background())
+            if err != nil {
+                return nil, fmt.Errorf("Failed to get block number: %v", err)
+            }
+            return []interface{}{"0x1", hexutil.EncodeUint64(head + 1000)}, nil
+        }, -32000, "beyond head block"},
+    }
+    for _, c := range invalid {
+        c := c
+        r.add(fmt.Sprintf("eth_feeHistory (%s)", c.name), func() error {
+            params, err := c.params()
+            if err != nil {
+                return err
+            }
+            var history rpcFeeHistory
+            err = h.client.Client().CallContext(context.Background(), &history, "eth_feeHistory", params...)
+            if err == nil {
+                return fmt.Errorf("Fee history with %s succeeded, expected an error", c.name)
+            }
+            return h.expectRelayErrorCode(err, c.code, c.message)
+        })
+    }
+
+    // Ethereum clients reject reward percentiles out of range or in
+    // decreasing order. The relay does not validate them, so an answer is
+    // skipped as a known deviation rather than passed.
+    unvalidated := []struct {
+        name        string
+        percentiles []float64
+        validation  string
+    }{
+        {"percentile out of range", []float64{101}, "reward percentiles between 0 and 100"},
+        {"decreasing percentiles", []float64{90, 10}, "reward percentiles in increasing order"},
+    }
+    for _, c := range unvalidated {
+        c := c
+        r.add(fmt.Sprintf("eth_feeHistory (%s)", c.name), func() error {
+            var history rpcFeeHistory
+            err := h.client.Client().CallContext(context.Background(), &history, "eth_feeHistory", "0x1", "latest", c.percentiles)
+            if err == nil {
+                return skipf("known deviation: the relay does not validate %s, and answered %v instead of rejecting it", c.validation, c.percentiles)
+            }
+            return h.expectRelayErrorCode(err, -32602, "")
+        })
+    }
+}
+
+// feeHistoryRange is the range of blocks the relay returns with
+// ETH_FEE_HISTORY_FIXED, its default: blockCount capped at maxResults and
+// ending at the newest block, or block 1 alone when that range would
+// reach back to the genesis block.
+func feeHistoryRange(blockCount, newest, maxResults uint64) (uint64, uint64) {
+    if blockCount > maxResults {
+        blockCount = maxResults
+    }
+    if blockCount > newest {
+        return 1, 1
+    }
+    return newest - blockCount + 1, blockCount
+}
+
+// checkFeeHistory requests a fee history and checks its lengths, its range
+// ends at the newest block, rewards grow with the percentiles and base fees
+// match those of the blocks.
+func checkFeeHistory(h *harness, blockCount uint64, newest string, percentiles []float64, maxResults uint64) ([]string, error) {
+    ctx := context.Background()
+    head, err := h.client.BlockNumber(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get block number: %v", err)
+    }
+    params := []interface{}{hexutil.Uint64(blockCount), newest}
+    if percentiles != nil {
+        params = append(params, percentiles)
+    }
+    var history rpcFeeHistory
+    if err := h.client.Client().CallContext(ctx, &history, "eth_feeHistory", params...); err != nil {
+        return nil, fmt.Errorf("Failed to get fee history: %v", err)
+    }
+
+    var diffs []string
+    if history.OldestBlock == nil {
+        return []string{"oldestBlock is missing"}, nil
+    }
+    n := uint64(len(history.GasUsedRatio))
+    oldest := history.OldestBlock.ToInt().Uint64()
+    if n == 0 {
+        return []string{"gasUsedRatio is empty"}, nil
+    }
+    newestNumber := oldest + n - 1
+
+    // A range ending at the head may end at a later block by the time the
+    // relay answers.
+    expectedNewest := head
+    switch newest {
+    case "latest", "pending":
+    case "earliest":
+        expectedNewest = 0
+    default:
+        expectedNewest = hexutil.MustDecodeUint64(newest)
+    }
+    expectedOldest, expected := feeHistoryRange(blockCount, expectedNewest, maxResults)
+    if n != expected {
+        diffs = append(diffs, fmt.Sprintf("gasUsedRatio has %d entries, expected %d", n, expected))
+    }
+    if (newest == "latest" || newest == "pending") && expectedOldest > 1 {
+        if newestNumber < head {
+            diffs = append(diffs, fmt.Sprintf("newest block is %d, behind head %d", newestNumber, head))
+        }
+    } else if oldest != expectedOldest {
+        diffs = append(diffs, fmt.Sprintf("oldest block is %d, expected %d", oldest, expectedOldest))
+    }
+    if uint64(len(history.BaseFeePerGas)) != n+1 {
+        diffs = append(diffs, fmt.Sprintf("baseFeePerGas has %d entries, expected %d", len(history.BaseFeePerGas), n+1))
+    }
+    for i, ratio := range history.GasUsedRatio {
+        if ratio < 0 || ratio > 1 {
+            diffs = append(diffs, fmt.Sprintf("gasUsedRatio %d is %v, outside [0, 1]", i, ratio))
+        }
+    }
+
+    if len(percentiles) == 0 {
+        if history.Reward != nil {
+            diffs = append(diffs, fmt.Sprintf("reward has %d entries, expected none without percentiles", len(history.Reward)))
+        }
+    } else {
+        if uint64(len(history.Reward)) != n {
+            diffs = append(diffs, fmt.Sprintf("reward has %d entries, expected %d", len(history.Reward), n))
+        }
+        for i, rewards := range history.Reward {
+            if len(rewards) != len(percentiles) {
+                diffs = append(diffs, fmt.Sprintf("reward %d has %d entries, expected %d", i, len(rewards), len(percentiles)))
+                continue
+            }
+            for j := 1; j < len(rewards); j++ {
+                if percentiles[j] >= percentiles[j-1] && rewards[j].ToInt().Cmp(rewards[j-1].ToInt()) < 0 {
+                    diffs = append(diffs, fmt.Sprintf("reward %d at percentile %v is %s, below %s at percentile %v", i, percentiles[j], rewards[j].String(), rewards[j-1].String(), percentiles[j-1]))
+                }
+            }
+        }
+    }
+
+    // The last base fee is a prediction unless the block after the newest
+    // one exists.
+    for i, baseFee := range history.BaseFeePerGas {
+        number := oldest + uint64(i)
+        if number > head {
+            break
+        }
+        var block struct {
+            BaseFeePerGas *hexutil.Big `json:"baseFeePerGas"`
+        }
+        if err := h.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), false); err != nil {
+            return nil, fmt.Errorf("Failed to get block by number: %v", err)
+        }
+        if block.BaseFeePerGas == nil || baseFee == nil || block.BaseFeePerGas.ToInt().Cmp(baseFee.ToInt()) != 0 {
+            diffs = append(diffs, fmt.Sprintf("baseFeePerGas %d is %v, block %d has %v", i, baseFee, number, block.BaseFeePerGas))
+        }
+    }
+    return diffs, nil
+}
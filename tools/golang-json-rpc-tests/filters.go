@@ -0,0 +1,343 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+)
+
+// valueStoredTopic is the topic of the SampleContract ValueStored event,
+// which every deployment emits from its constructor.
+var valueStoredTopic = crypto.Keccak256Hash([]byte("ValueStored(uint256)"))
+
+const (
+    filterNotFoundCode    = -32001
+    filterNotFoundMessage = "Filter not found"
+
+    // Filters are served from the mirror node, which trails consensus by a
+    // few seconds, so changes are polled for a while before giving up.
+    filterPollInterval = time.Second
+    filterPollTimeout  = 30 * time.Second
+
+    filterDeployments = 2
+)
+
+// filterScenario follows a log filter and a block filter while the
+// SampleContract is deployed a few times, then uninstalls them.
+type filterScenario struct {
+    h *harness
+
+    fromBlock     uint64
+    logFilterId   string
+    blockFilterId string
+    deployments   []*types.Receipt
+}
+
+func registerFilterCases(r *runner, h *harness, filterTTL time.Duration) {
+    s := &filterScenario{h: h}
+    r.add("eth_newFilter", s.testNewFilter)
+    r.add("eth_newBlockFilter", s.testNewBlockFilter)
+    r.add("eth_newPendingTransactionFilter", func() error {
+        // The relay has no mempool to watch.
+        return testUnsupportedMethod(h, "eth_newPendingTransactionFilter")
+    })
+    r.add("eth_getFilterChanges (logs)", s.testLogFilterChanges)
+    r.add("eth_getFilterChanges (blocks)", s.testBlockFilterChanges)
+    r.add("eth_getFilterLogs", s.testGetFilterLogs)
+    r.add("eth_uninstallFilter", s.testUninstallFilter)
+    r.add("eth_getFilterChanges (expired filter)", func() error {
+        return s.testExpiredFilter(filterTTL)
+    })
+}
+
+func (s *filterScenario) requireFilters() error {
+    if s.logFilterId == "" || s.blockFilterId == "" {
+        return skipf("filters were not created")
+    }
+    return nil
+}
+
+func (s *filterScenario) requireDeployments() error {
+    if len(s.deployments) < filterDeployments {
+        return skipf("contracts were not deployed")
+    }
+    return nil
+}
+
+func (s *filterScenario) testNewFilter() error {
+    ctx := context.Background()
+    blockNumber, err := s.h.client.BlockNumber(ctx)
+    if err != nil {
+        return fmt.Errorf("Failed to get block number: %v", err)
+    }
+    criteria := map[string]interface{}{
+        "fromBlock": hexutil.EncodeUint64(blockNumber),
+        "toBlock":   "latest",
+        "topics":    []interface{}{[]common.Hash{valueStoredTopic}},
+    }
+    var id string
+    if err := s.h.client.Client().CallContext(ctx, &id, "eth_newFilter", criteria); err != nil {
+        return fmt.Errorf("Failed to create log filter: %v", err)
+    }
+    if id == "" {
+        return fmt.Errorf("Log filter ID is empty")
+    }
+    s.fromBlock = blockNumber
+    s.logFilterId = id
+    fmt.Printf("Log filter: %s\n", id)
+    return nil
+}
+
+func (s *filterScenario) testNewBlockFilter() error {
+    var id string
+    if err := s.h.client.Client().CallContext(context.Background(), &id, "eth_newBlockFilter"); err != nil {
+        return fmt.Errorf("Failed to create block filter: %v", err)
+    }
+    if id == "" {
+        return fmt.Errorf("Block filter ID is empty")
+    }
+    s.blockFilterId = id
+    fmt.Printf("Block filter: %s\n", id)
+    return nil
+}
+
+// testLogFilterChanges deploys the SampleContract and polls the log filter
+// until every ValueStored event arrived, checking none arrives twice.
+func (s *filterScenario) testLogFilterChanges() error {
+    if err := s.requireFilters(); err != nil {
+        return err
+    }
+    for i := 0; i < filterDeployments; i++ {
+        tx, _, err := testSendContractCreationTransaction(s.h.client, s.h.fromAddress, s.h.privateKey, s.h.chainId)
+        if err != nil {
+            return err
+        }
+        receipt, err := waitForTransaction(s.h.client, tx)
+        if err != nil {
+            return err
+        }
+        s.deployments = append(s.deployments, receipt)
+    }
+
+    expected := map[common.Hash]bool{}
+    for _, receipt := range s.deployments {
+        expected[receipt.TxHash] = true
+    }
+    seen := map[string]bool{}
+    err := pollFilter(func() (bool, error) {
+        var logs []types.Log
+        if err := s.h.client.Client().CallContext(context.Background(), &logs, "eth_getFilterChanges", s.logFilterId); err != nil {
+            return false, fmt.Errorf("Failed to get filter changes: %v", err)
+        }
+        for _, log := range logs {
+            key := fmt.Sprintf("%s:%d", log.TxHash.Hex(), log.Index)
+            if seen[key] {
+                return false, fmt.Errorf("Log %s was returned more than once", key)
+            }
+            seen[key] = true
+            delete(expected, log.TxHash)
+        }
+        return len(expected) == 0, nil
+    })
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Log filter returned %d logs once each\n", len(seen))
+    return nil
+}
+
+// testBlockFilterChanges polls the block filter until the blocks of the
+// deployments arrived, checking no block hash arrives twice.
+func (s *filterScenario) testBlockFilterChanges() error {
+    if err := s.requireFilters(); err != nil {
+        return err
+    }
+    if err := s.requireDeployments(); err != nil {
+        return err
+    }
+
+    expected := map[common.Hash]bool{}
+    for _, receipt := range s.deployments {
+        expected[receipt.BlockHash] = true
+    }
+    seen := map[common.Hash]bool{}
+    err := pollFilter(func() (bool, error) {
+        var hashes []common.Hash
+        if err := s.h.client.Client().CallContext(context.Background(), &hashes, "eth_getFilterChanges", s.blockFilterId); err != nil {
+            return false, fmt.Errorf("Failed to get filter changes: %v", err)
+        }
+        for _, hash := range hashes {
+            if seen[hash] {
+                return false, fmt.Errorf("Block %s was returned more than once", hash.Hex())
+            }
+            seen[hash] = true
+            delete(expected, hash)
+        }
+        return len(expected) == 0, nil
+    })
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Block filter returned %d blocks once each\n", len(seen))
+    return nil
+}
+
+// pollFilter calls poll until it reports it is done, then polls once more
+// so changes that are returned twice are caught.
+func pollFilter(poll func() (bool, error)) error {
+    deadline := time.Now().Add(filterPollTimeout)
+    for {
+        done, err := poll()
+        if err != nil {
+            return err
+        }
+        if done {
+            break
+        }
+        if time.Now().After(deadline) {
+            return fmt.Errorf("Expected changes did not arrive within %s", filterPollTimeout)
+        }
+        time.Sleep(filterPollInterval)
+    }
+    time.Sleep(filterPollInterval)
+    _, err := poll()
+    return err
+}
+
+// testGetFilterLogs checks the log filter returns the same logs as
+// eth_getLogs with the same criteria, up to the last deployment.
+func (s *filterScenario) testGetFilterLogs() error {
+    if err := s.requireFilters(); err != nil {
+        return err
+    }
+    if err := s.requireDeployments(); err != nil {
+        return err
+    }
+    ctx := context.Background()
+    var filterLogs []types.Log
+    if err := s.h.client.Client().CallContext(ctx, &filterLogs, "eth_getFilterLogs", s.logFilterId); err != nil {
+        return fmt.Errorf("Failed to get filter logs: %v", err)
+    }
+    logs, err := s.h.client.FilterLogs(ctx, ethereum.FilterQuery{
+        FromBlock: new(big.Int).SetUint64(s.fromBlock),
+        Topics:    [][]common.Hash{{valueStoredTopic}},
+    })
+    if err != nil {
+        return fmt.Errorf("Failed to get logs: %v", err)
+    }
+
+    lastBlock := s.deployments[len(s.deployments)-1].BlockNumber.Uint64()
+    filterKeys := logKeys(filterLogs, lastBlock)
+    getLogsKeys := logKeys(logs, lastBlock)
+    if len(filterKeys) != len(getLogsKeys) {
+        return fmt.Errorf("eth_getFilterLogs returned %d logs, eth_getLogs returned %d", len(filterKeys), len(getLogsKeys))
+    }
+    for key := range getLogsKeys {
+        if !filterKeys[key] {
+            return fmt.Errorf("Log %s returned by eth_getLogs is missing from eth_getFilterLogs", key)
+        }
+    }
+    for _, receipt := range s.deployments {
+        for _, log := range receipt.Logs {
+            key := fmt.Sprintf("%s:%d", log.TxHash.Hex(), log.Index)
+            if !filterKeys[key] {
+                return fmt.Errorf("Log %s of a deployment is missing from eth_getFilterLogs", key)
+            }
+        }
+    }
+    fmt.Printf("eth_getFilterLogs matches eth_getLogs: %d logs\n", len(filterKeys))
+    return nil
+}
+
+func logKeys(logs []types.Log, lastBlock uint64) map[string]bool {
+    keys := map[string]bool{}
+    for _, log := range logs {
+        if log.BlockNumber <= lastBlock {
+            keys[fmt.Sprintf("%s:%d", log.TxHash.Hex(), log.Index)] = true
+        }
+    }
+    return keys
+}
+
+// testUninstallFilter uninstalls both filters and checks their IDs are no
+// longer accepted.
+func (s *filterScenario) testUninstallFilter() error {
+    if err := s.requireFilters(); err != nil {
+        return err
+    }
+    ctx := context.Background()
+    for _, id := range []string{s.logFilterId, s.blockFilterId} {
+        var uninstalled bool
+        if err := s.h.client.Client().CallContext(ctx, &uninstalled, "eth_uninstallFilter", id); err != nil {
+            return fmt.Errorf("Failed to uninstall filter: %v", err)
+        }
+        if !uninstalled {
+            return fmt.Errorf("Filter %s was not uninstalled", id)
+        }
+        if err := expectFilterNotFound(s.h, "eth_getFilterChanges", id); err != nil {
+            return err
+        }
+        if err := s.h.client.Client().CallContext(ctx, &uninstalled, "eth_uninstallFilter", id); err != nil {
+            return fmt.Errorf("Failed to uninstall filter: %v", err)
+        }
+        if uninstalled {
+            return fmt.Errorf("Filter %s was uninstalled twice", id)
+        }
+    }
+    if err := expectFilterNotFound(s.h, "eth_getFilterLogs", s.logFilterId); err != nil {
+        return err
+    }
+    fmt.Printf("Uninstalled filters %s and %s\n", s.logFilterId, s.blockFilterId)
+    return nil
+}
+
+// testExpiredFilter waits for a filter to outlive the relay's FILTER_TTL.
+func (s *filterScenario) testExpiredFilter(filterTTL time.Duration) error {
+    if filterTTL == 0 {
+        return skipf("set --filter-ttl to the relay's FILTER_TTL to check filter expiry")
+    }
+    var id string
+    if err := s.h.client.Client().CallContext(context.Background(), &id, "eth_newBlockFilter"); err != nil {
+        return fmt.Errorf("Failed to create block filter: %v", err)
+    }
+    wait := filterTTL + 2*filterPollInterval
+    fmt.Printf("Waiting %s for filter %s to expire\n", wait, id)
+    time.Sleep(wait)
+    return expectFilterNotFound(s.h, "eth_getFilterChanges", id)
+}
+
+func expectFilterNotFound(h *harness, method string, id string) error {
+    var result interface{}
+    err := h.client.Client().CallContext(context.Background(), &result, method, id)
+    if err == nil {
+        return fmt.Errorf("Expected %s to reject filter %s, got %v", method, id, result)
+    }
+    return h.expectRelayError(err, filterNotFoundCode, filterNotFoundMessage)
+}
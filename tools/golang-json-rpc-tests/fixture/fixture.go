@@ -0,0 +1,379 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package fixture records the JSON-RPC traffic of a run to a file and
+// replays it in place of the network, so a run can be reproduced offline.
+//
+// A fixture holds one exchange per HTTP request: the calls sent, the HTTP
+// status of the answer and the response to each call, or the response to
+// the request as a whole when the relay rejected a batch outright. IDs are
+// not kept, so fixtures only change when a response does. GET requests,
+// such as mirror node lookups, are kept by path with the body returned.
+package fixture
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+)
+
+// Exchange is an HTTP request of one or more JSON-RPC calls together with
+// the relay's answer.
+type Exchange struct {
+    // Status is the HTTP status of the answer.
+    Status int `json:"status"`
+    // Get is the path and query of a GET request, which has no calls.
+    Get string `json:"get,omitempty"`
+    // Batch is set when the calls were sent as a batch.
+    Batch bool   `json:"batch,omitempty"`
+    Calls []Call `json:"calls,omitempty"`
+    // Response answers the request as a whole instead of call by call, as
+    // for a batch over the size limit or a GET request.
+    Response json.RawMessage `json:"response,omitempty"`
+}
+
+// Call is a JSON-RPC request and the response the relay returned for it,
+// without its ID.
+type Call struct {
+    Method   string          `json:"method"`
+    Params   json.RawMessage `json:"params,omitempty"`
+    Response json.RawMessage `json:"response,omitempty"`
+}
+
+// message is a JSON-RPC request or response.
+type message struct {
+    Id     json.RawMessage `json:"id,omitempty"`
+    Method string          `json:"method,omitempty"`
+    Params json.RawMessage `json:"params,omitempty"`
+}
+
+// parseMessages parses a single JSON-RPC message or a batch of them.
+func parseMessages(body []byte) ([]message, []json.RawMessage, bool, error) {
+    var raw []json.RawMessage
+    batch := true
+    if err := json.Unmarshal(body, &raw); err != nil {
+        batch = false
+        raw = []json.RawMessage{body}
+    }
+    messages := make([]message, len(raw))
+    for i, r := range raw {
+        if err := json.Unmarshal(r, &messages[i]); err != nil {
+            return nil, nil, false, err
+        }
+    }
+    return messages, raw, batch, nil
+}
+
+// withoutId removes the ID and version from a response.
+func withoutId(response json.RawMessage) (json.RawMessage, error) {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(response, &fields); err != nil {
+        return nil, err
+    }
+    delete(fields, "id")
+    delete(fields, "jsonrpc")
+    return json.Marshal(fields)
+}
+
+// withId gives a recorded response the ID of the request it answers.
+func withId(response json.RawMessage, id json.RawMessage) (json.RawMessage, error) {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(response, &fields); err != nil {
+        return nil, err
+    }
+    fields["jsonrpc"] = json.RawMessage(`"2.0"`)
+    fields["id"] = id
+    return json.Marshal(fields)
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+    if body == nil {
+        return nil, nil
+    }
+    defer body.Close()
+    return io.ReadAll(body)
+}
+
+// Recorder is an http.RoundTripper that records every exchange made over
+// it, to be saved as a fixture once the run is over.
+type Recorder struct {
+    transport http.RoundTripper
+
+    mu        sync.Mutex
+    exchanges []Exchange
+}
+
+// NewRecorder records the exchanges sent over transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+    return &Recorder{transport: transport}
+}
+
+func (f *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+    reqBody, err := readBody(req.Body)
+    if err != nil {
+        return nil, err
+    }
+    if req.Body != nil {
+        req.Body = io.NopCloser(bytes.NewReader(reqBody))
+    }
+    resp, err := f.transport.RoundTrip(req)
+    if err != nil {
+        return nil, err
+    }
+    respBody, err := readBody(resp.Body)
+    if err != nil {
+        return nil, err
+    }
+    resp.Body = io.NopCloser(bytes.NewReader(respBody))
+    if req.Method == http.MethodGet {
+        f.recordGet(req.URL.RequestURI(), resp.StatusCode, respBody)
+    } else {
+        f.record(reqBody, resp.StatusCode, respBody)
+    }
+    return resp, nil
+}
+
+// recordGet records the JSON body returned for a GET request.
+func (f *Recorder) recordGet(path string, status int, respBody []byte) {
+    if !json.Valid(respBody) {
+        return
+    }
+    var compact bytes.Buffer
+    if err := json.Compact(&compact, respBody); err != nil {
+        return
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.exchanges = append(f.exchanges, Exchange{Status: status, Get: path, Response: compact.Bytes()})
+}
+
+// record pairs the requests with the responses by ID. Exchanges without a
+// JSON answer, such as those rejected by a proxy, are not recorded.
+func (f *Recorder) record(reqBody []byte, status int, respBody []byte) {
+    requests, _, batch, err := parseMessages(reqBody)
+    if err != nil {
+        return
+    }
+    exchange := Exchange{Status: status, Batch: batch, Calls: make([]Call, len(requests))}
+    for i, request := range requests {
+        exchange.Calls[i] = Call{Method: request.Method, Params: request.Params}
+    }
+
+    responses, raw, batchResponse, err := parseMessages(respBody)
+    if err != nil {
+        return
+    }
+    if batch && !batchResponse {
+        response, err := withoutId(raw[0])
+        if err != nil {
+            return
+        }
+        exchange.Response = response
+    } else {
+        byId := map[string]json.RawMessage{}
+        for i, response := range responses {
+            byId[string(response.Id)] = raw[i]
+        }
+        for i, request := range requests {
+            response, ok := byId[string(request.Id)]
+            if !ok {
+                continue
+            }
+            if exchange.Calls[i].Response, err = withoutId(response); err != nil {
+                return
+            }
+        }
+    }
+
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.exchanges = append(f.exchanges, exchange)
+}
+
+// Save writes the recorded exchanges to path.
+func (f *Recorder) Save(path string) error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    data, err := json.MarshalIndent(f.exchanges, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Replayer is an http.RoundTripper that answers requests from a fixture
+// instead of the network. A request is answered by the next recorded
+// exchange with the same calls and params, with the recorded HTTP status.
+// A request that was not recorded, or more often than recorded, fails.
+type Replayer struct {
+    mu        sync.Mutex
+    exchanges []Exchange
+    used      []bool
+}
+
+// Load reads a fixture written by a Recorder.
+func Load(path string) (*Replayer, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read fixture: %v", err)
+    }
+    var exchanges []Exchange
+    if err := json.Unmarshal(data, &exchanges); err != nil {
+        return nil, fmt.Errorf("Failed to parse fixture: %v", err)
+    }
+    // Saving indents the params and GET bodies, while requests send them
+    // compact and responses are returned as recorded.
+    compact := func(raw json.RawMessage) (json.RawMessage, error) {
+        if len(raw) == 0 {
+            return raw, nil
+        }
+        var compact bytes.Buffer
+        if err := json.Compact(&compact, raw); err != nil {
+            return nil, fmt.Errorf("Failed to parse fixture: %v", err)
+        }
+        return compact.Bytes(), nil
+    }
+    for i, exchange := range exchanges {
+        if exchanges[i].Response, err = compact(exchange.Response); err != nil {
+            return nil, err
+        }
+        for j, call := range exchange.Calls {
+            if exchange.Calls[j].Params, err = compact(call.Params); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return &Replayer{exchanges: exchanges, used: make([]bool, len(exchanges))}, nil
+}
+
+// Gets reports whether the fixture recorded GET requests, so the run can
+// make the lookups it made when recording.
+func (f *Replayer) Gets() bool {
+    for _, exchange := range f.exchanges {
+        if exchange.Get != "" {
+            return true
+        }
+    }
+    return false
+}
+
+func (f *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+    reqBody, err := readBody(req.Body)
+    if err != nil {
+        return nil, err
+    }
+    var exchange Exchange
+    var respBody []byte
+    if req.Method == http.MethodGet {
+        path := req.URL.RequestURI()
+        exchange, err = f.take(func(exchange Exchange) bool { return exchange.Get == path }, "GET "+path)
+        if err != nil {
+            return nil, err
+        }
+        respBody = exchange.Response
+    } else {
+        requests, _, batch, err := parseMessages(reqBody)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to parse request: %v", err)
+        }
+        calls := make([]string, len(requests))
+        for i, request := range requests {
+            calls[i] = request.Method + " " + string(request.Params)
+        }
+        exchange, err = f.take(func(exchange Exchange) bool { return matches(exchange, requests, batch) }, strings.Join(calls, ", "))
+        if err != nil {
+            return nil, err
+        }
+        if respBody, err = respond(exchange, requests); err != nil {
+            return nil, err
+        }
+    }
+    return &http.Response{
+        Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+        StatusCode:    exchange.Status,
+        Proto:         "HTTP/1.1",
+        ProtoMajor:    1,
+        ProtoMinor:    1,
+        Header:        http.Header{"Content-Type": []string{"application/json"}},
+        Body:          io.NopCloser(bytes.NewReader(respBody)),
+        ContentLength: int64(len(respBody)),
+        Request:       req,
+    }, nil
+}
+
+// take takes the first unused exchange that matches the request described.
+func (f *Replayer) take(match func(Exchange) bool, request string) (Exchange, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for i, exchange := range f.exchanges {
+        if !f.used[i] && match(exchange) {
+            f.used[i] = true
+            return exchange, nil
+        }
+    }
+    return Exchange{}, fmt.Errorf("No recorded response to %s", request)
+}
+
+func matches(exchange Exchange, requests []message, batch bool) bool {
+    if exchange.Get != "" || exchange.Batch != batch || len(exchange.Calls) != len(requests) {
+        return false
+    }
+    for i, call := range exchange.Calls {
+        if call.Method != requests[i].Method || !bytes.Equal(call.Params, requests[i].Params) {
+            return false
+        }
+    }
+    return true
+}
+
+// respond builds the answer of a recorded exchange with the IDs of the
+// requests.
+func respond(exchange Exchange, requests []message) ([]byte, error) {
+    if exchange.Response != nil {
+        response, err := withId(exchange.Response, json.RawMessage("null"))
+        if err != nil {
+            return nil, err
+        }
+        return response, nil
+    }
+    responses := make([]json.RawMessage, 0, len(requests))
+    for i, call := range exchange.Calls {
+        if call.Response == nil {
+            continue
+        }
+        response, err := withId(call.Response, requests[i].Id)
+        if err != nil {
+            return nil, err
+        }
+        responses = append(responses, response)
+    }
+    if !exchange.Batch {
+        if len(responses) == 0 {
+            return nil, nil
+        }
+        return responses[0], nil
+    }
+    return json.Marshal(responses)
+}
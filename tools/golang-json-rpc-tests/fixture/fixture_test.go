@@ -0,0 +1,183 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package fixture
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+
+    "github.com/ethereum/go-ethereum/rpc"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+// batchLimit is the most calls the test relay answers in a batch.
+const batchLimit = 2
+
+type testMessage struct {
+    Jsonrpc string          `json:"jsonrpc"`
+    Id      json.RawMessage `json:"id"`
+    Method  string          `json:"method,omitempty"`
+    Result  interface{}     `json:"result,omitempty"`
+    Error   interface{}     `json:"error,omitempty"`
+}
+
+// testRelay answers eth_chainId, rejects any other method with an HTTP 400
+// like the relay, answers batches in reverse order and rejects batches over
+// batchLimit as a whole.
+func testRelay(t *testing.T) *httptest.Server {
+    answer := func(request testMessage) (testMessage, int) {
+        if request.Method == "eth_chainId" {
+            return testMessage{Jsonrpc: "2.0", Id: request.Id, Result: "0x12a"}, http.StatusOK
+        }
+        return testMessage{Jsonrpc: "2.0", Id: request.Id, Error: map[string]interface{}{"code": -32601, "message": "Method " + request.Method + " not found"}}, http.StatusBadRequest
+    }
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        if r.Method == http.MethodGet {
+            fmt.Fprintf(w, `{"account": "0.0.1002", "path": %q}`, r.URL.RequestURI())
+            return
+        }
+        body, err := io.ReadAll(r.Body)
+        require.NoError(t, err)
+        var batch []testMessage
+        if err := json.Unmarshal(body, &batch); err != nil {
+            var request testMessage
+            require.NoError(t, json.Unmarshal(body, &request))
+            response, status := answer(request)
+            w.WriteHeader(status)
+            require.NoError(t, json.NewEncoder(w).Encode(response))
+            return
+        }
+        if len(batch) > batchLimit {
+            w.WriteHeader(http.StatusBadRequest)
+            fmt.Fprint(w, `{"jsonrpc": "2.0", "id": null, "error": {"code": -32203, "message": "Batch request amount exceeds max"}}`)
+            return
+        }
+        responses := make([]testMessage, len(batch))
+        for i, request := range batch {
+            responses[len(batch)-1-i], _ = answer(request)
+        }
+        require.NoError(t, json.NewEncoder(w).Encode(responses))
+    }))
+}
+
+// exercise makes single, failing, batched and GET requests over transport
+// and describes what came back.
+func exercise(t *testing.T, url string, transport http.RoundTripper) []string {
+    client, err := rpc.DialOptions(context.Background(), url, rpc.WithHTTPClient(&http.Client{Transport: transport}))
+    require.NoError(t, err)
+    defer client.Close()
+    ctx := context.Background()
+    var outcomes []string
+    // Bodies are compared decoded, as replaying does not keep their layout.
+    decoded := func(body []byte) string {
+        var value interface{}
+        require.NoError(t, json.Unmarshal(body, &value))
+        return fmt.Sprint(value)
+    }
+    describe := func(result interface{}, err error) {
+        var httpErr rpc.HTTPError
+        if errors.As(err, &httpErr) {
+            outcomes = append(outcomes, fmt.Sprintf("%v %d %s", result, httpErr.StatusCode, decoded(httpErr.Body)))
+            return
+        }
+        outcomes = append(outcomes, fmt.Sprintf("%v %v", result, err))
+    }
+
+    var chainId string
+    describe(chainId, client.CallContext(ctx, &chainId, "eth_chainId"))
+    var unknown string
+    describe(unknown, client.CallContext(ctx, &unknown, "eth_mining"))
+
+    for _, size := range []int{batchLimit, batchLimit + 1} {
+        batch := make([]rpc.BatchElem, size)
+        for i := range batch {
+            batch[i] = rpc.BatchElem{Method: "eth_chainId", Result: new(string)}
+        }
+        batch[0].Method = "eth_mining"
+        err := client.BatchCallContext(ctx, batch)
+        describe(size, err)
+        for _, elem := range batch {
+            describe(*elem.Result.(*string), elem.Error)
+        }
+    }
+
+    res, err := (&http.Client{Transport: transport}).Get(url + "/api/v1/accounts/0x0000000000000000000000000000000000000001")
+    require.NoError(t, err)
+    body, err := io.ReadAll(res.Body)
+    require.NoError(t, err)
+    res.Body.Close()
+    outcomes = append(outcomes, fmt.Sprintf("%d %s", res.StatusCode, decoded(body)))
+    return outcomes
+}
+
+func TestRecordingRoundTrips(t *testing.T) {
+    relay := testRelay(t)
+    defer relay.Close()
+
+    recorder := NewRecorder(http.DefaultTransport)
+    recorded := exercise(t, relay.URL, recorder)
+    // The failing call and the rejected batch keep their HTTP status.
+    assert.Contains(t, recorded, " 400 map[error:map[code:-32601 message:Method eth_mining not found] id:2 jsonrpc:2.0]")
+    assert.Contains(t, recorded, "3 400 map[error:map[code:-32203 message:Batch request amount exceeds max] id:<nil> jsonrpc:2.0]")
+
+    path := filepath.Join(t.TempDir(), "fixture.json")
+    require.NoError(t, recorder.Save(path))
+    replayer, err := Load(path)
+    require.NoError(t, err)
+    assert.True(t, replayer.Gets())
+    assert.Equal(t, recorded, exercise(t, relay.URL, replayer))
+}
+
+func TestReplayFailsOnUnrecordedRequests(t *testing.T) {
+    relay := testRelay(t)
+    defer relay.Close()
+
+    recorder := NewRecorder(http.DefaultTransport)
+    client, err := rpc.DialOptions(context.Background(), relay.URL, rpc.WithHTTPClient(&http.Client{Transport: recorder}))
+    require.NoError(t, err)
+    var chainId string
+    require.NoError(t, client.CallContext(context.Background(), &chainId, "eth_chainId"))
+    client.Close()
+    path := filepath.Join(t.TempDir(), "fixture.json")
+    require.NoError(t, recorder.Save(path))
+
+    replayer, err := Load(path)
+    require.NoError(t, err)
+    client, err = rpc.DialOptions(context.Background(), relay.URL, rpc.WithHTTPClient(&http.Client{Transport: replayer}))
+    require.NoError(t, err)
+    defer client.Close()
+    require.NoError(t, client.CallContext(context.Background(), &chainId, "eth_chainId"))
+    assert.Equal(t, "0x12a", chainId)
+    // Each recorded response is replayed once.
+    err = client.CallContext(context.Background(), &chainId, "eth_chainId")
+    require.ErrorContains(t, err, "No recorded response to eth_chainId")
+    err = client.CallContext(context.Background(), &chainId, "eth_blockNumber")
+    require.ErrorContains(t, err, "No recorded response to eth_blockNumber")
+}
@@ -0,0 +1,402 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "math/big"
+    "strings"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+)
+
+// relayLogsBlockRangeLimit is the relay's default
+// ETH_GET_LOGS_BLOCK_RANGE_LIMIT.
+const relayLogsBlockRangeLimit = 1000
+
+// emittedLog is an event the Logs contract was made to emit, as the harness
+// expects it, with the block the transaction was mined in.
+type emittedLog struct {
+    address     common.Address
+    topics      []common.Hash
+    data        []byte
+    txHash      common.Hash
+    blockNumber uint64
+    blockHash   common.Hash
+}
+
+// logsMatrix emits a known set of events from two Logs contracts and
+// checks eth_getLogs returns exactly the ones each filter selects.
+type logsMatrix struct {
+    h          *harness
+    rangeLimit uint64
+    logs       *contract
+    addresses  []common.Address
+    emitted    []emittedLog
+}
+
+type topicFilter struct {
+    name   string
+    topics [][]common.Hash
+}
+
+type addressFilter struct {
+    name      string
+    addresses []common.Address
+}
+
+func registerLogsCases(r *runner, h *harness, rangeLimit uint64) {
+    m := &logsMatrix{h: h, rangeLimit: rangeLimit}
+    r.add("eth_getLogs (emit events)", m.emit)
+    r.add("eth_getLogs (topics, full range)", func() error {
+        if err := m.requireEvents(); err != nil {
+            return err
+        }
+        first, last := m.emitted[0], m.emitted[len(m.emitted)-1]
+        return m.checkMatrix(new(big.Int).SetUint64(first.blockNumber), new(big.Int).SetUint64(last.blockNumber), nil)
+    })
+    r.add("eth_getLogs (topics, partial range)", func() error {
+        if err := m.requireEvents(); err != nil {
+            return err
+        }
+        first, middle := m.emitted[0], m.emitted[len(m.emitted)/2]
+        return m.checkMatrix(new(big.Int).SetUint64(first.blockNumber), new(big.Int).SetUint64(middle.blockNumber), nil)
+    })
+    r.add("eth_getLogs (topics, block hash)", func() error {
+        if err := m.requireEvents(); err != nil {
+            return err
+        }
+        return m.checkMatrix(nil, nil, &m.emitted[1].blockHash)
+    })
+    r.add("eth_getLogs (range over limit)", func() error {
+        from, to, err := m.rangeOverLimit()
+        if err != nil {
+            return err
+        }
+        _, err = h.client.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: from, ToBlock: to, Addresses: m.addresses})
+        if err == nil {
+            return fmt.Errorf("Logs of %d addresses over %s blocks were returned, expected the range to be rejected", len(m.addresses), new(big.Int).Sub(to, from).String())
+        }
+        return h.expectRelayError(err, -32000, fmt.Sprintf("Exceeded maximum block range: %d", m.rangeLimit))
+    })
+    r.add("eth_getLogs (single address over limit)", func() error {
+        from, to, err := m.rangeOverLimit()
+        if err != nil {
+            return err
+        }
+        query := ethereum.FilterQuery{FromBlock: from, ToBlock: to, Addresses: m.addresses[:1]}
+        selected := 0
+        for _, e := range m.emitted {
+            if matchesQuery(e, query) {
+                selected++
+            }
+        }
+        if selected == 0 {
+            return fmt.Errorf("No emitted event of %s falls in blocks %s to %s", m.addresses[0].Hex(), from.String(), to.String())
+        }
+        return m.check(query)
+    })
+    r.add("eth_getLogs (invalid range)", func() error {
+        latest, err := h.client.BlockNumber(context.Background())
+        if err != nil {
+            return fmt.Errorf("Failed to get block number: %v", err)
+        }
+        if latest == 0 {
+            return skipf("chain has a single block")
+        }
+        query := ethereum.FilterQuery{FromBlock: new(big.Int).SetUint64(latest), ToBlock: new(big.Int).SetUint64(latest - 1)}
+        _, err = h.client.FilterLogs(context.Background(), query)
+        if err == nil {
+            return fmt.Errorf("Logs with fromBlock after toBlock were returned, expected an error")
+        }
+        return h.expectRelayError(err, -39013, "Invalid block range")
+    })
+}
+
+func (m *logsMatrix) requireEvents() error {
+    if len(m.emitted) == 0 {
+        return skipf("events were not emitted")
+    }
+    return nil
+}
+
+// emit deploys two Logs contracts and emits events with zero to three
+// indexed parameters from each, sharing topic values across events so
+// filters match more than one of them.
+func (m *logsMatrix) emit() error {
+    logs, err := loadContract("Logs")
+    if err != nil {
+        return err
+    }
+    m.logs = logs
+    var addresses []common.Address
+    for i := 0; i < 2; i++ {
+        address, _, err := logs.deploy(m.h)
+        if err != nil {
+            return err
+        }
+        addresses = append(addresses, address)
+    }
+
+    calls := []struct {
+        contract int
+        method   string
+        args     []int64
+    }{
+        {0, "log4", []int64{1, 2, 3, 10}},
+        {0, "log4", []int64{1, 2, 4, 11}},
+        {0, "log3", []int64{1, 5, 3}},
+        {0, "log2", []int64{2, 2}},
+        {0, "log1", []int64{1}},
+        {0, "log0", []int64{7}},
+        {1, "log4", []int64{1, 2, 3, 12}},
+        {1, "log1", []int64{2}},
+    }
+    var emitted []emittedLog
+    for _, call := range calls {
+        args := make([]interface{}, len(call.args))
+        for i, arg := range call.args {
+            args[i] = big.NewInt(arg)
+        }
+        receipt, err := logs.transact(m.h, addresses[call.contract], call.method, args...)
+        if err != nil {
+            return err
+        }
+        expected, err := m.expectedLog(call.method, call.args)
+        if err != nil {
+            return err
+        }
+        expected.address = addresses[call.contract]
+        expected.txHash = receipt.TxHash
+        expected.blockNumber = receipt.BlockNumber.Uint64()
+        expected.blockHash = receipt.BlockHash
+        emitted = append(emitted, expected)
+    }
+    m.addresses = addresses
+    m.emitted = emitted
+    fmt.Printf("Emitted %d events from %s and %s\n", len(emitted), addresses[0].Hex(), addresses[1].Hex())
+    return nil
+}
+
+// expectedLog encodes the event a Logs method emits: the event ID unless it
+// is anonymous, a topic for every indexed argument and the others as data.
+func (m *logsMatrix) expectedLog(method string, args []int64) (emittedLog, error) {
+    event, ok := m.logs.abi.Events[strings.ToUpper(method[:1])+method[1:]]
+    if !ok {
+        return emittedLog{}, fmt.Errorf("Logs has no event for %s", method)
+    }
+    var expected emittedLog
+    if !event.Anonymous {
+        expected.topics = append(expected.topics, event.ID)
+    }
+    for i, input := range event.Inputs {
+        word := common.BigToHash(big.NewInt(args[i]))
+        if input.Indexed {
+            expected.topics = append(expected.topics, word)
+        } else {
+            expected.data = append(expected.data, word.Bytes()...)
+        }
+    }
+    return expected, nil
+}
+
+func (m *logsMatrix) topicFilters() []topicFilter {
+    id := func(name string) common.Hash {
+        return m.logs.abi.Events[name].ID
+    }
+    n := func(value int64) common.Hash {
+        return common.BigToHash(big.NewInt(value))
+    }
+    return []topicFilter{
+        {"no topics", nil},
+        {"exact", [][]common.Hash{{id("Log4")}, {n(1)}, {n(2)}, {n(3)}}},
+        {"event only", [][]common.Hash{{id("Log4")}}},
+        {"wildcard event", [][]common.Hash{nil, {n(1)}}},
+        {"wildcard middle", [][]common.Hash{{id("Log4")}, nil, nil, {n(3)}}},
+        {"OR events", [][]common.Hash{{id("Log4"), id("Log3")}}},
+        {"OR values", [][]common.Hash{{id("Log4")}, {n(1)}, {n(2)}, {n(3), n(4)}}},
+        {"OR in every position", [][]common.Hash{{id("Log2"), id("Log3")}, {n(1), n(2)}, {n(2), n(5)}}},
+        {"no match", [][]common.Hash{{id("Log1")}, {n(99)}}},
+    }
+}
+
+func (m *logsMatrix) addressFilters() []addressFilter {
+    return []addressFilter{
+        {"first contract", m.addresses[:1]},
+        {"second contract", m.addresses[1:]},
+        {"both contracts", m.addresses},
+    }
+}
+
+// checkMatrix runs every topic and address filter over a block range, or a
+// block hash when one is given. Ranges the relay rejects for more than one
+// address are left to the range limit cases.
+func (m *logsMatrix) checkMatrix(from, to *big.Int, blockHash *common.Hash) error {
+    var diffs []string
+    overLimit := blockHash == nil && to.Uint64()-from.Uint64() > m.rangeLimit
+    for _, topics := range m.topicFilters() {
+        for _, addresses := range m.addressFilters() {
+            if overLimit && len(addresses.addresses) > 1 {
+                continue
+            }
+            query := ethereum.FilterQuery{
+                BlockHash: blockHash,
+                FromBlock: from,
+                ToBlock:   to,
+                Addresses: addresses.addresses,
+                Topics:    topics.topics,
+            }
+            queryDiffs, err := m.compare(query)
+            if err != nil {
+                return err
+            }
+            for _, diff := range queryDiffs {
+                diffs = append(diffs, fmt.Sprintf("%s, %s: %s", topics.name, addresses.name, diff))
+            }
+        }
+    }
+    if len(diffs) > 0 {
+        return fmt.Errorf("Logs do not match the emitted events:\n    %s", strings.Join(diffs, "\n    "))
+    }
+    return nil
+}
+
+func (m *logsMatrix) check(query ethereum.FilterQuery) error {
+    diffs, err := m.compare(query)
+    if err != nil {
+        return err
+    }
+    if len(diffs) > 0 {
+        return fmt.Errorf("Logs do not match the emitted events:\n    %s", strings.Join(diffs, "\n    "))
+    }
+    return nil
+}
+
+// compare runs a query and lists the logs that are missing, unexpected or
+// different from the emitted events it selects.
+func (m *logsMatrix) compare(query ethereum.FilterQuery) ([]string, error) {
+    logs, err := m.h.client.FilterLogs(context.Background(), query)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get logs: %v", err)
+    }
+    expected := map[common.Hash]emittedLog{}
+    for _, e := range m.emitted {
+        if matchesQuery(e, query) {
+            expected[e.txHash] = e
+        }
+    }
+
+    var diffs []string
+    returned := map[common.Hash]bool{}
+    for _, log := range logs {
+        e, ok := expected[log.TxHash]
+        if !ok {
+            diffs = append(diffs, fmt.Sprintf("unexpected log of %s in transaction %s", log.Address.Hex(), log.TxHash.Hex()))
+            continue
+        }
+        returned[log.TxHash] = true
+        if diff := logDiff(e, log); diff != "" {
+            diffs = append(diffs, fmt.Sprintf("log of transaction %s %s", log.TxHash.Hex(), diff))
+        }
+    }
+    for _, e := range m.emitted {
+        if _, ok := expected[e.txHash]; ok && !returned[e.txHash] {
+            diffs = append(diffs, fmt.Sprintf("missing log of transaction %s", e.txHash.Hex()))
+        }
+    }
+    return diffs, nil
+}
+
+// matchesQuery applies eth_getLogs filtering to an emitted event. Each topic
+// position matches any of its hashes, or anything when empty, and an event
+// with fewer topics than the filter never matches.
+func matchesQuery(e emittedLog, query ethereum.FilterQuery) bool {
+    if query.BlockHash != nil {
+        if e.blockHash != *query.BlockHash {
+            return false
+        }
+    } else {
+        if query.FromBlock != nil && e.blockNumber < query.FromBlock.Uint64() {
+            return false
+        }
+        if query.ToBlock != nil && e.blockNumber > query.ToBlock.Uint64() {
+            return false
+        }
+    }
+    if len(query.Addresses) > 0 {
+        found := false
+        for _, address := range query.Addresses {
+            found = found || address == e.address
+        }
+        if !found {
+            return false
+        }
+    }
+    if len(query.Topics) > len(e.topics) {
+        return false
+    }
+    for i, position := range query.Topics {
+        if len(position) == 0 {
+            continue
+        }
+        found := false
+        for _, topic := range position {
+            found = found || topic == e.topics[i]
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+func logDiff(e emittedLog, log types.Log) string {
+    switch {
+    case log.Address != e.address:
+        return fmt.Sprintf("has address %s, expected %s", log.Address.Hex(), e.address.Hex())
+    case log.BlockNumber != e.blockNumber || log.BlockHash != e.blockHash:
+        return fmt.Sprintf("is in block %d %s, expected %d %s", log.BlockNumber, log.BlockHash.Hex(), e.blockNumber, e.blockHash.Hex())
+    case fmt.Sprint(log.Topics) != fmt.Sprint(e.topics):
+        return fmt.Sprintf("has topics %v, expected %v", log.Topics, e.topics)
+    case !bytes.Equal(log.Data, e.data):
+        return fmt.Sprintf("has data %x, expected %x", log.Data, e.data)
+    }
+    return ""
+}
+
+// rangeOverLimit returns a range one block past the limit that ends at the
+// block of the last emitted event, so emitted events fall inside it,
+// skipping when the chain is not that long.
+func (m *logsMatrix) rangeOverLimit() (*big.Int, *big.Int, error) {
+    if err := m.requireEvents(); err != nil {
+        return nil, nil, err
+    }
+    last := m.emitted[len(m.emitted)-1].blockNumber
+    if last <= m.rangeLimit {
+        return nil, nil, skipf("events were emitted up to block %d, set --logs-range-limit below that to check the relay's limit", last)
+    }
+    return new(big.Int).SetUint64(last - m.rangeLimit - 1), new(big.Int).SetUint64(last), nil
+}
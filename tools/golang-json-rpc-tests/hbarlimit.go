@@ -0,0 +1,484 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bufio"
+    "context"
+    "crypto/ecdsa"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// Names of the relay settings read from an HBAR limit config.
+const (
+    hbarRateLimitBasic      = "HBAR_RATE_LIMIT_BASIC"
+    hbarRateLimitExtended   = "HBAR_RATE_LIMIT_EXTENDED"
+    hbarRateLimitPrivileged = "HBAR_RATE_LIMIT_PRIVILEGED"
+    hbarRateLimitTinybar    = "HBAR_RATE_LIMIT_TINYBAR"
+)
+
+const (
+    hbarLimitTransfer = "transfer"
+    hbarLimitDeploy   = "deploy"
+
+    // hbarPlanKeyOffset keeps the keys derived for spending plans apart from
+    // those of the load senders.
+    hbarPlanKeyOffset = 1 << 20
+
+    // The relay records what a transaction cost the operator once its
+    // record arrives, so the remaining budget is polled until it drops.
+    hbarExpensePoll    = 500 * time.Millisecond
+    hbarExpenseTimeout = 30 * time.Second
+)
+
+// relayHbarLimitDefaults are the relay's defaults, documented in
+// docs/configuration.md.
+var relayHbarLimitDefaults = map[string]string{
+    hbarRateLimitBasic:      "1120000000",
+    hbarRateLimitExtended:   "3200000000",
+    hbarRateLimitPrivileged: "8000000000",
+    hbarRateLimitTinybar:    "800000000000",
+}
+
+// tierSettings names the budget setting of every subscription tier a plan
+// can have.
+var tierSettings = map[string]string{
+    hedera.TierBasic:      hbarRateLimitBasic,
+    hedera.TierExtended:   hbarRateLimitExtended,
+    hedera.TierPrivileged: hbarRateLimitPrivileged,
+}
+
+type hbarLimitConfig struct {
+    kind    string
+    max     int
+    funding *big.Int
+    // metricsUrl serves the relay's Prometheus metrics, which hold the
+    // remaining total budget.
+    metricsUrl string
+    // limits is the budget in tinybars of every tier, and budget the total.
+    limits map[string]int64
+    budget int64
+}
+
+// loadHbarLimitConfig reads the HBAR budgets of the relay.
+func loadHbarLimitConfig(path string, kind string, max int, funding *big.Int, metricsUrl string) (hbarLimitConfig, error) {
+    config := hbarLimitConfig{kind: kind, max: max, funding: funding, metricsUrl: metricsUrl, limits: map[string]int64{}}
+    if kind != hbarLimitTransfer && kind != hbarLimitDeploy {
+        return config, fmt.Errorf("--hbar-limit-kind must be %s or %s", hbarLimitTransfer, hbarLimitDeploy)
+    }
+    if max < 1 {
+        return config, fmt.Errorf("--hbar-limit-max must be at least 1")
+    }
+    settings, err := readRelaySettings(path, relayHbarLimitDefaults)
+    if err != nil {
+        return config, err
+    }
+    for name, value := range settings {
+        tinybars, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64)
+        if err != nil || tinybars < 1 {
+            return config, fmt.Errorf("%s must be a positive number of tinybars, got %q", name, value)
+        }
+        if name == hbarRateLimitTinybar {
+            config.budget = tinybars
+        }
+        for tier, setting := range tierSettings {
+            if setting == name {
+                config.limits[tier] = tinybars
+            }
+        }
+    }
+    return config, nil
+}
+
+// loadSpendingPlans reads spending plans in the format of the relay's
+// HBAR_SPENDING_PLANS_CONFIG, checking them the way the relay does.
+func loadSpendingPlans(path string) ([]hedera.SpendingPlan, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read spending plans: %v", err)
+    }
+    var plans []hedera.SpendingPlan
+    if err := json.Unmarshal(data, &plans); err != nil {
+        return nil, fmt.Errorf("Failed to parse spending plans: %v", err)
+    }
+    for _, plan := range plans {
+        if _, ok := tierSettings[plan.SubscriptionTier]; !ok {
+            return nil, fmt.Errorf("Spending plan %q has subscription tier %q, expected BASIC, EXTENDED or PRIVILEGED", plan.Name, plan.SubscriptionTier)
+        }
+        if len(plan.EvmAddresses) == 0 && len(plan.IpAddresses) == 0 {
+            return nil, fmt.Errorf("Spending plan %q has neither EVM nor IP addresses", plan.Name)
+        }
+    }
+    return plans, nil
+}
+
+// derivePlanKeys derives a key for a sender without a plan, then one for
+// every EVM address of the plans in order, so the same operator key and
+// plans file always give the same addresses.
+func derivePlanKeys(operator *ecdsa.PrivateKey, plans []hedera.SpendingPlan) (*ecdsa.PrivateKey, [][]*ecdsa.PrivateKey, error) {
+    basic, err := deriveKey(operator, hbarPlanKeyOffset)
+    if err != nil {
+        return nil, nil, fmt.Errorf("Failed to derive key: %v", err)
+    }
+    index := hbarPlanKeyOffset + 1
+    keys := make([][]*ecdsa.PrivateKey, len(plans))
+    for i, plan := range plans {
+        for range plan.EvmAddresses {
+            key, err := deriveKey(operator, index)
+            if err != nil {
+                return nil, nil, fmt.Errorf("Failed to derive key: %v", err)
+            }
+            keys[i] = append(keys[i], key)
+            index++
+        }
+    }
+    return basic, keys, nil
+}
+
+// derivedPlans returns the plans with EVM addresses, with those replaced by
+// the addresses of the derived keys. IP addresses are left out: the relay
+// charges senders without a plan of their own to the plan of their IP, so
+// listing the IP of this client would merge the senders of a run.
+func derivedPlans(plans []hedera.SpendingPlan, keys [][]*ecdsa.PrivateKey) []hedera.SpendingPlan {
+    var derived []hedera.SpendingPlan
+    for i, plan := range plans {
+        if len(keys[i]) == 0 {
+            continue
+        }
+        plan.EvmAddresses = nil
+        plan.IpAddresses = nil
+        for _, key := range keys[i] {
+            plan.EvmAddresses = append(plan.EvmAddresses, crypto.PubkeyToAddress(key.PublicKey).Hex())
+        }
+        derived = append(derived, plan)
+    }
+    return derived
+}
+
+func writeSpendingPlans(path string, plans []hedera.SpendingPlan) error {
+    data, err := json.MarshalIndent(plans, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+        return fmt.Errorf("Failed to write spending plans: %v", err)
+    }
+    return nil
+}
+
+// metricsUrlOf returns the metrics endpoint on the host of the relay.
+func metricsUrlOf(endpointUrl string) string {
+    u, err := url.Parse(endpointUrl)
+    if err != nil {
+        return endpointUrl
+    }
+    return u.Scheme + "://" + u.Host + "/metrics"
+}
+
+// hbarPlanTarget is a plan whose budget is spent, and the keys of its EVM
+// addresses, which take turns sending.
+type hbarPlanTarget struct {
+    name string
+    tier string
+    keys []*ecdsa.PrivateKey
+}
+
+// hbarPlanResult is what spending the budget of a plan observed.
+type hbarPlanResult struct {
+    target   hbarPlanTarget
+    accepted int
+    // spent is the sum of the expenses of the accepted transactions, and
+    // last the expense of the last one, in tinybars.
+    spent int64
+    last  int64
+    // limited is set once a transaction was rejected with
+    // HBAR_RATE_LIMIT_EXCEEDED, when remaining was left of the total budget.
+    limited   bool
+    remaining int64
+    // unshared are addresses of the plan still served once it was limited.
+    unshared []common.Address
+}
+
+type hbarLimitReport struct {
+    results []hbarPlanResult
+    // skipped are the plans with only IP addresses, which cannot be told
+    // apart from this client.
+    skipped []string
+}
+
+type hbarLimitSession struct {
+    h        *harness
+    config   hbarLimitConfig
+    gasPrice *big.Int
+    bytecode []byte
+}
+
+// runHbarLimit spends the budget of a sender without a plan, which gets a
+// BASIC plan, then of every plan with EVM addresses, one transaction at a
+// time. The expense of every transaction is read from the drop of the
+// relay's remaining total budget, so nothing else may spend it meanwhile.
+func runHbarLimit(h *harness, plans []hedera.SpendingPlan, config hbarLimitConfig) (*hbarLimitReport, error) {
+    basic, keys, err := derivePlanKeys(h.privateKey, plans)
+    if err != nil {
+        return nil, err
+    }
+    report := &hbarLimitReport{}
+    targets := []hbarPlanTarget{{name: "address without a plan", tier: hedera.TierBasic, keys: []*ecdsa.PrivateKey{basic}}}
+    for i, plan := range plans {
+        if len(keys[i]) == 0 {
+            report.skipped = append(report.skipped, plan.Name)
+            continue
+        }
+        targets = append(targets, hbarPlanTarget{name: plan.Name, tier: plan.SubscriptionTier, keys: keys[i]})
+    }
+
+    var addresses []common.Address
+    for _, target := range targets {
+        for _, key := range target.keys {
+            addresses = append(addresses, crypto.PubkeyToAddress(key.PublicKey))
+        }
+    }
+    if err := fundAccounts(h, addresses, config.funding); err != nil {
+        return nil, err
+    }
+    fmt.Printf("Funded %d addresses with %s weibars each\n", len(addresses), config.funding.String())
+
+    s := &hbarLimitSession{h: h, config: config}
+    s.gasPrice, err = h.client.SuggestGasPrice(context.Background())
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    if config.kind == hbarLimitDeploy {
+        s.bytecode, err = sampleContractBytecode()
+        if err != nil {
+            return nil, err
+        }
+    }
+    for _, target := range targets {
+        result, err := s.spend(target)
+        if err != nil {
+            return nil, err
+        }
+        report.results = append(report.results, *result)
+    }
+    return report, nil
+}
+
+// spend sends transactions from the addresses of the target in turn until
+// one is limited, then checks every other address of the plan is limited
+// too, as they share its budget.
+func (s *hbarLimitSession) spend(target hbarPlanTarget) (*hbarPlanResult, error) {
+    result := &hbarPlanResult{target: target}
+    remaining, err := hbarRemaining(s.config.metricsUrl)
+    if err != nil {
+        return nil, err
+    }
+    for result.accepted < s.config.max {
+        err := s.send(target.keys[result.accepted%len(target.keys)])
+        if isHbarRateLimited(err) {
+            result.limited = true
+            result.remaining = remaining
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        next, err := waitForExpense(s.config.metricsUrl, remaining)
+        if err != nil {
+            return nil, err
+        }
+        result.accepted++
+        result.last = remaining - next
+        result.spent += result.last
+        remaining = next
+    }
+    fmt.Printf("%s (%s): %d transactions spent %d tinybars\n", target.name, target.tier, result.accepted, result.spent)
+    if !result.limited {
+        return result, nil
+    }
+
+    for i := 1; i < len(target.keys); i++ {
+        key := target.keys[(result.accepted+i)%len(target.keys)]
+        err := s.send(key)
+        if isHbarRateLimited(err) {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        result.unshared = append(result.unshared, crypto.PubkeyToAddress(key.PublicKey))
+        if _, err := waitForExpense(s.config.metricsUrl, remaining); err != nil {
+            return nil, err
+        }
+    }
+    return result, nil
+}
+
+// send sends a transaction from key and waits until it is mined.
+func (s *hbarLimitSession) send(key *ecdsa.PrivateKey) error {
+    ctx := context.Background()
+    address := crypto.PubkeyToAddress(key.PublicKey)
+    nonce, err := s.h.client.PendingNonceAt(ctx, address)
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    txData := &types.LegacyTx{
+        Nonce:    nonce,
+        GasPrice: s.gasPrice,
+        Gas:      21000,
+        To:       &address,
+        Value:    big.NewInt(hedera.WeibarsPerTinybar),
+    }
+    if s.config.kind == hbarLimitDeploy {
+        txData.To = nil
+        txData.Value = big.NewInt(0)
+        txData.Gas = contractDeployGas
+        txData.Data = s.bytecode
+    }
+    signedTx, err := types.SignNewTx(key, types.NewEIP155Signer(s.h.chainId), txData)
+    if err != nil {
+        return fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    if err := s.h.client.SendTransaction(ctx, signedTx); err != nil {
+        return err
+    }
+    _, err = waitForTransaction(s.h.client, signedTx)
+    return err
+}
+
+// isHbarRateLimited reports whether err is HBAR_RATE_LIMIT_EXCEEDED, which
+// the relay sends to a single request with HTTP status 400.
+func isHbarRateLimited(err error) bool {
+    rpcErr, ok := asRpcError(err)
+    return ok && rpcErr.Code == hedera.HbarRateLimitedCode
+}
+
+// hbarRemaining reads the remaining total budget in tinybars from the
+// relay's metrics.
+func hbarRemaining(metricsUrl string) (int64, error) {
+    resp, err := http.Get(metricsUrl)
+    if err != nil {
+        return 0, fmt.Errorf("Failed to get metrics: %v", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        body, _ := io.ReadAll(resp.Body)
+        return 0, fmt.Errorf("Failed to get metrics: %s %s", resp.Status, body)
+    }
+    scanner := bufio.NewScanner(resp.Body)
+    for scanner.Scan() {
+        value, ok := strings.CutPrefix(scanner.Text(), hedera.HbarRemainingMetric+" ")
+        if !ok {
+            continue
+        }
+        remaining, err := strconv.ParseFloat(value, 64)
+        if err != nil {
+            return 0, fmt.Errorf("Failed to parse %s: %v", hedera.HbarRemainingMetric, err)
+        }
+        return int64(remaining), nil
+    }
+    if err := scanner.Err(); err != nil {
+        return 0, fmt.Errorf("Failed to read metrics: %v", err)
+    }
+    return 0, fmt.Errorf("Metrics at %s have no %s, set --hbar-metrics to the relay's metrics", metricsUrl, hedera.HbarRemainingMetric)
+}
+
+// waitForExpense polls the remaining total budget until it drops below
+// previous.
+func waitForExpense(metricsUrl string, previous int64) (int64, error) {
+    deadline := time.Now().Add(hbarExpenseTimeout)
+    for {
+        remaining, err := hbarRemaining(metricsUrl)
+        if err != nil {
+            return 0, err
+        }
+        if remaining < previous {
+            return remaining, nil
+        }
+        if time.Now().After(deadline) {
+            return 0, fmt.Errorf("Remaining HBAR budget did not drop from %d within %s", previous, hbarExpenseTimeout)
+        }
+        time.Sleep(hbarExpensePoll)
+    }
+}
+
+// mismatches lists the plans that were not limited at their budget: the
+// transaction after the budget was spent must be rejected, and none before.
+func (r *hbarLimitReport) mismatches(config hbarLimitConfig) []string {
+    var mismatches []string
+    for _, result := range r.results {
+        name := fmt.Sprintf("%s (%s)", result.target.name, result.target.tier)
+        limit := config.limits[result.target.tier]
+        switch {
+        case !result.limited:
+            mismatches = append(mismatches, fmt.Sprintf("%s was not limited after %d transactions spending %d of its %d tinybars", name, result.accepted, result.spent, limit))
+        case result.remaining <= 0:
+            mismatches = append(mismatches, fmt.Sprintf("%s was limited by the total budget, raise %s", name, hbarRateLimitTinybar))
+        case result.spent < limit:
+            mismatches = append(mismatches, fmt.Sprintf("%s was limited after spending %d of its %d tinybars", name, result.spent, limit))
+        case result.spent-result.last >= limit:
+            mismatches = append(mismatches, fmt.Sprintf("%s was limited only after spending %d tinybars, its %d tinybars were spent a transaction earlier", name, result.spent, limit))
+        }
+        for _, address := range result.unshared {
+            mismatches = append(mismatches, fmt.Sprintf("%s was still served from %s once its budget was spent", name, address.Hex()))
+        }
+    }
+    return mismatches
+}
+
+func (r *hbarLimitReport) print(out io.Writer, config hbarLimitConfig) {
+    w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "\nPLAN\tTIER\tADDRESSES\tBUDGET\tTRANSACTIONS\tSPENT\tLIMITED")
+    for _, result := range r.results {
+        fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%t\n", result.target.name, result.target.tier, len(result.target.keys),
+            config.limits[result.target.tier], result.accepted, result.spent, result.limited)
+    }
+    w.Flush()
+    for _, name := range r.skipped {
+        fmt.Fprintf(out, "Skipped %q, which has only IP addresses\n", name)
+    }
+
+    mismatches := r.mismatches(config)
+    if len(mismatches) == 0 {
+        fmt.Fprintln(out, "\nHBAR limits as expected")
+        return
+    }
+    fmt.Fprintln(out)
+    for _, mismatch := range mismatches {
+        fmt.Fprintln(out, mismatch)
+    }
+}
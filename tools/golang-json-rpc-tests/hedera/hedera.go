@@ -0,0 +1,146 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package hedera holds the behaviour of the Hedera JSON RPC Relay that the
+// tests check and the mock relay reproduces, so the expectations are not
+// taken from the mock they run against.
+package hedera
+
+import (
+    "fmt"
+    "net/http"
+)
+
+// Chain IDs of the Hedera networks.
+const (
+    MainnetChainId    = 295
+    TestnetChainId    = 296
+    PreviewnetChainId = 297
+    LocalChainId      = 298
+)
+
+// WeibarsPerTinybar is the number of weibars in one tinybar, the smallest
+// HBAR denomination. Values handled by the relay are tinybar-granular.
+const WeibarsPerTinybar = 10_000_000_000
+
+// LatestBlockTolerance is the latestBlockTolerance of the relay's
+// eth_getBalance: a block this close to the head, or past it, is answered
+// with the latest balance.
+const LatestBlockTolerance = 1
+
+// Errors of the relay's validator for block parameters, as its
+// BLOCK_NUMBER_ERROR, BLOCK_HASH_ERROR and BLOCK_PARAMS_ERROR.
+const (
+    BlockNumberError = `Expected 0x prefixed hexadecimal block number, or the string "latest", "earliest" or "pending"`
+    BlockHashError   = "Expected 0x prefixed string representing the hash (32 bytes) of a block"
+    BlockParamsError = `Expected 0x prefixed string representing the hash (32 bytes) in object, 0x prefixed hexadecimal block number, or the string "latest", "earliest" or "pending"`
+)
+
+// BlockObjectMethods take an EIP-1898 block number or block hash object as
+// their block parameter, as well as a string. The relay validates the block
+// parameter of eth_getBalance, eth_getCode, eth_getStorageAt and
+// eth_getTransactionCount as a block number or block hash string, and
+// rejects any key of a block object besides blockNumber or blockHash.
+var BlockObjectMethods = map[string]bool{
+    "eth_call": true,
+}
+
+// HttpStatus is the HTTP status the relay answers a single request that
+// fails with the given JSON-RPC error code with, as its
+// RpcErrorCodeToStatusMap. Batches and WebSocket messages carry their errors
+// in a successful response instead.
+func HttpStatus(code int) int {
+    switch code {
+    case 3:
+        return http.StatusOK
+    case -32603:
+        return http.StatusInternalServerError
+    case -32015:
+        return http.StatusServiceUnavailable
+    case IpRateLimitedCode:
+        return http.StatusConflict
+    }
+    return http.StatusBadRequest
+}
+
+// IpRateLimitedCode is the error code of IP_RATE_LIMIT_EXCEEDED, the error
+// the relay answers a call over its per-IP rate limit with.
+const IpRateLimitedCode = -32605
+
+// IpRateLimitedMessage is the message of IP_RATE_LIMIT_EXCEEDED.
+func IpRateLimitedMessage(method string) string {
+    return fmt.Sprintf("IP Rate limit exceeded on %s", method)
+}
+
+// HbarRateLimitedCode and HbarRateLimitedMessage are the error of
+// HBAR_RATE_LIMIT_EXCEEDED, which the relay answers a transaction with once
+// the HBAR budget of its spending plan or the total budget is spent.
+const (
+    HbarRateLimitedCode    = -32606
+    HbarRateLimitedMessage = "HBAR Rate limit exceeded"
+)
+
+// HbarRemainingMetric is the relay's gauge of the remaining total budget in
+// tinybars.
+const HbarRemainingMetric = "rpc_relay_hbar_rate_remaining"
+
+// Subscription tiers of HBAR spending plans.
+const (
+    TierBasic      = "BASIC"
+    TierExtended   = "EXTENDED"
+    TierPrivileged = "PRIVILEGED"
+)
+
+// SpendingPlan is a preconfigured HBAR spending plan, an entry of the
+// relay's HBAR_SPENDING_PLANS_CONFIG.
+type SpendingPlan struct {
+    ID               string   `json:"id"`
+    Name             string   `json:"name"`
+    EvmAddresses     []string `json:"evmAddresses,omitempty"`
+    IpAddresses      []string `json:"ipAddresses,omitempty"`
+    SubscriptionTier string   `json:"subscriptionTier"`
+}
+
+// WsMethods are the methods the relay's WebSocket server serves, as its
+// WS_CONSTANTS.METHODS. It answers every other method with Method not
+// found.
+var WsMethods = map[string]bool{
+    "eth_blockNumber":           true,
+    "eth_call":                  true,
+    "eth_chainId":               true,
+    "eth_estimateGas":           true,
+    "eth_gasPrice":              true,
+    "eth_getBalance":            true,
+    "eth_getBlockByHash":        true,
+    "eth_getBlockByNumber":      true,
+    "eth_getCode":               true,
+    "eth_getLogs":               true,
+    "eth_getStorageAt":          true,
+    "eth_getTransactionByHash":  true,
+    "eth_getTransactionCount":   true,
+    "eth_getTransactionReceipt": true,
+    "eth_maxPriorityFeePerGas":  true,
+    "eth_newFilter":             true,
+    "eth_sendRawTransaction":    true,
+    "eth_subscribe":             true,
+    "eth_unsubscribe":           true,
+    "web3_clientVersion":        true,
+    "web3_sha3":                 true,
+}
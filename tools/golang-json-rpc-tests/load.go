@@ -0,0 +1,437 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "math/big"
+    "net/http"
+    "sort"
+    "sync"
+    "text/tabwriter"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/rpc"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const (
+    loadTransfer = "transfer"
+    loadCall     = "call"
+
+    // loadReceiptPoll is how often a sender polls for the receipt of its
+    // last transaction; bind.WaitMined polls once a second, which is too
+    // coarse to measure latency.
+    loadReceiptPoll    = 100 * time.Millisecond
+    loadReceiptTimeout = time.Minute
+)
+
+// Error groups for failures that are not JSON-RPC errors.
+const (
+    loadErrorTransport = "transport"
+    loadErrorTimeout   = "timeout"
+    loadErrorReverted  = "reverted"
+)
+
+type loadConfig struct {
+    senders  int
+    rate     float64
+    duration time.Duration
+    kind     string
+    // funding is the amount in weibars sent to each sender before the load
+    // starts.
+    funding *big.Int
+}
+
+func (c loadConfig) validate() error {
+    if c.senders < 1 {
+        return fmt.Errorf("--load-senders must be at least 1")
+    }
+    if c.rate <= 0 {
+        return fmt.Errorf("--load-rate must be positive")
+    }
+    if c.duration <= 0 {
+        return fmt.Errorf("--load-duration must be positive")
+    }
+    if c.kind != loadTransfer && c.kind != loadCall {
+        return fmt.Errorf("--load-kind must be %s or %s", loadTransfer, loadCall)
+    }
+    return nil
+}
+
+// hbarToWeibars converts an amount of HBAR to weibars, rounded to whole
+// tinybars.
+func hbarToWeibars(hbar float64) *big.Int {
+    tinybars := big.NewInt(int64(math.Round(hbar * 1e8)))
+    return tinybars.Mul(tinybars, big.NewInt(hedera.WeibarsPerTinybar))
+}
+
+// loadSender is an account derived from the operator key that sends its
+// transactions one after another.
+type loadSender struct {
+    privateKey *ecdsa.PrivateKey
+    address    common.Address
+    nonce      uint64
+}
+
+// deriveKey derives the key of the index-th load sender from the operator
+// key, so that repeated runs reuse the same funded accounts.
+func deriveKey(operator *ecdsa.PrivateKey, index int) (*ecdsa.PrivateKey, error) {
+    var seed [8]byte
+    binary.BigEndian.PutUint64(seed[:], uint64(index))
+    return crypto.ToECDSA(crypto.Keccak256(crypto.FromECDSA(operator), seed[:]))
+}
+
+// loadStats collects the outcome of every transaction of a load run.
+type loadStats struct {
+    mu         sync.Mutex
+    sent       int
+    confirmed  int
+    missed     int
+    submission []time.Duration
+    receipt    []time.Duration
+    errors     map[string]int
+    // messages holds the first message seen for each error group.
+    messages map[string]string
+}
+
+func newLoadStats() *loadStats {
+    return &loadStats{errors: map[string]int{}, messages: map[string]string{}}
+}
+
+func (s *loadStats) fail(group string, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.errors[group]++
+    if _, ok := s.messages[group]; !ok {
+        s.messages[group] = err.Error()
+    }
+}
+
+// errorGroup groups JSON-RPC errors by their code, including those the
+// relay answers with a non-200 status, such as its prechecks, the HBAR limit
+// and the IP rate limit.
+func errorGroup(err error) string {
+    var timeoutErr *loadTimeoutError
+    if rpcErr, ok := asRpcError(err); ok {
+        return fmt.Sprintf("%d", rpcErr.Code)
+    }
+    if errors.As(err, &timeoutErr) {
+        return loadErrorTimeout
+    }
+    return loadErrorTransport
+}
+
+// runLoad funds the senders and has them send transactions at the target
+// rate, spread evenly over time, until the duration has passed. A tick that
+// finds every sender still waiting for a receipt is counted as missed.
+func runLoad(endpointUrl string, h *harness, config loadConfig) (*loadStats, error) {
+    ctx := context.Background()
+    rpcClient, err := rpc.DialOptions(ctx, endpointUrl, rpc.WithHTTPClient(&http.Client{
+        Transport: &http.Transport{MaxIdleConnsPerHost: config.senders},
+    }))
+    if err != nil {
+        return nil, err
+    }
+    client := ethclient.NewClient(rpcClient)
+    defer client.Close()
+
+    senders, err := fundSenders(h, config)
+    if err != nil {
+        return nil, err
+    }
+    for _, sender := range senders {
+        sender.nonce, err = client.PendingNonceAt(ctx, sender.address)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to get transaction count: %v", err)
+        }
+    }
+
+    var target *common.Address
+    var logs *contract
+    if config.kind == loadCall {
+        logs, err = loadContract("Logs")
+        if err != nil {
+            return nil, err
+        }
+        address, _, err := logs.deploy(h)
+        if err != nil {
+            return nil, err
+        }
+        target = &address
+    }
+    gasPrice, err := client.SuggestGasPrice(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+
+    stats := newLoadStats()
+    ticks := make(chan int)
+    var wg sync.WaitGroup
+    for _, sender := range senders {
+        wg.Add(1)
+        go func(sender *loadSender) {
+            defer wg.Done()
+            for seq := range ticks {
+                txData := &types.AccessListTx{
+                    ChainID:  h.chainId,
+                    Nonce:    sender.nonce,
+                    GasPrice: gasPrice,
+                    Gas:      21000,
+                    To:       &sender.address,
+                    Value:    big.NewInt(hedera.WeibarsPerTinybar),
+                }
+                if logs != nil {
+                    txData.To = target
+                    txData.Value = big.NewInt(0)
+                    txData.Gas = contractCallGas
+                    txData.Data, _ = logs.abi.Pack("log1", big.NewInt(int64(seq)))
+                }
+                sendLoadTransaction(client, sender, txData, stats)
+            }
+        }(sender)
+    }
+
+    interval := time.Duration(float64(time.Second) / config.rate)
+    ticker := time.NewTicker(interval)
+    deadline := time.After(config.duration)
+    seq := 0
+loop:
+    for {
+        select {
+        case <-deadline:
+            break loop
+        case <-ticker.C:
+            select {
+            case ticks <- seq:
+            default:
+                stats.mu.Lock()
+                stats.missed++
+                stats.mu.Unlock()
+            }
+            seq++
+        }
+    }
+    ticker.Stop()
+    close(ticks)
+    wg.Wait()
+    return stats, nil
+}
+
+// fundSenders derives the senders and funds each of them from the operator
+// account.
+func fundSenders(h *harness, config loadConfig) ([]*loadSender, error) {
+    senders := make([]*loadSender, config.senders)
+    addresses := make([]common.Address, config.senders)
+    for i := range senders {
+        privateKey, err := deriveKey(h.privateKey, i)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to derive key of sender %d: %v", i, err)
+        }
+        senders[i] = &loadSender{privateKey: privateKey, address: crypto.PubkeyToAddress(privateKey.PublicKey)}
+        addresses[i] = senders[i].address
+    }
+    if err := fundAccounts(h, addresses, config.funding); err != nil {
+        return nil, err
+    }
+    fmt.Printf("Funded %d senders with %s weibars each\n", len(senders), config.funding.String())
+    return senders, nil
+}
+
+// fundAccounts sends amount from the operator account to every address,
+// waiting until every transfer is mined.
+func fundAccounts(h *harness, addresses []common.Address, amount *big.Int) error {
+    ctx := context.Background()
+    nonce, err := h.client.PendingNonceAt(ctx, h.fromAddress)
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    funding := make([]*types.Transaction, len(addresses))
+    for i, address := range addresses {
+        txData := dummyTransactionData(address, h.chainId, nonce+uint64(i), gasPrice)
+        txData.Value = amount
+        funding[i], err = signDummyTransaction(txData, h.privateKey)
+        if err != nil {
+            return err
+        }
+        if err := h.client.SendTransaction(ctx, funding[i]); err != nil {
+            return fmt.Errorf("Failed to fund %s: %v", address.Hex(), err)
+        }
+    }
+    for i, tx := range funding {
+        receipt, err := waitForTransaction(h.client, tx)
+        if err != nil {
+            return err
+        }
+        if receipt.Status != types.ReceiptStatusSuccessful {
+            return fmt.Errorf("Funding of %s failed", addresses[i].Hex())
+        }
+    }
+    return nil
+}
+
+// sendLoadTransaction signs and sends txData from sender and records how
+// long submission and the receipt took. The receipt latency is measured from
+// the start of submission.
+func sendLoadTransaction(client *ethclient.Client, sender *loadSender, txData *types.AccessListTx, stats *loadStats) {
+    ctx := context.Background()
+    signedTx, err := types.SignNewTx(sender.privateKey, types.NewEIP2930Signer(txData.ChainID), txData)
+    if err != nil {
+        stats.fail(loadErrorTransport, err)
+        return
+    }
+
+    start := time.Now()
+    err = client.SendTransaction(ctx, signedTx)
+    submitted := time.Since(start)
+    stats.mu.Lock()
+    stats.sent++
+    stats.mu.Unlock()
+    if err != nil {
+        stats.fail(errorGroup(err), err)
+        // The nonce may or may not have been used; ask the relay.
+        if nonce, err := client.PendingNonceAt(ctx, sender.address); err == nil {
+            sender.nonce = nonce
+        }
+        return
+    }
+    sender.nonce++
+
+    receipt, err := pollReceipt(ctx, client, signedTx.Hash())
+    if err != nil {
+        stats.fail(errorGroup(err), err)
+        return
+    }
+    received := time.Since(start)
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        stats.fail(loadErrorReverted, fmt.Errorf("Transaction %s failed", signedTx.Hash().Hex()))
+        return
+    }
+
+    stats.mu.Lock()
+    defer stats.mu.Unlock()
+    stats.confirmed++
+    stats.submission = append(stats.submission, submitted)
+    stats.receipt = append(stats.receipt, received)
+}
+
+func pollReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash) (*types.Receipt, error) {
+    ctx, cancel := context.WithTimeout(ctx, loadReceiptTimeout)
+    defer cancel()
+    ticker := time.NewTicker(loadReceiptPoll)
+    defer ticker.Stop()
+    for {
+        receipt, err := client.TransactionReceipt(ctx, hash)
+        if err == nil {
+            return receipt, nil
+        }
+        if !errors.Is(err, ethereum.NotFound) {
+            return nil, err
+        }
+        select {
+        case <-ctx.Done():
+            return nil, &loadTimeoutError{hash: hash}
+        case <-ticker.C:
+        }
+    }
+}
+
+type loadTimeoutError struct {
+    hash common.Hash
+}
+
+func (e *loadTimeoutError) Error() string {
+    return fmt.Sprintf("No receipt for %s after %s", e.hash.Hex(), loadReceiptTimeout)
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := int(p/100*float64(len(sorted))+0.5) - 1
+    if rank < 0 {
+        rank = 0
+    }
+    if rank >= len(sorted) {
+        rank = len(sorted) - 1
+    }
+    return sorted[rank]
+}
+
+func (s *loadStats) print(out io.Writer, config loadConfig) {
+    fmt.Fprintf(out, "\n%d senders, %s for %s at %.1f tx/s\n", config.senders, config.kind, config.duration, config.rate)
+    fmt.Fprintf(out, "%d sent (%.1f tx/s), %d confirmed, %d ticks missed with every sender busy\n",
+        s.sent, float64(s.sent)/config.duration.Seconds(), s.confirmed, s.missed)
+
+    w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "\nLATENCY\tP50\tP95\tP99")
+    for _, latency := range []struct {
+        name      string
+        durations []time.Duration
+    }{
+        {"submission", s.submission},
+        {"receipt", s.receipt},
+    } {
+        sort.Slice(latency.durations, func(i, j int) bool { return latency.durations[i] < latency.durations[j] })
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", latency.name,
+            percentile(latency.durations, 50).Round(time.Millisecond),
+            percentile(latency.durations, 95).Round(time.Millisecond),
+            percentile(latency.durations, 99).Round(time.Millisecond))
+    }
+    w.Flush()
+
+    if len(s.errors) == 0 {
+        fmt.Fprintln(out, "\nNo errors")
+        return
+    }
+    groups := make([]string, 0, len(s.errors))
+    for group := range s.errors {
+        groups = append(groups, group)
+    }
+    sort.Strings(groups)
+    w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "\nERROR\tCOUNT\tRATE\tFIRST MESSAGE")
+    for _, group := range groups {
+        rate := 0.0
+        if s.sent > 0 {
+            rate = 100 * float64(s.errors[group]) / float64(s.sent)
+        }
+        fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", group, s.errors[group], rate, s.messages[group])
+    }
+    w.Flush()
+}
@@ -24,20 +24,29 @@ import (
     "context"
     "crypto/ecdsa"
     "encoding/hex"
+    "encoding/json"
     "flag"
     "fmt"
     "log"
     "math/big"
+    "net/http"
     "os"
+    "regexp"
     "strings"
+    "time"
 
     "github.com/ethereum/go-ethereum"
     "github.com/ethereum/go-ethereum/accounts/abi/bind"
     "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
     "github.com/ethereum/go-ethereum/core/types"
     "github.com/ethereum/go-ethereum/crypto"
     "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/rpc"
     "github.com/joho/godotenv"
+    "hedera-json-rpc-golang-tests-project/fixture"
+    "hedera-json-rpc-golang-tests-project/hedera"
+    "hedera-json-rpc-golang-tests-project/mockrelay"
 )
 
 const (
@@ -47,38 +56,250 @@ const (
 )
 
 func main() {
-    err := godotenv.Load()
-    if err != nil {
-        log.Fatalf("Error loading .env file")
-    }
     mainnet := flag.Bool("mainnet", false, "Use mainnet network")
     previewnet := flag.Bool("previewnet", false, "Use previewnet network")
     testnet := flag.Bool("testnet", false, "Use testnet network")
     wss := flag.Bool("wss", false, "Enable WebSocket Secure protocol")
-    privateKeyHex := os.Getenv("OPERATOR_PRIVATE_KEY")
+    mock := flag.Bool("mock", false, "Run against an in-process mock relay instead of a live network")
+    mockServe := flag.String("mock-serve", "", "Only serve a mock relay on the given address, e.g. localhost:7546")
+    openrpcPath := flag.String("openrpc", "", "Validate every method of the given OpenRPC document, e.g. ../../docs/openrpc.json, instead of running the regular cases")
+    openrpcParamsPath := flag.String("openrpc-params", "", "JSON file mapping method names to the params to call them with in --openrpc mode")
+    filterTTL := flag.Duration("filter-ttl", 0, "Filter TTL of the relay (FILTER_TTL); when set, filter expiry is checked by waiting this long")
+    load := flag.Bool("load", false, "Send transactions from concurrent senders and report latencies and errors instead of running the cases")
+    loadSenders := flag.Int("load-senders", 10, "Number of senders in --load mode, each with a key derived from the operator key")
+    loadRate := flag.Float64("load-rate", 10, "Target transactions per second across all senders in --load mode")
+    loadDuration := flag.Duration("load-duration", 30*time.Second, "How long to send transactions in --load mode")
+    loadKind := flag.String("load-kind", loadTransfer, "Transactions to send in --load mode: transfer, or call for calls to the Logs contract")
+    loadFunding := flag.Float64("load-funding", 10, "HBAR the operator sends to each sender before --load mode starts")
+    diff := flag.Bool("diff", false, "Replay transactions and calls on go-ethereum's simulated backend and report where the relay differs, instead of running the regular cases")
+    diffAllow := flag.String("diff-allow", "", "Comma-separated fields, e.g. receipt.logsBloom, whose differences --diff mode reports without failing, besides the known Hedera deviations")
+    recordPath := flag.String("record", "", "Record every JSON-RPC call and response to the given fixture file")
+    replayPath := flag.String("replay", "", "Answer JSON-RPC calls from the given fixture file instead of the network")
+    receiptBlocks := flag.String("receipt-blocks", "", "Comma-separated block numbers whose receipts are verified, besides the blocks of the write cases")
+    blockRangeFlag := flag.String("block-range", "", "Inclusive range of blocks, e.g. 1000-1100, whose consistency is checked besides the blocks of the write cases")
+    feeHistoryMax := flag.Uint64("fee-history-max", relayFeeHistoryMaxResults, "FEE_HISTORY_MAX_RESULTS of the relay, the most blocks eth_feeHistory returns")
+    logsRangeLimit := flag.Uint64("logs-range-limit", relayLogsBlockRangeLimit, "ETH_GET_LOGS_BLOCK_RANGE_LIMIT of the relay, the most blocks eth_getLogs spans for more than one address")
+    mirrorNode := flag.String("mirror-node", "", "Mirror node URL to look up entity IDs, defaults to MIRROR_NODE_URL or the network's public mirror node")
+    rateLimit := flag.Bool("rate-limit", false, "Call one method until the relay's IP rate limit trips, wait for it to recover and compare the observed limit with the expected one, instead of running the cases")
+    rateLimitMethod := flag.String("rate-limit-method", "eth_chainId", "Method to call in --rate-limit mode")
+    rateLimitParams := flag.String("rate-limit-params", "[]", "JSON array of params to call the method with in --rate-limit mode")
+    rateLimitWorkers := flag.Int("rate-limit-workers", 10, "Number of concurrent callers in --rate-limit mode")
+    rateLimitConfigPath := flag.String("rate-limit-config", "", "The relay's .env file, or one with its TIER_*_RATE_LIMIT, DEFAULT_RATE_LIMIT, LIMIT_DURATION and RATE_LIMIT_DISABLED, giving the limits expected in --rate-limit mode; the relay's defaults otherwise")
+    hbarPlans := flag.String("hbar-plans", "", "Spending plans in the format of spendingPlansConfig.example.json, whose EVM addresses are replaced by keys derived from the operator key")
+    hbarPlansOut := flag.String("hbar-plans-out", "", "Write the --hbar-plans plans with EVM addresses to the given file, with the derived addresses and without IP addresses, for the relay's HBAR_SPENDING_PLANS_CONFIG, and exit")
+    hbarLimit := flag.Bool("hbar-limit", false, "Send transactions from a new address and from the addresses of every --hbar-plans plan until their HBAR budgets are spent and check where each is limited, instead of running the cases")
+    hbarLimitConfigPath := flag.String("hbar-limit-config", "", "The relay's .env file, or one with its HBAR_RATE_LIMIT_BASIC, HBAR_RATE_LIMIT_EXTENDED, HBAR_RATE_LIMIT_PRIVILEGED and HBAR_RATE_LIMIT_TINYBAR, giving the budgets expected in --hbar-limit mode; the relay's defaults otherwise")
+    hbarLimitKind := flag.String("hbar-limit-kind", hbarLimitTransfer, "Transactions to send in --hbar-limit mode: transfer, or deploy for deployments of the sample contract")
+    hbarLimitMax := flag.Int("hbar-limit-max", 1000, "Most transactions sent for each plan in --hbar-limit mode")
+    hbarLimitFunding := flag.Float64("hbar-limit-funding", 100, "HBAR the operator sends to each address before --hbar-limit mode starts")
+    hbarMetrics := flag.String("hbar-metrics", "", "Metrics URL of the relay, read for the remaining HBAR budget in --hbar-limit mode; defaults to /metrics on the relay's host")
+    planPath := flag.String("plan", "", "JSON test plan selecting the scenarios, tags and methods to run, e.g. plans/local.json")
+    var reports reportFlags
+    flag.Var(&reports, "report", "Write a report as format=path, where format is junit or json (repeatable)")
 
     flag.Parse()
+    loadConf := loadConfig{
+        senders:  *loadSenders,
+        rate:     *loadRate,
+        duration: *loadDuration,
+        kind:     *loadKind,
+        funding:  hbarToWeibars(*loadFunding),
+    }
+    if *logsRangeLimit == 0 {
+        log.Fatal("--logs-range-limit must be positive")
+    }
+    if *feeHistoryMax == 0 {
+        log.Fatal("--fee-history-max must be positive")
+    }
+    if *load {
+        if err := loadConf.validate(); err != nil {
+            log.Fatal(err)
+        }
+    }
+    if (*recordPath != "" || *replayPath != "") && (*wss || *load || *rateLimit || *hbarLimit) {
+        log.Fatal("--record and --replay only cover HTTP calls of the cases, not --wss, --load, --rate-limit or --hbar-limit")
+    }
+    if *rateLimit && *wss {
+        log.Fatal("--rate-limit checks the limits of the HTTP server, not --wss")
+    }
+    useMock := *mock || *mockServe != ""
+    err := godotenv.Load()
+    if err != nil && !useMock && *replayPath == "" {
+        log.Fatalf("Error loading .env file")
+    }
+    privateKeyHex := os.Getenv("OPERATOR_PRIVATE_KEY")
+
+    receiptBlockNumbers, err := parseBlockNumbers(*receiptBlocks)
+    if err != nil {
+        log.Fatal(err)
+    }
+    extraBlocks, err := parseBlockRange(*blockRangeFlag)
+    if err != nil {
+        log.Fatal(err)
+    }
+    var rateConf rateLimitConfig
+    if *rateLimit {
+        rateConf, err = loadRateLimitConfig(*rateLimitConfigPath, *rateLimitMethod, *rateLimitParams, *rateLimitWorkers)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    var spendingPlans []hedera.SpendingPlan
+    if *hbarPlans != "" {
+        spendingPlans, err = loadSpendingPlans(*hbarPlans)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    var hbarConf hbarLimitConfig
+    if *hbarLimit {
+        hbarConf, err = loadHbarLimitConfig(*hbarLimitConfigPath, *hbarLimitKind, *hbarLimitMax, hbarToWeibars(*hbarLimitFunding), *hbarMetrics)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    plan := &testPlan{Name: "default"}
+    if *planPath != "" {
+        plan, err = loadPlan(*planPath)
+        if err != nil {
+            log.Fatal(err)
+        }
+        plan.applyArtifacts()
+    }
+
     var endpointUrl string
+    var mirrorUrl string
+    var mockChainId int64
+    var network string
     switch {
     case *mainnet:
         endpointUrl = mainnetEndpoint
+        mirrorUrl = mainnetMirrorNode
+        mockChainId = hedera.MainnetChainId
+        network = "mainnet"
     case *previewnet:
         endpointUrl = previewnetEndpoint
+        mirrorUrl = previewnetMirrorNode
+        mockChainId = hedera.PreviewnetChainId
+        network = "previewnet"
     case *testnet:
         endpointUrl = testnetEndpoint
+        mirrorUrl = testnetMirrorNode
+        mockChainId = hedera.TestnetChainId
+        network = "testnet"
     default:
         endpointUrl = os.Getenv("RELAY_ENDPOINT")
+        mirrorUrl = localMirrorNode
+        mockChainId = hedera.LocalChainId
+        network = "local"
+    }
+    if url := os.Getenv("MIRROR_NODE_URL"); url != "" {
+        mirrorUrl = url
+    }
+    if *mirrorNode != "" {
+        mirrorUrl = *mirrorNode
+    }
+
+    privateKey, err := operatorKey(privateKeyHex, useMock)
+    if err != nil {
+        log.Fatalf("Failed to parse private key: %v", err)
+    }
+    if privateKeyHex == "" && *recordPath != "" {
+        // Replaying needs the same key to send the same transactions.
+        fmt.Printf("Operator private key: %s\n", hexutil.Encode(crypto.FromECDSA(privateKey)))
+    }
+    publicKey := privateKey.Public().(*ecdsa.PublicKey)
+    fromAddress := crypto.PubkeyToAddress(*publicKey)
+    _, planKeys, err := derivePlanKeys(privateKey, spendingPlans)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if *hbarPlansOut != "" {
+        derived := derivedPlans(spendingPlans, planKeys)
+        if err := writeSpendingPlans(*hbarPlansOut, derived); err != nil {
+            log.Fatal(err)
+        }
+        fmt.Printf("Wrote %d spending plans to %s\n", len(derived), *hbarPlansOut)
+        return
+    }
+
+    mockConfig := mockrelay.Config{
+        ChainId:             mockChainId,
+        FilterTTL:           *filterTTL,
+        LogsBlockRangeLimit: *logsRangeLimit,
+    }
+    if *rateLimit {
+        mockConfig.RateLimits = rateConf.mockRateLimits()
+        mockConfig.LimitDuration = rateConf.window
+    }
+    if *hbarLimit {
+        mockConfig.SpendingPlans = derivedPlans(spendingPlans, planKeys)
+        mockConfig.HbarLimits = hbarConf.limits
+        mockConfig.HbarBudget = hbarConf.budget
+    }
+    if *mockServe != "" {
+        serveMockRelay(*mockServe, mockConfig, privateKey)
+        return
+    }
+    var relay *mockrelay.Relay
+    if *mock {
+        relay = startMockRelay("127.0.0.1:0", mockConfig, fromAddress)
+        endpointUrl = relay.URL()
+        // The mock serves the mirror node lookups itself.
+        mirrorUrl = relay.URL()
+    }
+    if *rateLimit {
+        report, err := runRateLimit(endpointUrl, rateConf)
+        if relay != nil {
+            relay.Close()
+        }
+        if err != nil {
+            log.Fatal(err)
+        }
+        report.print(os.Stdout, rateConf)
+        if len(report.mismatches(rateConf)) > 0 {
+            os.Exit(1)
+        }
+        return
     }
+    if hbarConf.metricsUrl == "" {
+        hbarConf.metricsUrl = metricsUrlOf(endpointUrl)
+    }
+
     if *wss {
         endpointUrl = strings.Replace(endpointUrl, "http://", "ws://", 1)
         endpointUrl = strings.Replace(endpointUrl, "https://", "wss://", 1)
         endpointUrl = strings.Replace(endpointUrl, "/api", "/ws", 1)
         endpointUrl = strings.Replace(endpointUrl, ":7546", ":8546", 1)
     }
-    client, err := ethclient.Dial(endpointUrl)
+    capture := newRpcCapture()
+    var recorder *fixture.Recorder
+    if *recordPath != "" {
+        recorder = fixture.NewRecorder(capture.transport)
+        capture.transport = recorder
+    }
+    if *replayPath != "" {
+        replayer, err := fixture.Load(*replayPath)
+        if err != nil {
+            log.Fatal(err)
+        }
+        capture.transport = replayer
+        // Mirror node lookups are answered from the fixture, if it has any.
+        mirrorUrl = ""
+        if replayer.Gets() {
+            // The mirror node is never contacted.
+            mirrorUrl = localMirrorNode
+        }
+        if endpointUrl == "" {
+            // The endpoint is never contacted.
+            endpointUrl = "http://localhost:7546"
+        }
+    }
+    rpcClient, err := rpc.DialOptions(context.Background(), endpointUrl, rpc.WithHTTPClient(&http.Client{Transport: capture}))
     if err != nil {
         log.Fatal(err)
     }
+    client := ethclient.NewClient(rpcClient)
     fmt.Println("Connected to Ethereum client")
 
     chainId, err := client.ChainID(context.Background())
@@ -86,92 +307,415 @@ func main() {
         log.Fatalf("Failed to get chain ID: %v", err)
     }
     fmt.Printf("Chain ID: %s\n", chainId.String())
-    privateKeyHex = strings.TrimPrefix(privateKeyHex, "0x")
-    privateKey, err := crypto.HexToECDSA(privateKeyHex)
-    if err != nil {
-        log.Fatalf("Failed to parse private key: %v", err)
+    h := &harness{
+        client:      client,
+        chainId:     chainId,
+        privateKey:  privateKey,
+        fromAddress: fromAddress,
+        ws:          *wss,
+        mirror:      capture.transport,
+        endpoint:    endpointUrl,
+        transport:   capture,
     }
-    publicKey := privateKey.Public().(*ecdsa.PublicKey)
-    fromAddress := crypto.PubkeyToAddress(*publicKey)
-    signedTx := testSendDummyTransaction(client, fromAddress, privateKey, chainId)
-    receipt := waitForTransaction(client, signedTx)
-    blockNumber := receipt.BlockNumber
-    txIndex := receipt.TransactionIndex
-    blockHash := receipt.BlockHash
-
-    signedContractTx, contractAddress := testSendContractCreationTransaction(client, fromAddress, privateKey, chainId)
-    waitForTransaction(client, signedContractTx)
-    testBlockByNumber(client, blockNumber)
-    testTransactionReceipt(client, signedTx.Hash().Hex())
-    testGetBalance(client, fromAddress)
-    testEthCall(client, fromAddress)
-    testEstimateGas(client, fromAddress)
-    testGetGasPrice(client)
-    testBlockByHash(client, blockHash)
-    testCodeAt(client, contractAddress)
-    testGetLogs(client, contractAddress, nil)
-    testStorageAt(client, contractAddress, "0x0")
-    testGetTransactionByHash(client, signedTx.Hash().Hex())
-    testGetTransactionReceipt(client, signedTx.Hash().Hex())
-    if *wss {
-        return;
+
+    if *load {
+        stats, err := runLoad(endpointUrl, h, loadConf)
+        if relay != nil {
+            relay.Close()
+        }
+        if err != nil {
+            log.Fatal(err)
+        }
+        stats.print(os.Stdout, loadConf)
+        return
     }
+    if *hbarLimit {
+        report, err := runHbarLimit(h, spendingPlans, hbarConf)
+        if relay != nil {
+            relay.Close()
+        }
+        if err != nil {
+            log.Fatal(err)
+        }
+        report.print(os.Stdout, hbarConf)
+        if len(report.mismatches(hbarConf)) > 0 {
+            os.Exit(1)
+        }
+        return
+    }
+
+    r := newRunner(capture)
+    var reference *diffSession
+    if *openrpcPath != "" {
+        registerWriteCases(r, h)
+        doc, err := loadOpenRpcDocument(*openrpcPath)
+        if err != nil {
+            log.Fatal(err)
+        }
+        params, err := loadOpenRpcParams(*openrpcParamsPath)
+        if err != nil {
+            log.Fatal(err)
+        }
+        registerOpenRpcCases(r, h, doc, params, *wss)
+    } else if *diff {
+        registerWriteCases(r, h)
+        var allow []string
+        if *diffAllow != "" {
+            allow = strings.Split(*diffAllow, ",")
+        }
+        reference = registerDiffCases(r, h, allow)
+    } else {
+        transport := tagHttp
+        if *wss {
+            transport = tagWs
+        }
+        selected, err := plan.selectScenarios(planScenarios(h, *filterTTL, endpointUrl, receiptBlockNumbers, extraBlocks, *feeHistoryMax, *logsRangeLimit, mirrorUrl), transport)
+        if err != nil {
+            log.Fatal(err)
+        }
+        fmt.Printf("Test plan: %s\n", plan.Name)
+        r.methods = plan.methodFilter()
+        if expected, ok := plan.Networks[network]; ok {
+            registerNetworkCases(r, h, expected)
+        }
+        for _, s := range selected {
+            s.register(r)
+        }
+    }
+    r.run()
+    if reference != nil {
+        reference.close()
+    }
+    if recorder != nil {
+        if err := recorder.Save(*recordPath); err != nil {
+            log.Fatalf("Failed to save fixture: %v", err)
+        }
+    }
+    r.printSummary(os.Stdout)
+    info := runInfo{endpoint: endpointUrl, chainId: chainId.String()}
+    if err := writeReports(reports, info, r); err != nil {
+        log.Fatal(err)
+    }
+    if relay != nil {
+        relay.Close()
+    }
+    if r.failed() {
+        os.Exit(1)
+    }
+}
+
+// harness holds the connection shared by every test case together with the
+// state produced by the write cases, which later read cases depend on.
+type harness struct {
+    client      *ethclient.Client
+    chainId     *big.Int
+    privateKey  *ecdsa.PrivateKey
+    fromAddress common.Address
+    // ws is set when calls go over WebSocket instead of HTTP.
+    ws bool
+    // mirror carries the mirror node lookups, so fixtures record them.
+    mirror http.RoundTripper
+    // endpoint and transport send raw requests, which the client cannot
+    // build, the way the client's own requests are sent.
+    endpoint  string
+    transport http.RoundTripper
+
+    transferTx      *types.Transaction
+    transferReceipt *types.Receipt
+    contractTx      *types.Transaction
+    contractReceipt *types.Receipt
+    contractAddress common.Address
+}
+
+func (h *harness) requireTransfer() error {
+    if h.transferReceipt == nil {
+        return skipf("dummy transaction was not mined")
+    }
+    return nil
+}
+
+func (h *harness) requireContract() error {
+    if h.contractTx == nil {
+        return skipf("contract was not deployed")
+    }
+    return nil
+}
+
+// planScenarios lists every scenario a test plan can select, in the order
+// they run. The write scenario comes first since most read cases check the
+// transactions it submits. Only scenarios whose methods the relay's
+// WebSocket server serves are tagged ws.
+func planScenarios(h *harness, filterTTL time.Duration, endpointUrl string, receiptBlocks []*big.Int, blocks *blockRange, feeHistoryMax uint64, logsRangeLimit uint64, mirrorUrl string) []scenario {
+    return []scenario{
+        {name: "write", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerWriteCases(r, h) }},
+        {name: "common", tags: []string{tagHttp, tagWs, tagRead}, register: func(r *runner) { registerCommonCases(r, h) }},
+        {name: "rejected", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerRejectedMethodCases(r, h) }},
+        {name: "filters", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerFilterCases(r, h, filterTTL) }},
+        {name: "debug", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerDebugCases(r, h) }},
+        {name: "txtypes", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerTxTypeCases(r, h) }},
+        {name: "prechecks", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerPrecheckCases(r, h) }},
+        {name: "blocks", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerBlockCases(r, h, blocks) }},
+        {name: "feehistory", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerFeeHistoryCases(r, h, feeHistoryMax) }},
+        {name: "logs", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerLogsCases(r, h, logsRangeLimit) }},
+        {name: "entities", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerEntityCases(r, h, mirrorUrl) }},
+        {name: "tinybars", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerTinybarCases(r, h) }},
+        {name: "blocktags", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerBlockTagCases(r, h) }},
+        {name: "receipts", tags: []string{tagHttp, tagWs, tagWrite}, register: func(r *runner) { registerReceiptCases(r, h, receiptBlocks) }},
+        {name: "subscriptions", tags: []string{tagWs, tagWrite}, register: func(r *runner) { registerSubscriptionCases(r, h, endpointUrl) }},
+        {name: "https", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerHttpsCases(r, h) }},
+        {name: "batch", tags: []string{tagHttp, tagRead}, register: func(r *runner) { registerBatchCases(r, h) }},
+    }
+}
+
+// registerWriteCases adds the cases that submit the transactions every other
+// case reads back.
+func registerWriteCases(r *runner, h *harness) {
+    r.add("eth_sendRawTransaction (transfer)", func() error {
+        signedTx, err := testSendDummyTransaction(h.client, h.fromAddress, h.privateKey, h.chainId)
+        if err != nil {
+            return err
+        }
+        receipt, err := waitForTransaction(h.client, signedTx)
+        if err != nil {
+            return err
+        }
+        h.transferTx = signedTx
+        h.transferReceipt = receipt
+        return nil
+    })
+    r.add("eth_sendRawTransaction (contract creation)", func() error {
+        signedContractTx, contractAddress, err := testSendContractCreationTransaction(h.client, h.fromAddress, h.privateKey, h.chainId)
+        if err != nil {
+            return err
+        }
+        receipt, err := waitForTransaction(h.client, signedContractTx)
+        if err != nil {
+            return err
+        }
+        h.contractTx = signedContractTx
+        h.contractReceipt = receipt
+        h.contractAddress = contractAddress
+        return nil
+    })
+}
 
-    // https only methods
-    testGetTransactionCount(client)
-    testFeeHistory(client, 5, blockNumber, []float64{10, 50, 90})
-    testGetAccounts(client)
-    testGetBlockTransactionCountByHash(client, blockHash)
-    testGetBlockTransactionCountByNumber(client)
-    testGetTransactionByBlockHashAndIndex(client, blockHash, txIndex)
-    testSyncing(client)
+func registerCommonCases(r *runner, h *harness) {
+    r.add("eth_getBlockByNumber", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testBlockByNumber(h.client, h.transferReceipt.BlockNumber)
+    })
+    r.add("eth_getTransactionReceipt (hash match)", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testTransactionReceipt(h.client, h.transferTx.Hash().Hex())
+    })
+    r.add("eth_getBalance", func() error {
+        return testGetBalance(h.client, h.fromAddress)
+    })
+    r.add("eth_call", func() error {
+        return testEthCall(h.client, h.fromAddress)
+    })
+    r.add("eth_estimateGas", func() error {
+        return testEstimateGas(h.client, h.fromAddress)
+    })
+    r.add("eth_gasPrice", func() error {
+        return testGetGasPrice(h.client)
+    })
+    r.add("eth_getBlockByHash", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testBlockByHash(h.client, h.transferReceipt.BlockHash)
+    })
+    r.add("eth_getCode", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        return testCodeAt(h.client, h.contractAddress)
+    })
+    r.add("eth_getLogs", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        return testGetLogs(h.client, h.contractAddress, nil)
+    })
+    r.add("eth_getStorageAt", func() error {
+        if err := h.requireContract(); err != nil {
+            return err
+        }
+        return testStorageAt(h.client, h.contractAddress, "0x0")
+    })
+    r.add("eth_getTransactionByHash", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetTransactionByHash(h.client, h.transferTx.Hash().Hex())
+    })
+    r.add("eth_getTransactionReceipt", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetTransactionReceipt(h.client, h.transferTx.Hash().Hex())
+    })
+    r.add("eth_blockNumber", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testBlockNumber(h.client, h.transferReceipt.BlockNumber)
+    })
+    r.add("eth_maxPriorityFeePerGas", func() error {
+        return testMaxPriorityFeePerGas(h.client)
+    })
+    r.add("web3_clientVersion", func() error {
+        return testClientVersion(h.client)
+    })
+}
+
+// registerHttpsCases adds the cases of the methods the relay only serves
+// over HTTP.
+func registerHttpsCases(r *runner, h *harness) {
+    r.add("eth_getTransactionCount", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        sent := []*types.Transaction{h.transferTx}
+        if h.contractTx != nil {
+            sent = append(sent, h.contractTx)
+        }
+        return testGetTransactionCount(h.client, h.fromAddress, sent)
+    })
+    r.add("eth_feeHistory", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testFeeHistory(h.client, 5, h.transferReceipt.BlockNumber, []float64{10, 50, 90})
+    })
+    r.add("eth_accounts", func() error {
+        return testGetAccounts(h.client)
+    })
+    r.add("eth_getBlockTransactionCountByHash", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetBlockTransactionCountByHash(h.client, h.transferReceipt.BlockHash)
+    })
+    r.add("eth_getBlockTransactionCountByNumber", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetBlockTransactionCountByNumber(h.client, h.transferReceipt.BlockNumber)
+    })
+    r.add("eth_getTransactionByBlockHashAndIndex", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetTransactionByBlockHashAndIndex(h.client, h.transferReceipt.BlockHash, h.transferReceipt.TransactionIndex)
+    })
+    r.add("eth_syncing", func() error {
+        return testSyncing(h.client)
+    })
+    r.add("eth_getTransactionByBlockNumberAndIndex", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testGetTransactionByBlockNumberAndIndex(h.client, h.transferReceipt.BlockNumber, h.transferReceipt.TransactionIndex, h.transferTx.Hash())
+    })
+    r.add("net_version", func() error {
+        return testNetVersion(h.client, h.chainId)
+    })
+    r.add("net_listening", func() error {
+        return testNetListening(h.client)
+    })
+    r.add("net_peerCount", func() error {
+        return testUnsupportedMethod(h, "net_peerCount")
+    })
+    r.add("eth_coinbase", func() error {
+        return testUnsupportedMethod(h, "eth_coinbase")
+    })
+    r.add("eth_mining", func() error {
+        return testMining(h.client)
+    })
+    r.add("eth_hashrate", func() error {
+        return testHashrate(h.client)
+    })
+    r.add("eth_getUncleCountByBlockHash", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testUncleCount(h.client, "eth_getUncleCountByBlockHash", h.transferReceipt.BlockHash)
+    })
+    r.add("eth_getUncleCountByBlockNumber", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testUncleCount(h.client, "eth_getUncleCountByBlockNumber", hexutil.EncodeBig(h.transferReceipt.BlockNumber))
+    })
+    r.add("eth_getUncleByBlockHashAndIndex", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testUncle(h.client, "eth_getUncleByBlockHashAndIndex", h.transferReceipt.BlockHash)
+    })
+    r.add("eth_getUncleByBlockNumberAndIndex", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return testUncle(h.client, "eth_getUncleByBlockNumberAndIndex", hexutil.EncodeBig(h.transferReceipt.BlockNumber))
+    })
 }
 
-func testBlockByHash(client *ethclient.Client, blockHash common.Hash) {
+func testBlockByHash(client *ethclient.Client, blockHash common.Hash) error {
     block, err := client.BlockByHash(context.Background(), blockHash)
     if err != nil {
-        log.Fatalf("Failed to get block by hash: %v", err)
+        return fmt.Errorf("Failed to get block by hash: %v", err)
     }
     fmt.Printf("Block by hash: %s\n", block.Hash().Hex())
+    return nil
 }
 
-func testBlockByNumber(client *ethclient.Client, blockNumber *big.Int) {
+func testBlockByNumber(client *ethclient.Client, blockNumber *big.Int) error {
     block, err := client.BlockByNumber(context.Background(), blockNumber)
     if err != nil {
-        log.Fatalf("Failed to get block by number: %v", err)
+        return fmt.Errorf("Failed to get block by number: %v", err)
     }
     if block.Number().Cmp(blockNumber) != 0 {
-        log.Fatalf("Block number mismatch: expected %s, got %s", blockNumber.String(), block.Number().String())
+        return fmt.Errorf("Block number mismatch: expected %s, got %s", blockNumber.String(), block.Number().String())
     }
     fmt.Printf("Block by number\n")
+    return nil
 }
 
-func testTransactionReceipt(client *ethclient.Client, txHash string) {
+func testTransactionReceipt(client *ethclient.Client, txHash string) error {
     receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txHash))
     if err != nil {
-        log.Fatalf("Failed to get transaction receipt: %v", err)
+        return fmt.Errorf("Failed to get transaction receipt: %v", err)
     }
     if receipt == nil {
         fmt.Printf("Transaction receipt: null\n")
     } else if receipt.TxHash.Hex() != txHash {
-        log.Fatalf("Receipt transaction hash mismatch: expected %s, got %s", txHash, receipt.TxHash.Hex())
+        return fmt.Errorf("Receipt transaction hash mismatch: expected %s, got %s", txHash, receipt.TxHash.Hex())
     } else {
         fmt.Printf("Transaction receipt: %d\n", receipt.Status)
     }
+    return nil
 }
 
-func testGetBalance(client *ethclient.Client, fromAddress common.Address) {
+func testGetBalance(client *ethclient.Client, fromAddress common.Address) error {
     balance, err := client.BalanceAt(context.Background(), fromAddress, nil)
     if err != nil {
-        log.Fatalf("Failed to get balance: %v", err)
+        return fmt.Errorf("Failed to get balance: %v", err)
     }
     if balance == nil {
-        log.Fatalf("Balance is nil")
+        return fmt.Errorf("Balance is nil")
     }
     fmt.Printf("Account balance: %s\n", balance.String())
+    return nil
 }
 
-func testEthCall(client *ethclient.Client, fromAddress common.Address) {
+func testEthCall(client *ethclient.Client, fromAddress common.Address) error {
     msg := ethereum.CallMsg{
         From: fromAddress,
         To:   &fromAddress,
@@ -179,12 +723,13 @@ func testEthCall(client *ethclient.Client, fromAddress common.Address) {
     }
     result, err := client.CallContract(context.Background(), msg, nil)
     if err != nil {
-        log.Fatalf("Failed to call contract: %v", err)
+        return fmt.Errorf("Failed to call contract: %v", err)
     }
     fmt.Printf("eth_call result %s\n", hex.EncodeToString(result))
+    return nil
 }
 
-func testEstimateGas(client *ethclient.Client, fromAddress common.Address) {
+func testEstimateGas(client *ethclient.Client, fromAddress common.Address) error {
     msg := ethereum.CallMsg{
         From:  fromAddress,
         To:    &fromAddress,
@@ -194,43 +739,55 @@ func testEstimateGas(client *ethclient.Client, fromAddress common.Address) {
     }
     gasEstimate, err := client.EstimateGas(context.Background(), msg)
     if err != nil {
-        log.Fatalf("Failed to estimate gas: %v", err)
+        return fmt.Errorf("Failed to estimate gas: %v", err)
     }
     if gasEstimate <= 0 {
-        log.Fatalf("Gas estimate is invalid: %d", gasEstimate)
+        return fmt.Errorf("Gas estimate is invalid: %d", gasEstimate)
     }
     fmt.Printf("Gas estimate: %d\n", gasEstimate)
+    return nil
 }
 
-func testGetGasPrice(client *ethclient.Client) {
+func testGetGasPrice(client *ethclient.Client) error {
     gasPrice, err := client.SuggestGasPrice(context.Background())
     if err != nil {
-        log.Fatalf("Failed to get gas price: %v", err)
+        return fmt.Errorf("Failed to get gas price: %v", err)
     }
     if gasPrice.Cmp(big.NewInt(0)) <= 0 {
-        log.Fatalf("Gas price is invalid: %s", gasPrice.String())
+        return fmt.Errorf("Gas price is invalid: %s", gasPrice.String())
     }
     fmt.Printf("Gas price: %s\n", gasPrice.String())
+    return nil
 }
 
-func testSendContractCreationTransaction(client *ethclient.Client, fromAddress common.Address, privateKey *ecdsa.PrivateKey, chainId *big.Int) (*types.Transaction, common.Address) {
-    file, err := os.ReadFile("contracts/input.bin")
+// sampleContractBytecode reads the creation bytecode of the contract the
+// write cases deploy.
+func sampleContractBytecode() ([]byte, error) {
+    file, err := os.ReadFile(artifacts.SampleContract)
     if err != nil {
-        log.Fatalf("Failed to read bytecode from file: %v", err)
+        return nil, fmt.Errorf("Failed to read bytecode from file: %v", err)
     }
     bytecodeStr := string(file)
     bytecode, err := hex.DecodeString(bytecodeStr)
     if err != nil {
-        log.Fatalf("Failed to decode the bytecode string: %v", err)
+        return nil, fmt.Errorf("Failed to decode the bytecode string: %v", err)
+    }
+    return bytecode, nil
+}
+
+func testSendContractCreationTransaction(client *ethclient.Client, fromAddress common.Address, privateKey *ecdsa.PrivateKey, chainId *big.Int) (*types.Transaction, common.Address, error) {
+    bytecode, err := sampleContractBytecode()
+    if err != nil {
+        return nil, common.Address{}, err
     }
     nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
     if err != nil {
-        log.Fatalf("Failed to get transaction count: %v", err)
+        return nil, common.Address{}, fmt.Errorf("Failed to get transaction count: %v", err)
     }
     fmt.Printf("Transaction count (nonce): %d\n", nonce)
     gasPrice, err := client.SuggestGasPrice(context.Background())
     if err != nil {
-        log.Fatalf("Failed to get gas price: %v", err)
+        return nil, common.Address{}, fmt.Errorf("Failed to get gas price: %v", err)
     }
     txData := &types.AccessListTx{
         ChainID:    chainId,
@@ -246,7 +803,7 @@ func testSendContractCreationTransaction(client *ethclient.Client, fromAddress c
     signer := types.NewEIP2930Signer(chainId)
     signedTx, err := types.SignTx(tx, signer, privateKey)
     if err != nil {
-        log.Fatalf("Failed to sign transaction: %v", err)
+        return nil, common.Address{}, fmt.Errorf("Failed to sign transaction: %v", err)
     }
     v, r, s := signedTx.RawSignatureValues()
     fmt.Printf("R: %s\n", r.String())
@@ -254,44 +811,32 @@ func testSendContractCreationTransaction(client *ethclient.Client, fromAddress c
     fmt.Printf("V: %s\n", v.String())
     err = client.SendTransaction(context.Background(), signedTx)
     if err != nil {
-        log.Fatalf("Failed to send transaction: %v", err)
+        return nil, common.Address{}, fmt.Errorf("Failed to send transaction: %v", err)
     }
     fmt.Printf("Sent raw transaction: %s\n", signedTx.Hash().Hex())
 
     contractAddress := crypto.CreateAddress(fromAddress, nonce)
     fmt.Printf("New contract address: %s\n", contractAddress.Hex())
 
-    return signedTx, contractAddress
+    return signedTx, contractAddress, nil
 }
 
-func testSendDummyTransaction(client *ethclient.Client, fromAddress common.Address, privateKey *ecdsa.PrivateKey, chainId *big.Int) *types.Transaction {
+func testSendDummyTransaction(client *ethclient.Client, fromAddress common.Address, privateKey *ecdsa.PrivateKey, chainId *big.Int) (*types.Transaction, error) {
     nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
     if err != nil {
-        log.Fatalf("Failed to get transaction count: %v", err)
+        return nil, fmt.Errorf("Failed to get transaction count: %v", err)
     }
     fmt.Printf("Transaction count (nonce): %d\n", nonce)
 
     gasPrice, err := client.SuggestGasPrice(context.Background())
     if err != nil {
-        log.Fatalf("Failed to get gas price: %v", err)
-    }
-
-    txData := &types.AccessListTx{
-        ChainID:    chainId,
-        Nonce:      nonce,
-        GasPrice:   gasPrice,
-        Gas:        21000,
-        To:         &fromAddress,
-        Value:      big.NewInt(10000000000), // 0.01 Ether
-        Data:       nil,
-        AccessList: types.AccessList{},
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
     }
 
-    tx := types.NewTx(txData)
-    signer := types.NewEIP2930Signer(chainId)
-    signedTx, err := types.SignTx(tx, signer, privateKey)
+    txData := dummyTransactionData(fromAddress, chainId, nonce, gasPrice)
+    signedTx, err := signDummyTransaction(txData, privateKey)
     if err != nil {
-        log.Fatalf("Failed to sign transaction: %v", err)
+        return nil, err
     }
 
     v, r, s := signedTx.RawSignatureValues()
@@ -301,20 +846,46 @@ func testSendDummyTransaction(client *ethclient.Client, fromAddress common.Addre
 
     err = client.SendTransaction(context.Background(), signedTx)
     if err != nil {
-        log.Fatalf("Failed to send transaction: %v", err)
+        return nil, fmt.Errorf("Failed to send transaction: %v", err)
     }
     fmt.Printf("Sent raw transaction: %s\n", signedTx.Hash().Hex())
 
-    return signedTx
+    return signedTx, nil
+}
+
+// dummyTransactionData is the transfer testSendDummyTransaction sends to the
+// sender itself.
+func dummyTransactionData(fromAddress common.Address, chainId *big.Int, nonce uint64, gasPrice *big.Int) *types.AccessListTx {
+    return &types.AccessListTx{
+        ChainID:    chainId,
+        Nonce:      nonce,
+        GasPrice:   gasPrice,
+        Gas:        21000,
+        To:         &fromAddress,
+        Value:      big.NewInt(hedera.WeibarsPerTinybar), // 1 tinybar
+        Data:       nil,
+        AccessList: types.AccessList{},
+    }
+}
+
+// signDummyTransaction signs txData for the chain ID it holds.
+func signDummyTransaction(txData *types.AccessListTx, privateKey *ecdsa.PrivateKey) (*types.Transaction, error) {
+    tx := types.NewTx(txData)
+    signer := types.NewEIP2930Signer(txData.ChainID)
+    signedTx, err := types.SignTx(tx, signer, privateKey)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    return signedTx, nil
 }
 
-func testGetAccounts(client *ethclient.Client) {
+func testGetAccounts(client *ethclient.Client) error {
     rpcClient := client.Client()
 
     var result []string
     err := rpcClient.CallContext(context.Background(), &result, "eth_accounts")
     if err != nil {
-        log.Fatalf("Failed to get accounts: %v", err)
+        return fmt.Errorf("Failed to get accounts: %v", err)
     }
 
     for _, account := range result {
@@ -325,76 +896,82 @@ func testGetAccounts(client *ethclient.Client) {
             continue
         }
         if balance == nil {
-            log.Fatalf("Balance for account %s is nil", account)
+            return fmt.Errorf("Balance for account %s is nil", account)
         }
         fmt.Printf("Account %s balance: %s\n", account, balance.String())
     }
+    return nil
 }
 
-func testFeeHistory(client *ethclient.Client, blockCount uint64, newestBlock *big.Int, rewardPercentiles []float64) {
+func testFeeHistory(client *ethclient.Client, blockCount uint64, newestBlock *big.Int, rewardPercentiles []float64) error {
     ctx := context.Background()
 
     feeHistory, err := client.FeeHistory(ctx, blockCount, newestBlock, rewardPercentiles)
     if err != nil {
-        log.Fatalf("Failed to get fee history: %v", err)
+        return fmt.Errorf("Failed to get fee history: %v", err)
     }
 
     if feeHistory == nil {
-        log.Fatalf("Fee history is nil")
+        return fmt.Errorf("Fee history is nil")
     }
 
     fmt.Printf("Fee History:\n")
     fmt.Printf("Oldest Block: %s\n", feeHistory.OldestBlock.String())
     fmt.Printf("Gas Used Ratio: %v\n", feeHistory.GasUsedRatio)
     fmt.Printf("Reward: %v\n", feeHistory.Reward)
+    return nil
 }
 
-func waitForTransaction(client *ethclient.Client, tx *types.Transaction) *types.Receipt {
+func waitForTransaction(client *ethclient.Client, tx *types.Transaction) (*types.Receipt, error) {
     ctx := context.Background()
     receipt, err := bind.WaitMined(ctx, client, tx)
     if err != nil {
-        log.Fatalf("Failed to wait for transaction to be mined: %v", err)
+        return nil, fmt.Errorf("Failed to wait for transaction to be mined: %v", err)
     }
     if receipt == nil {
-        log.Fatalf("Receipt is nil")
+        return nil, fmt.Errorf("Receipt is nil")
     }
-    return receipt
+    return receipt, nil
 }
 
-func testGetBlockTransactionCountByHash(client *ethclient.Client, blockHash common.Hash) {
+func testGetBlockTransactionCountByHash(client *ethclient.Client, blockHash common.Hash) error {
     txCount, err := client.TransactionCount(context.Background(), blockHash)
     if err != nil {
-        log.Fatalf("Failed to get transaction count by block hash: %v", err)
+        return fmt.Errorf("Failed to get transaction count by block hash: %v", err)
     }
     if txCount < 0 {
-        log.Fatalf("Transaction count is invalid: %d", txCount)
+        return fmt.Errorf("Transaction count is invalid: %d", txCount)
     }
     fmt.Printf("Transaction count by block hash %s: %d\n", blockHash.Hex(), txCount)
+    return nil
 }
 
-func testGetBlockTransactionCountByNumber(client *ethclient.Client) {
-    txCount, err := client.PendingTransactionCount(context.Background())
+func testGetBlockTransactionCountByNumber(client *ethclient.Client, blockNumber *big.Int) error {
+    var txCount hexutil.Uint64
+    err := client.Client().CallContext(context.Background(), &txCount, "eth_getBlockTransactionCountByNumber", hexutil.EncodeBig(blockNumber))
     if err != nil {
-        log.Fatalf("Failed to get transaction count by block number: %v", err)
+        return fmt.Errorf("Failed to get transaction count by block number: %v", err)
     }
-    if txCount < 0 {
-        log.Fatalf("Transaction count is invalid: %d", txCount)
+    if txCount == 0 {
+        return fmt.Errorf("Transaction count of block %s is 0, expected the mined transaction", blockNumber.String())
     }
-    fmt.Printf("Transaction count by block number: %d\n", txCount)
+    fmt.Printf("Transaction count by block number %s: %d\n", blockNumber.String(), txCount)
+    return nil
 }
 
-func testCodeAt(client *ethclient.Client, address common.Address) {
+func testCodeAt(client *ethclient.Client, address common.Address) error {
     code, err := client.CodeAt(context.Background(), address, nil)
     if err != nil {
-        log.Fatalf("Failed to get code at address: %v", err)
+        return fmt.Errorf("Failed to get code at address: %v", err)
     }
     if code == nil {
-        log.Fatalf("Code is nil")
+        return fmt.Errorf("Code is nil")
     }
     fmt.Printf("Code at address %s: %s\n", address.Hex(), hex.EncodeToString(code))
+    return nil
 }
 
-func testGetLogs(client *ethclient.Client, address common.Address, topics []common.Hash) {
+func testGetLogs(client *ethclient.Client, address common.Address, topics []common.Hash) error {
     query := ethereum.FilterQuery{
         Addresses: []common.Address{address},
         Topics:    [][]common.Hash{topics},
@@ -402,7 +979,7 @@ func testGetLogs(client *ethclient.Client, address common.Address, topics []comm
 
     logs, err := client.FilterLogs(context.Background(), query)
     if err != nil {
-        log.Fatalf("Failed to get logs: %v", err)
+        return fmt.Errorf("Failed to get logs: %v", err)
     }
 
     fmt.Printf("Logs for address %s:\n", address.Hex())
@@ -415,71 +992,231 @@ func testGetLogs(client *ethclient.Client, address common.Address, topics []comm
             fmt.Printf("Log Data: %s\n", hex.EncodeToString(vLog.Data))
         }
     }
+    return nil
 }
 
-func testStorageAt(client *ethclient.Client, address common.Address, slot string) {
+func testStorageAt(client *ethclient.Client, address common.Address, slot string) error {
     slotHash := common.HexToHash(slot)
     storageValue, err := client.StorageAt(context.Background(), address, slotHash, nil)
     if err != nil {
-        log.Fatalf("Failed to get storage at address: %v", err)
+        return fmt.Errorf("Failed to get storage at address: %v", err)
     }
     if storageValue == nil {
-        log.Fatalf("Storage value is nil")
+        return fmt.Errorf("Storage value is nil")
     }
     fmt.Printf("Storage at address %s slot %s: %s\n", address.Hex(), slot, hex.EncodeToString(storageValue))
+    return nil
 }
 
-func testGetTransactionByBlockHashAndIndex(client *ethclient.Client, blockHash common.Hash, index uint) {
+func testGetTransactionByBlockHashAndIndex(client *ethclient.Client, blockHash common.Hash, index uint) error {
     tx, err := client.TransactionInBlock(context.Background(), blockHash, index)
     if err != nil {
-        log.Fatalf("Failed to get transaction by block hash and index: %v", err)
+        return fmt.Errorf("Failed to get transaction by block hash and index: %v", err)
     }
     fmt.Printf("Transaction in block hash %s at index %d: %s\n", blockHash.Hex(), index, tx.Hash().Hex())
+    return nil
 }
 
-func testGetTransactionByHash(client *ethclient.Client, txHash string) {
+func testGetTransactionByHash(client *ethclient.Client, txHash string) error {
     tx, _, err := client.TransactionByHash(context.Background(), common.HexToHash(txHash))
     if err != nil {
-        log.Fatalf("Failed to get transaction by hash: %v", err)
+        return fmt.Errorf("Failed to get transaction by hash: %v", err)
     }
     if tx == nil {
-        log.Fatalf("Transaction is nil")
+        return fmt.Errorf("Transaction is nil")
     }
     fmt.Printf("Transaction by hash: %s\n", tx.Hash().Hex())
+    return nil
 }
 
-func testGetTransactionCount(client *ethclient.Client) {
-    count, err := client.PendingTransactionCount(context.Background())
+// testGetTransactionCount checks that the latest nonce of the sender is past
+// the nonce of every transaction it sent. Later cases may have sent more.
+func testGetTransactionCount(client *ethclient.Client, fromAddress common.Address, sent []*types.Transaction) error {
+    nonce, err := client.NonceAt(context.Background(), fromAddress, nil)
     if err != nil {
-        log.Fatalf("Failed to get transaction count: %v", err)
+        return fmt.Errorf("Failed to get transaction count: %v", err)
     }
-    if count < 0 {
-        log.Fatalf("Transaction count is invalid: %d", count)
+    for _, tx := range sent {
+        if nonce <= tx.Nonce() {
+            return fmt.Errorf("Transaction count for address %s is %d, expected more than the nonce %d of transaction %s", fromAddress.Hex(), nonce, tx.Nonce(), tx.Hash().Hex())
+        }
     }
-    fmt.Printf("Transaction count for address %d\n", count)
+    fmt.Printf("Transaction count for address %s: %d\n", fromAddress.Hex(), nonce)
+    return nil
 }
 
-func testGetTransactionReceipt(client *ethclient.Client, txHash string) {
+func testGetTransactionReceipt(client *ethclient.Client, txHash string) error {
     receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txHash))
     if err != nil {
-        log.Fatalf("Failed to get transaction receipt: %v", err)
+        return fmt.Errorf("Failed to get transaction receipt: %v", err)
     }
     if receipt == nil {
-        log.Fatalf("Receipt is nil")
+        return fmt.Errorf("Receipt is nil")
     }
     fmt.Printf("Transaction receipt for hash %s: %v\n", txHash, receipt)
+    return nil
 }
 
-func testSyncing(client *ethclient.Client) {
+func testSyncing(client *ethclient.Client) error {
     syncing, err := client.SyncProgress(context.Background())
     if err != nil {
-        log.Fatalf("Failed to get syncing status: %v", err)
+        return fmt.Errorf("Failed to get syncing status: %v", err)
     }
     if syncing == nil {
         fmt.Println("Not syncing")
     } else {
         fmt.Printf("Syncing: %+v\n", syncing)
     }
+    return nil
+}
+
+func testGetTransactionByBlockNumberAndIndex(client *ethclient.Client, blockNumber *big.Int, index uint, expected common.Hash) error {
+    var tx struct {
+        Hash common.Hash `json:"hash"`
+    }
+    err := client.Client().CallContext(context.Background(), &tx, "eth_getTransactionByBlockNumberAndIndex", hexutil.EncodeBig(blockNumber), hexutil.EncodeUint64(uint64(index)))
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction by block number and index: %v", err)
+    }
+    if tx.Hash != expected {
+        return fmt.Errorf("Transaction hash mismatch: expected %s, got %s", expected.Hex(), tx.Hash.Hex())
+    }
+    fmt.Printf("Transaction in block %s at index %d: %s\n", blockNumber.String(), index, tx.Hash.Hex())
+    return nil
+}
+
+func testBlockNumber(client *ethclient.Client, minBlockNumber *big.Int) error {
+    blockNumber, err := client.BlockNumber(context.Background())
+    if err != nil {
+        return fmt.Errorf("Failed to get block number: %v", err)
+    }
+    if blockNumber < minBlockNumber.Uint64() {
+        return fmt.Errorf("Block number %d is behind block %s of the mined transaction", blockNumber, minBlockNumber.String())
+    }
+    fmt.Printf("Block number: %d\n", blockNumber)
+    return nil
+}
+
+func testMaxPriorityFeePerGas(client *ethclient.Client) error {
+    fee, err := client.SuggestGasTipCap(context.Background())
+    if err != nil {
+        return fmt.Errorf("Failed to get max priority fee per gas: %v", err)
+    }
+    // Hedera has no tips, so the relay always returns 0x0.
+    if fee.Sign() != 0 {
+        return fmt.Errorf("Max priority fee per gas should be 0, got %s", fee.String())
+    }
+    fmt.Printf("Max priority fee per gas: %s\n", fee.String())
+    return nil
+}
+
+func testNetVersion(client *ethclient.Client, chainId *big.Int) error {
+    version, err := client.NetworkID(context.Background())
+    if err != nil {
+        return fmt.Errorf("Failed to get net version: %v", err)
+    }
+    if version.Cmp(chainId) != 0 {
+        return fmt.Errorf("Net version mismatch: expected chain ID %s, got %s", chainId.String(), version.String())
+    }
+    fmt.Printf("Net version: %s\n", version.String())
+    return nil
 }
 
+func testNetListening(client *ethclient.Client) error {
+    // The relay answers with the string form of its listening status.
+    var listening string
+    err := client.Client().CallContext(context.Background(), &listening, "net_listening")
+    if err != nil {
+        return fmt.Errorf("Failed to get listening status: %v", err)
+    }
+    if listening != "false" {
+        return fmt.Errorf("Listening status is %q, expected \"false\"", listening)
+    }
+    fmt.Printf("Listening: %s\n", listening)
+    return nil
+}
+
+var clientVersionPattern = regexp.MustCompile(`^relay/[0-9]+\.[0-9]+\.[0-9]+(-[a-zA-Z0-9-]+)?$`)
 
+func testClientVersion(client *ethclient.Client) error {
+    var version string
+    err := client.Client().CallContext(context.Background(), &version, "web3_clientVersion")
+    if err != nil {
+        return fmt.Errorf("Failed to get client version: %v", err)
+    }
+    if !clientVersionPattern.MatchString(version) {
+        return fmt.Errorf("Client version %q is not of the form relay/<version>", version)
+    }
+    fmt.Printf("Client version: %s\n", version)
+    return nil
+}
+
+func testMining(client *ethclient.Client) error {
+    var mining bool
+    err := client.Client().CallContext(context.Background(), &mining, "eth_mining")
+    if err != nil {
+        return fmt.Errorf("Failed to get mining status: %v", err)
+    }
+    if mining {
+        return fmt.Errorf("Relay should report it is not mining")
+    }
+    fmt.Printf("Mining: %t\n", mining)
+    return nil
+}
+
+func testHashrate(client *ethclient.Client) error {
+    var hashrate hexutil.Uint64
+    err := client.Client().CallContext(context.Background(), &hashrate, "eth_hashrate")
+    if err != nil {
+        return fmt.Errorf("Failed to get hashrate: %v", err)
+    }
+    if hashrate != 0 {
+        return fmt.Errorf("Hashrate should be 0, got %d", hashrate)
+    }
+    fmt.Printf("Hashrate: %d\n", hashrate)
+    return nil
+}
+
+// testUncleCount checks an uncle count method, which is always 0 as there
+// are no uncles on Hedera.
+func testUncleCount(client *ethclient.Client, method string, block interface{}) error {
+    var count hexutil.Uint64
+    err := client.Client().CallContext(context.Background(), &count, method, block)
+    if err != nil {
+        return fmt.Errorf("Failed to get uncle count: %v", err)
+    }
+    if count != 0 {
+        return fmt.Errorf("Uncle count should be 0, got %d", count)
+    }
+    fmt.Printf("Uncle count: %d\n", count)
+    return nil
+}
+
+// testUncle checks an uncle getter, which always returns null.
+func testUncle(client *ethclient.Client, method string, block interface{}) error {
+    var uncle json.RawMessage
+    err := client.Client().CallContext(context.Background(), &uncle, method, block, "0x0")
+    if err != nil {
+        return fmt.Errorf("Failed to get uncle: %v", err)
+    }
+    if string(uncle) != "null" {
+        return fmt.Errorf("Uncle should be null, got %s", string(uncle))
+    }
+    fmt.Printf("Uncle: %s\n", string(uncle))
+    return nil
+}
+
+// testUnsupportedMethod checks that the relay rejects a method it does not
+// implement with the unsupported method error.
+func testUnsupportedMethod(h *harness, method string) error {
+    var result interface{}
+    err := h.client.Client().CallContext(context.Background(), &result, method)
+    if err == nil {
+        return fmt.Errorf("Expected %s to be unsupported, got %v", method, result)
+    }
+    if err := h.expectRelayError(err, unsupportedMethodCode, unsupportedMethodMessage); err != nil {
+        return err
+    }
+    fmt.Printf("%s: %s\n", method, unsupportedMethodMessage)
+    return nil
+}
@@ -0,0 +1,81 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "fmt"
+    "log"
+    "math/big"
+    "os"
+    "os/signal"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/crypto"
+    "hedera-json-rpc-golang-tests-project/hedera"
+    "hedera-json-rpc-golang-tests-project/mockrelay"
+)
+
+// mockOperatorBalance funds the operator of a mock relay with 1,000,000 HBAR.
+var mockOperatorBalance = new(big.Int).Mul(big.NewInt(1_000_000_00_000_000), big.NewInt(hedera.WeibarsPerTinybar))
+
+// operatorKey parses the operator key. A mock relay funds whichever key it is
+// given, so when none is configured a fresh one is generated for it.
+func operatorKey(privateKeyHex string, useMock bool) (*ecdsa.PrivateKey, error) {
+    if privateKeyHex == "" && useMock {
+        return crypto.GenerateKey()
+    }
+    return crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+}
+
+func startMockRelay(addr string, config mockrelay.Config, operator common.Address) *mockrelay.Relay {
+    config.Accounts = map[common.Address]*big.Int{operator: mockOperatorBalance}
+    relay, err := mockrelay.New(config)
+    if err != nil {
+        log.Fatalf("Failed to create mock relay: %v", err)
+    }
+    if err := relay.Start(addr); err != nil {
+        log.Fatalf("Failed to start mock relay: %v", err)
+    }
+    fmt.Printf("Mock relay listening on %s and %s\n", relay.URL(), relay.WSURL())
+    return relay
+}
+
+// serveMockRelay serves a mock relay until interrupted, so tools outside this
+// module, such as the golang-example tests, can target it.
+func serveMockRelay(addr string, config mockrelay.Config, privateKey *ecdsa.PrivateKey) {
+    operator := crypto.PubkeyToAddress(privateKey.PublicKey)
+    relay := startMockRelay(addr, config, operator)
+    defer relay.Close()
+
+    fmt.Printf("Chain ID: %s\n", relay.ChainId().String())
+    fmt.Printf("Funded operator %s\n", operator.Hex())
+    if os.Getenv("OPERATOR_PRIVATE_KEY") == "" {
+        fmt.Printf("Operator private key: %s\n", hexutil.Encode(crypto.FromECDSA(privateKey)))
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    <-ctx.Done()
+}
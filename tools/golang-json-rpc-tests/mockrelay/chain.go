@@ -0,0 +1,123 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "errors"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/eth"
+    "github.com/ethereum/go-ethereum/eth/catalyst"
+    "github.com/ethereum/go-ethereum/eth/downloader"
+    "github.com/ethereum/go-ethereum/eth/ethconfig"
+    "github.com/ethereum/go-ethereum/eth/filters"
+    "github.com/ethereum/go-ethereum/eth/tracers"
+    "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/node"
+    "github.com/ethereum/go-ethereum/p2p"
+    "github.com/ethereum/go-ethereum/params"
+    "github.com/ethereum/go-ethereum/rpc"
+
+    // Registers callTracer.
+    _ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+// simulatedChain is set up like go-ethereum's simulated backend, a dev chain
+// that seals a block on every Commit, but also serves the debug namespace so
+// transactions can be traced.
+type simulatedChain struct {
+    node   *node.Node
+    beacon *catalyst.SimulatedBeacon
+    client *ethclient.Client
+}
+
+func newSimulatedChain(alloc types.GenesisAlloc, configure func(nodeConf *node.Config, ethConf *ethconfig.Config)) (*simulatedChain, error) {
+    nodeConf := node.DefaultConfig
+    nodeConf.DataDir = ""
+    nodeConf.P2P = p2p.Config{NoDiscovery: true}
+
+    ethConf := ethconfig.Defaults
+    ethConf.Genesis = &core.Genesis{
+        Config:   params.AllDevChainProtocolChanges,
+        GasLimit: ethconfig.Defaults.Miner.GasCeil,
+        Alloc:    alloc,
+    }
+    ethConf.SyncMode = downloader.FullSync
+    ethConf.TxPool.NoLocals = true
+    configure(&nodeConf, &ethConf)
+
+    stack, err := node.New(&nodeConf)
+    if err != nil {
+        return nil, err
+    }
+    backend, err := eth.New(stack, &ethConf)
+    if err != nil {
+        stack.Close()
+        return nil, err
+    }
+    filterSystem := filters.NewFilterSystem(backend.APIBackend, filters.Config{})
+    stack.RegisterAPIs([]rpc.API{{
+        Namespace: "eth",
+        Service:   filters.NewFilterAPI(filterSystem),
+    }})
+    stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+    if err := stack.Start(); err != nil {
+        stack.Close()
+        return nil, err
+    }
+    // Hedera has no tips, so transactions without one must be accepted and
+    // mined like any other.
+    backend.TxPool().SetGasTip(new(big.Int))
+    backend.Miner().SetGasTip(new(big.Int))
+    beacon, err := catalyst.NewSimulatedBeacon(0, backend)
+    if err != nil {
+        stack.Close()
+        return nil, err
+    }
+    if err := beacon.Fork(backend.BlockChain().GetCanonicalHash(0)); err != nil {
+        beacon.Stop()
+        stack.Close()
+        return nil, err
+    }
+    return &simulatedChain{
+        node:   stack,
+        beacon: beacon,
+        client: ethclient.NewClient(stack.Attach()),
+    }, nil
+}
+
+// Client returns a client that accesses the chain in-process.
+func (c *simulatedChain) Client() *ethclient.Client {
+    return c.client
+}
+
+// Commit seals a block with the pending transactions.
+func (c *simulatedChain) Commit() common.Hash {
+    return c.beacon.Commit()
+}
+
+func (c *simulatedChain) Close() error {
+    c.client.Close()
+    return errors.Join(c.beacon.Stop(), c.node.Close())
+}
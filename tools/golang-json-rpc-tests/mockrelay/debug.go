@@ -0,0 +1,127 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// Tracers accepted by the relay's debug_traceTransaction.
+const (
+    tracerCallTracer   = "callTracer"
+    tracerOpcodeLogger = "opcodeLogger"
+)
+
+// tracerConfigWrapper is the second debug_traceTransaction param when it
+// names the tracer.
+type tracerConfigWrapper struct {
+    Tracer       string          `json:"tracer"`
+    TracerConfig json.RawMessage `json:"tracerConfig"`
+}
+
+// traceTransaction accepts the params the relay accepts: a tracer name
+// optionally followed by its config, a tracer config alone, or a wrapper
+// object holding both. The tracer defaults to the opcodeLogger.
+//
+// The traces come from go-ethereum, whose struct logger takes the same
+// options as the opcodeLogger at the top level, and whose callTracer takes
+// its config the same way as the relay. The relay builds callTracer frames
+// from the mirror node, so their values are in tinybars.
+func (r *Relay) traceTransaction(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    if len(params) < 1 {
+        return nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    tracer := tracerOpcodeLogger
+    var config json.RawMessage
+    if len(params) > 1 {
+        var wrapper tracerConfigWrapper
+        if err := json.Unmarshal(params[1], &tracer); err == nil {
+            if len(params) > 2 {
+                config = params[2]
+            }
+        } else if err := json.Unmarshal(params[1], &wrapper); err != nil {
+            return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 1: %v", err)}
+        } else if wrapper.Tracer != "" || wrapper.TracerConfig != nil {
+            if wrapper.Tracer != "" {
+                tracer = wrapper.Tracer
+            }
+            config = wrapper.TracerConfig
+        } else {
+            config = params[1]
+        }
+    }
+    if len(config) == 0 || string(config) == "null" {
+        config = json.RawMessage("{}")
+    }
+
+    var options interface{}
+    switch tracer {
+    case tracerCallTracer:
+        options = map[string]interface{}{"tracer": tracer, "tracerConfig": config}
+    case tracerOpcodeLogger:
+        options = config
+    default:
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 1: Invalid tracer type, value: %s", tracer)}
+    }
+    encoded, err := json.Marshal(options)
+    if err != nil {
+        return nil, err
+    }
+    result, err := r.forward(ctx, "debug_traceTransaction", []json.RawMessage{params[0], encoded})
+    if err != nil || tracer != tracerCallTracer {
+        return result, err
+    }
+    var frame map[string]interface{}
+    if err := json.Unmarshal(result, &frame); err != nil {
+        return nil, err
+    }
+    if err := valuesToTinybars(frame); err != nil {
+        return nil, err
+    }
+    return frame, nil
+}
+
+// valuesToTinybars converts the value of a callTracer frame and of its
+// subcalls from weibars to whole tinybars.
+func valuesToTinybars(frame map[string]interface{}) error {
+    if value, ok := frame["value"].(string); ok {
+        weibars, err := hexutil.DecodeBig(value)
+        if err != nil {
+            return err
+        }
+        frame["value"] = hexutil.EncodeBig(new(big.Int).Quo(weibars, big.NewInt(hedera.WeibarsPerTinybar)))
+    }
+    calls, _ := frame["calls"].([]interface{})
+    for _, call := range calls {
+        if call, ok := call.(map[string]interface{}); ok {
+            if err := valuesToTinybars(call); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
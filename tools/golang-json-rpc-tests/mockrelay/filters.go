@@ -0,0 +1,228 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// DefaultFilterTTL mirrors the relay's FILTER_TTL default.
+const DefaultFilterTTL = 5 * time.Minute
+
+const (
+    filterTypeLog      = "log"
+    filterTypeNewBlock = "newBlock"
+)
+
+var errFilterNotFound = &Error{Code: -32001, Message: "Filter not found"}
+
+// filter is a polling filter kept the way the relay keeps it in its cache:
+// only the criteria and the last block that was queried are stored, and
+// every query extends its lifetime.
+type filter struct {
+    kind            string
+    criteria        logCriteria
+    blockAtCreation uint64
+    lastQueried     uint64
+    expires         time.Time
+}
+
+type logCriteria struct {
+    FromBlock string          `json:"fromBlock,omitempty"`
+    ToBlock   string          `json:"toBlock,omitempty"`
+    Address   json.RawMessage `json:"address,omitempty"`
+    Topics    json.RawMessage `json:"topics,omitempty"`
+}
+
+type logBlockNumber struct {
+    BlockNumber hexutil.Uint64 `json:"blockNumber"`
+}
+
+func (r *Relay) newFilter(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    var criteria logCriteria
+    if len(params) > 0 {
+        if err := json.Unmarshal(params[0], &criteria); err != nil {
+            return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+        }
+    }
+    if criteria.FromBlock == "" || criteria.FromBlock == "latest" {
+        latest, err := r.backend.Client().BlockNumber(ctx)
+        if err != nil {
+            return nil, err
+        }
+        criteria.FromBlock = hexutil.EncodeUint64(latest)
+    }
+    if criteria.ToBlock == "" {
+        criteria.ToBlock = "latest"
+    }
+    return r.installFilter(&filter{kind: filterTypeLog, criteria: criteria}), nil
+}
+
+func (r *Relay) newBlockFilter(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    latest, err := r.backend.Client().BlockNumber(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return r.installFilter(&filter{kind: filterTypeNewBlock, blockAtCreation: latest}), nil
+}
+
+func (r *Relay) installFilter(f *filter) string {
+    id := make([]byte, 16)
+    rand.Read(id)
+    filterId := hexutil.Encode(id)
+
+    r.filtersMu.Lock()
+    defer r.filtersMu.Unlock()
+    f.expires = time.Now().Add(r.config.FilterTTL)
+    r.filters[filterId] = f
+    return filterId
+}
+
+// lookupFilter returns a copy of a live filter and refreshes its lifetime.
+func (r *Relay) lookupFilter(params []json.RawMessage) (string, *filter, error) {
+    if len(params) < 1 {
+        return "", nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    var id string
+    if err := json.Unmarshal(params[0], &id); err != nil {
+        return "", nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+    }
+
+    r.filtersMu.Lock()
+    defer r.filtersMu.Unlock()
+    f, ok := r.filters[id]
+    if !ok {
+        return id, nil, nil
+    }
+    if time.Now().After(f.expires) {
+        delete(r.filters, id)
+        return id, nil, nil
+    }
+    f.expires = time.Now().Add(r.config.FilterTTL)
+    snapshot := *f
+    return id, &snapshot, nil
+}
+
+func (r *Relay) uninstallFilter(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    id, f, err := r.lookupFilter(params)
+    if err != nil {
+        return nil, err
+    }
+    if f == nil {
+        return false, nil
+    }
+    r.filtersMu.Lock()
+    delete(r.filters, id)
+    r.filtersMu.Unlock()
+    return true, nil
+}
+
+func (r *Relay) getFilterLogs(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    _, f, err := r.lookupFilter(params)
+    if err != nil {
+        return nil, err
+    }
+    if f == nil || f.kind != filterTypeLog {
+        return nil, errFilterNotFound
+    }
+    return r.queryLogs(ctx, f.criteria)
+}
+
+// getFilterChanges returns what happened since the previous poll. Like the
+// relay, log filters resume from the block after the last returned log and
+// block filters from the last returned block.
+func (r *Relay) getFilterChanges(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    id, f, err := r.lookupFilter(params)
+    if err != nil {
+        return nil, err
+    }
+    if f == nil {
+        return nil, errFilterNotFound
+    }
+
+    latest, err := r.backend.Client().BlockNumber(ctx)
+    if err != nil {
+        return nil, err
+    }
+    var result interface{}
+    var lastQueried uint64
+    switch f.kind {
+    case filterTypeLog:
+        criteria := f.criteria
+        if f.lastQueried != 0 {
+            criteria.FromBlock = hexutil.EncodeUint64(f.lastQueried)
+        }
+        logs, err := r.queryLogs(ctx, criteria)
+        if err != nil {
+            return nil, err
+        }
+        lastQueried = latest + 1
+        var entries []logBlockNumber
+        if err := json.Unmarshal(logs, &entries); err == nil && len(entries) > 0 {
+            lastQueried = uint64(entries[len(entries)-1].BlockNumber) + 1
+        }
+        result = logs
+    case filterTypeNewBlock:
+        from := f.blockAtCreation
+        if f.lastQueried != 0 {
+            from = f.lastQueried
+        }
+        hashes := []string{}
+        for number := from + 1; number <= latest; number++ {
+            header, err := r.backend.Client().HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+            if err != nil {
+                return nil, err
+            }
+            hashes = append(hashes, header.Hash().Hex())
+        }
+        lastQueried = latest
+        result = hashes
+    }
+
+    r.filtersMu.Lock()
+    if current, ok := r.filters[id]; ok {
+        current.lastQueried = lastQueried
+    }
+    r.filtersMu.Unlock()
+    return result, nil
+}
+
+func (r *Relay) queryLogs(ctx context.Context, criteria logCriteria) (json.RawMessage, error) {
+    encoded, err := json.Marshal(criteria)
+    if err != nil {
+        return nil, err
+    }
+    logs, err := r.forward(ctx, "eth_getLogs", []json.RawMessage{encoded})
+    if err != nil {
+        return nil, err
+    }
+    if string(logs) == "null" {
+        return json.RawMessage("[]"), nil
+    }
+    return logs, nil
+}
@@ -0,0 +1,140 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package mockrelay
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// DefaultHbarBudget mirrors the relay's HBAR_RATE_LIMIT_TINYBAR default,
+// 8000 HBAR.
+const DefaultHbarBudget = 800_000_000_000
+
+// The mock charges the operator a flat fee for every transaction plus a fee
+// per byte of call data, standing in for the network fees the relay pays,
+// which grow with the size of the transaction.
+const (
+    transactionExpense = 5_000_000
+    callDataExpense    = 10_000
+)
+
+var errHbarRateLimited = &Error{Code: hedera.HbarRateLimitedCode, Message: hedera.HbarRateLimitedMessage}
+
+// hbarLimiter tracks the HBAR the operator spends the way the relay's
+// HbarLimitService does: every transaction is charged to the total budget
+// and to the spending plan of its sender, found by EVM address, then by IP,
+// and otherwise created as a BASIC plan of the address. The budgets never
+// reset, as the mock does not outlive HBAR_RATE_LIMIT_DURATION.
+type hbarLimiter struct {
+    mu        sync.Mutex
+    limits    map[string]int64
+    budget    int64
+    spent     int64
+    addresses map[common.Address]*hbarPlan
+    ips       map[string]*hbarPlan
+}
+
+type hbarPlan struct {
+    tier  string
+    spent int64
+}
+
+func newHbarLimiter(config Config) *hbarLimiter {
+    l := &hbarLimiter{
+        limits:    config.HbarLimits,
+        budget:    config.HbarBudget,
+        addresses: map[common.Address]*hbarPlan{},
+        ips:       map[string]*hbarPlan{},
+    }
+    for _, plan := range config.SpendingPlans {
+        shared := &hbarPlan{tier: plan.SubscriptionTier}
+        for _, address := range plan.EvmAddresses {
+            l.addresses[common.HexToAddress(address)] = shared
+        }
+        for _, ip := range plan.IpAddresses {
+            l.ips[ip] = shared
+        }
+    }
+    return l
+}
+
+func (l *hbarLimiter) planOf(address common.Address, ip string) *hbarPlan {
+    if plan, ok := l.addresses[address]; ok {
+        return plan
+    }
+    if plan, ok := l.ips[ip]; ok {
+        return plan
+    }
+    plan := &hbarPlan{tier: hedera.TierBasic}
+    l.addresses[address] = plan
+    return plan
+}
+
+// shouldLimit reports whether the total budget or the budget of the plan
+// of the sender is spent. Nothing is limited without tier limits.
+func (l *hbarLimiter) shouldLimit(address common.Address, ip string) bool {
+    if l.limits == nil {
+        return false
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.spent >= l.budget {
+        return true
+    }
+    plan := l.planOf(address, ip)
+    return l.limits[plan.tier] <= plan.spent
+}
+
+func (l *hbarLimiter) addExpense(expense int64, address common.Address, ip string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.spent += expense
+    l.planOf(address, ip).spent += expense
+}
+
+func (l *hbarLimiter) remaining() int64 {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.budget - l.spent
+}
+
+func expenseOf(tx *types.Transaction) int64 {
+    return transactionExpense + callDataExpense*int64(len(tx.Data()))
+}
+
+// serveMetrics serves the remaining HBAR budget in the Prometheus text
+// format, like the relay's /metrics.
+func (r *Relay) serveMetrics(w http.ResponseWriter) {
+    var metrics strings.Builder
+    fmt.Fprintf(&metrics, "# HELP %s Relay Hbar rate limit remaining budget\n", hedera.HbarRemainingMetric)
+    fmt.Fprintf(&metrics, "# TYPE %s gauge\n", hedera.HbarRemainingMetric)
+    fmt.Fprintf(&metrics, "%s %d\n", hedera.HbarRemainingMetric, r.hbar.remaining())
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write([]byte(metrics.String()))
+}
@@ -0,0 +1,100 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package mockrelay
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// DefaultLogsBlockRangeLimit mirrors the relay's
+// ETH_GET_LOGS_BLOCK_RANGE_LIMIT default.
+const DefaultLogsBlockRangeLimit = 1000
+
+var errInvalidBlockRange = &Error{Code: -39013, Message: "Invalid block range"}
+
+type logQuery struct {
+    logCriteria
+    BlockHash *common.Hash `json:"blockHash,omitempty"`
+}
+
+// getLogs checks the block range the way the relay does before asking the
+// chain: fromBlock must not be after toBlock, and only queries of a single
+// address may span more blocks than the limit.
+func (r *Relay) getLogs(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    var query logQuery
+    if len(params) > 0 {
+        if err := json.Unmarshal(params[0], &query); err != nil {
+            return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+        }
+    }
+    if query.BlockHash == nil {
+        latest, err := r.backend.Client().BlockNumber(ctx)
+        if err != nil {
+            return nil, err
+        }
+        from, err := resolveBlockTag(query.FromBlock, latest)
+        if err != nil {
+            return nil, err
+        }
+        to, err := resolveBlockTag(query.ToBlock, latest)
+        if err != nil {
+            return nil, err
+        }
+        if from > to {
+            return nil, errInvalidBlockRange
+        }
+        if !singleAddress(query.Address) && to-from > r.config.LogsBlockRangeLimit {
+            return nil, &Error{Code: -32000, Message: fmt.Sprintf("Exceeded maximum block range: %d", r.config.LogsBlockRangeLimit)}
+        }
+    }
+    return r.forward(ctx, "eth_getLogs", r.resolveFilterAddresses(params))
+}
+
+// resolveBlockTag turns a block parameter into a number. Missing parameters
+// and every tag but earliest mean the latest block, as on the relay.
+func resolveBlockTag(tag string, latest uint64) (uint64, error) {
+    switch tag {
+    case "", "latest", "pending", "safe", "finalized":
+        return latest, nil
+    case "earliest":
+        return 0, nil
+    }
+    number, err := hexutil.DecodeUint64(tag)
+    if err != nil {
+        return 0, &Error{Code: -32602, Message: fmt.Sprintf("Invalid block number %q", tag)}
+    }
+    return number, nil
+}
+
+func singleAddress(address json.RawMessage) bool {
+    var one common.Address
+    if err := json.Unmarshal(address, &one); err == nil {
+        return true
+    }
+    var many []common.Address
+    return json.Unmarshal(address, &many) == nil && len(many) == 1
+}
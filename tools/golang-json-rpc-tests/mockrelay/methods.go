@@ -0,0 +1,558 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "regexp"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const (
+    // maxTransactionGas mirrors the relay's MAX_TRANSACTION_FEE_THRESHOLD.
+    maxTransactionGas = 15_000_000
+    // maxTransactionSize mirrors the relay's SEND_RAW_TRANSACTION_SIZE_LIMIT.
+    maxTransactionSize = 131072
+    // feeHistoryMaxResults mirrors the relay's FEE_HISTORY_MAX_RESULTS.
+    feeHistoryMaxResults = 10
+
+    txBaseCost     = 21_000
+    txDataZeroCost = 4
+    txDataNonZero  = 16
+
+    clientVersion = "relay/0.0.0-mock"
+
+    hexError = "Expected 0x prefixed hexadecimal value"
+)
+
+var (
+    hexPattern         = regexp.MustCompile(`^0[xX][a-fA-F0-9]*$`)
+    blockNumberPattern = regexp.MustCompile(`^0[xX]([1-9A-Fa-f][0-9A-Fa-f]{0,13}|0)$`)
+    blockHashPattern   = regexp.MustCompile(`^0[xX][a-fA-F0-9]{64}$`)
+)
+
+type methodHandler func(ctx context.Context, params []json.RawMessage) (interface{}, error)
+
+// forwardedMethods are answered by the simulated chain unchanged.
+var forwardedMethods = []string{
+    "eth_blockNumber",
+    "eth_call",
+    "eth_estimateGas",
+    "eth_getBlockByHash",
+    "eth_getBlockByNumber",
+    "eth_getBlockTransactionCountByHash",
+    "eth_getBlockTransactionCountByNumber",
+    "eth_getStorageAt",
+    "eth_getTransactionByBlockHashAndIndex",
+    "eth_getTransactionByBlockNumberAndIndex",
+    "eth_getTransactionByHash",
+    "eth_getTransactionReceipt",
+}
+
+// stateMethods take a block parameter at the given index. The relay reads
+// state from the mirror node, where pending, safe and finalized are all the
+// latest block.
+var stateMethods = map[string]int{
+    "eth_call":                1,
+    "eth_getBalance":          1,
+    "eth_getCode":             1,
+    "eth_getStorageAt":        2,
+    "eth_getTransactionCount": 1,
+}
+
+var (
+    errUnsupportedMethod = &Error{Code: -32601, Message: "Unsupported JSON-RPC method"}
+    errValueTooLow       = &Error{Code: -32602, Message: "Value can't be non-zero and less than 10_000_000_000 wei which is 1 tinybar"}
+    errUnsupportedTxType = &Error{Code: -32611, Message: "Unsupported transaction type"}
+    errInsufficientFunds = &Error{Code: -32000, Message: "Insufficient funds for transfer"}
+)
+
+func (r *Relay) methodTable() map[string]methodHandler {
+    methods := map[string]methodHandler{}
+    for _, method := range forwardedMethods {
+        method := method
+        methods[method] = func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+            return r.forward(ctx, method, params)
+        }
+    }
+
+    methods["eth_chainId"] = constant((*hexutil.Big)(r.ChainId()))
+    methods["eth_gasPrice"] = constant((*hexutil.Big)(r.config.GasPrice))
+    methods["eth_maxPriorityFeePerGas"] = constant("0x0")
+    methods["eth_accounts"] = constant([]string{})
+    methods["eth_syncing"] = constant(false)
+    methods["eth_mining"] = constant(false)
+    methods["eth_hashrate"] = constant("0x0")
+    methods["eth_submitWork"] = constant(false)
+    methods["eth_getUncleByBlockHashAndIndex"] = constant(nil)
+    methods["eth_getUncleByBlockNumberAndIndex"] = constant(nil)
+    methods["eth_getUncleCountByBlockHash"] = constant("0x0")
+    methods["eth_getUncleCountByBlockNumber"] = constant("0x0")
+    methods["net_listening"] = constant("false")
+    methods["net_version"] = constant(r.ChainId().String())
+    methods["web3_clientVersion"] = constant(clientVersion)
+    for _, method := range []string{
+        "eth_coinbase",
+        "eth_getWork",
+        "eth_newPendingTransactionFilter",
+        "eth_protocolVersion",
+        "eth_sendTransaction",
+        "eth_sign",
+        "eth_signTransaction",
+        "eth_submitHashrate",
+        "net_peerCount",
+    } {
+        methods[method] = unsupported
+    }
+
+    methods["web3_sha3"] = sha3
+    methods["eth_newFilter"] = r.newFilter
+    methods["eth_newBlockFilter"] = r.newBlockFilter
+    methods["eth_uninstallFilter"] = r.uninstallFilter
+    methods["eth_getFilterLogs"] = r.getFilterLogs
+    methods["eth_getFilterChanges"] = r.getFilterChanges
+    methods["eth_getBalance"] = r.getBalance
+    for _, method := range []string{"eth_getCode", "eth_getTransactionCount"} {
+        method := method
+        methods[method] = func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+            return r.forward(ctx, method, r.resolveAddressParam(params, 0))
+        }
+    }
+    methods["eth_feeHistory"] = r.feeHistory
+    methods["eth_getLogs"] = r.getLogs
+    methods["eth_sendRawTransaction"] = r.sendRawTransaction
+    methods["debug_traceTransaction"] = r.traceTransaction
+    for method, index := range stateMethods {
+        handler, index := methods[method], index
+        method := method
+        methods[method] = func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+            if err := checkBlockParam(method, params, index); err != nil {
+                return nil, err
+            }
+            return handler(ctx, latestBlockParam(params, index))
+        }
+    }
+    return methods
+}
+
+// latestBlockParam rewrites the pending, safe and finalized tags at index
+// to latest.
+func latestBlockParam(params []json.RawMessage, index int) []json.RawMessage {
+    if len(params) <= index {
+        return params
+    }
+    var tag string
+    if err := json.Unmarshal(params[index], &tag); err != nil {
+        return params
+    }
+    switch tag {
+    case "pending", "safe", "finalized":
+    default:
+        return params
+    }
+    rewritten := append([]json.RawMessage{}, params...)
+    rewritten[index] = json.RawMessage(`"latest"`)
+    return rewritten
+}
+
+// checkBlockParam validates the block parameter at index the way the
+// relay's validator does for method.
+func checkBlockParam(method string, params []json.RawMessage, index int) error {
+    if len(params) <= index {
+        return nil
+    }
+    param := params[index]
+    if string(param) == "null" {
+        return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter %d: The value passed is not valid: null.", index)}
+    }
+    var str string
+    isString := json.Unmarshal(param, &str) == nil
+    if !hedera.BlockObjectMethods[method] {
+        if isString && (isBlockNumber(str) || blockHashPattern.MatchString(str)) {
+            return nil
+        }
+        return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter %d: The value passed is not valid: %s. %s OR %s", index, templateString(param), hedera.BlockNumberError, hedera.BlockHashError)}
+    }
+    if isString {
+        if isBlockNumber(str) {
+            return nil
+        }
+        return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter %d: %s, value: %s", index, hedera.BlockParamsError, str)}
+    }
+    keys, object, ok := objectKeys(param)
+    if !ok {
+        return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter %d: %s, value: %s", index, hedera.BlockParamsError, string(param))}
+    }
+    name, property, valid, propertyError := "BlockNumberObject", "blockNumber", isBlockNumber, hedera.BlockNumberError
+    if _, ok := object["blockHash"]; ok {
+        name, property, valid, propertyError = "BlockHashObject", "blockHash", blockHashPattern.MatchString, hedera.BlockHashError
+    }
+    for _, key := range keys {
+        if key != property {
+            return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter '%s' for %s: Unknown parameter", key, name)}
+        }
+    }
+    if value, ok := object[property]; ok {
+        if json.Unmarshal(value, &str) != nil || !valid(str) {
+            return &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter '%s' for %s: %s, value: %s", property, name, propertyError, paramString(value))}
+        }
+    }
+    return nil
+}
+
+// objectKeys decodes a JSON object, with its keys in the order they were
+// sent, which is the order the relay reports unknown keys in.
+func objectKeys(param json.RawMessage) ([]string, map[string]json.RawMessage, bool) {
+    var object map[string]json.RawMessage
+    if json.Unmarshal(param, &object) != nil || object == nil {
+        return nil, nil, false
+    }
+    decoder := json.NewDecoder(bytes.NewReader(param))
+    if _, err := decoder.Token(); err != nil {
+        return nil, nil, false
+    }
+    var keys []string
+    for decoder.More() {
+        token, err := decoder.Token()
+        if err != nil {
+            return nil, nil, false
+        }
+        keys = append(keys, token.(string))
+        var value json.RawMessage
+        if err := decoder.Decode(&value); err != nil {
+            return nil, nil, false
+        }
+    }
+    return keys, object, true
+}
+
+// templateString renders a param the way a JavaScript template string
+// does: strings unquoted, objects as [object Object] and anything else as
+// JSON.
+func templateString(param json.RawMessage) string {
+    var object map[string]json.RawMessage
+    if json.Unmarshal(param, &object) == nil && object != nil {
+        return "[object Object]"
+    }
+    return paramString(param)
+}
+
+// isBlockNumber reports whether the relay's validator accepts param as a
+// blockNumber: a block number of at most 14 hex digits, or a tag.
+func isBlockNumber(param string) bool {
+    switch param {
+    case "earliest", "latest", "pending", "finalized", "safe":
+        return true
+    }
+    return blockNumberPattern.MatchString(param)
+}
+
+// paramString renders a param the way the relay's validator quotes it in
+// errors: strings unquoted and anything else as JSON.
+func paramString(param json.RawMessage) string {
+    var str string
+    if json.Unmarshal(param, &str) == nil {
+        return str
+    }
+    return string(param)
+}
+
+func constant(result interface{}) methodHandler {
+    return func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+        return result, nil
+    }
+}
+
+func unsupported(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    return nil, errUnsupportedMethod
+}
+
+func sha3(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    if len(params) < 1 {
+        return nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    var input hexutil.Bytes
+    if err := json.Unmarshal(params[0], &input); err != nil {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+    }
+    return hexutil.Bytes(crypto.Keccak256(input)), nil
+}
+
+// getBalance reports balances the way Hedera stores them, in whole tinybars,
+// and with the latest balance for a block number or hash within
+// hedera.LatestBlockTolerance of the head.
+func (r *Relay) getBalance(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    var blockParam string
+    if len(params) > 1 && json.Unmarshal(params[1], &blockParam) == nil && strings.HasPrefix(blockParam, "0x") {
+        head, err := r.backend.Client().BlockNumber(ctx)
+        if err != nil {
+            return nil, err
+        }
+        block, err := r.blockNumberParam(ctx, params, 1)
+        if err != nil {
+            return nil, err
+        }
+        if block+hedera.LatestBlockTolerance >= head {
+            params = append([]json.RawMessage{}, params...)
+            params[1] = json.RawMessage(`"latest"`)
+        }
+    }
+    result, err := r.forward(ctx, "eth_getBalance", r.resolveAddressParam(params, 0))
+    if err != nil {
+        return nil, err
+    }
+    var balance hexutil.Big
+    if err := json.Unmarshal(result, &balance); err != nil {
+        return nil, err
+    }
+    var address common.Address
+    if err := json.Unmarshal(params[0], &address); err != nil {
+        return nil, err
+    }
+    adjusted, err := r.hederaBalance(ctx, r.resolveAddress(address), balance.ToInt(), params)
+    if err != nil {
+        return nil, err
+    }
+    return (*hexutil.Big)(adjusted), nil
+}
+
+// feeHistory answers the way the relay does with its default
+// ETH_FEE_HISTORY_FIXED: blockCount is capped at the maximum, a range that
+// would start at or before genesis is clamped to block 1 alone, every
+// gasUsedRatio is 0.5 and every reward is 0. Percentiles are not checked.
+// The relay fills baseFeePerGas with the gas price, which is also the base
+// fee of its blocks; the blocks of the simulated chain keep their own base
+// fees, so each entry takes the base fee of its block instead.
+func (r *Relay) feeHistory(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    if len(params) < 2 {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Missing value for required parameter %d", len(params))}
+    }
+    var countParam string
+    if json.Unmarshal(params[0], &countParam) != nil || !hexPattern.MatchString(countParam) {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %s, value: %s", hexError, paramString(params[0]))}
+    }
+    blockCount, _ := new(big.Int).SetString(countParam[2:], 16)
+    if blockCount == nil {
+        blockCount = new(big.Int)
+    }
+    var newest string
+    if json.Unmarshal(params[1], &newest) != nil || !isBlockNumber(newest) {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 1: %s, value: %s", hedera.BlockNumberError, paramString(params[1]))}
+    }
+    var percentiles []json.RawMessage
+    if len(params) > 2 && string(params[2]) != "null" {
+        if err := json.Unmarshal(params[2], &percentiles); err != nil {
+            return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 2: Expected Array, value: %s", paramString(params[2]))}
+        }
+    }
+
+    head, err := r.backend.Client().BlockNumber(ctx)
+    if err != nil {
+        return nil, err
+    }
+    newestNumber := head
+    switch newest {
+    case "latest", "pending", "safe", "finalized":
+    case "earliest":
+        newestNumber = 0
+    default:
+        newestNumber = hexutil.MustDecodeUint64(newest)
+    }
+    if newestNumber > head {
+        return nil, &Error{Code: -32000, Message: fmt.Sprintf("Request beyond head block: requested %d, head %d", newestNumber, head)}
+    }
+    count := uint64(feeHistoryMaxResults)
+    if blockCount.IsUint64() && blockCount.Uint64() < count {
+        count = blockCount.Uint64()
+    }
+    if count == 0 {
+        return map[string]interface{}{"gasUsedRatio": nil, "oldestBlock": "0x0"}, nil
+    }
+    oldest := int64(newestNumber) - int64(count) + 1
+    if oldest <= 0 {
+        count, oldest = 1, 1
+    }
+
+    history := map[string]interface{}{"oldestBlock": hexutil.Uint64(oldest)}
+    baseFees := make([]*hexutil.Big, 0, count+1)
+    ratios := make([]float64, 0, count)
+    for number := uint64(oldest); number <= uint64(oldest)+count; number++ {
+        if number > head {
+            // The next base fee is predicted to stay the same.
+            baseFees = append(baseFees, baseFees[len(baseFees)-1])
+        } else {
+            header, err := r.backend.Client().HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+            if err != nil {
+                return nil, err
+            }
+            baseFees = append(baseFees, (*hexutil.Big)(header.BaseFee))
+        }
+        if number < uint64(oldest)+count {
+            ratios = append(ratios, 0.5)
+        }
+    }
+    history["baseFeePerGas"] = baseFees
+    history["gasUsedRatio"] = ratios
+    if len(percentiles) > 0 {
+        rewards := make([][]string, count)
+        for i := range rewards {
+            rewards[i] = make([]string, len(percentiles))
+            for j := range rewards[i] {
+                rewards[i][j] = "0x0"
+            }
+        }
+        history["reward"] = rewards
+    }
+    return history, nil
+}
+
+// sendRawTransaction runs the relay prechecks, submits the transaction and
+// seals it into a block straight away, as Hedera reaches finality within
+// seconds and has no public mempool.
+func (r *Relay) sendRawTransaction(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    if len(params) < 1 {
+        return nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    var raw hexutil.Bytes
+    if err := json.Unmarshal(params[0], &raw); err != nil {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+    }
+    if len(raw) > maxTransactionSize {
+        return nil, &Error{Code: -32201, Message: fmt.Sprintf("Oversized data: transaction size %d, transaction limit %d", len(raw), maxTransactionSize)}
+    }
+    tx := new(types.Transaction)
+    if err := tx.UnmarshalBinary(raw); err != nil {
+        return nil, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    from, err := r.precheck(ctx, tx)
+    if err != nil {
+        return nil, err
+    }
+    if r.hbar.shouldLimit(from, remoteIP(ctx)) {
+        return nil, errHbarRateLimited
+    }
+    hash, err := r.forward(ctx, "eth_sendRawTransaction", params)
+    if err != nil {
+        return nil, err
+    }
+    r.backend.Commit()
+    r.hbar.addExpense(expenseOf(tx), from, remoteIP(ctx))
+    if err := r.recordValueRemainder(ctx, tx, from); err != nil {
+        return nil, err
+    }
+    return hash, nil
+}
+
+// precheck mirrors the checks the relay performs before submitting a
+// transaction to the network, in the same order and with the same errors,
+// and returns the sender.
+func (r *Relay) precheck(ctx context.Context, tx *types.Transaction) (common.Address, error) {
+    if tx.Type() == types.BlobTxType {
+        return common.Address{}, errUnsupportedTxType
+    }
+
+    intrinsicGas := intrinsicGasCost(tx.Data())
+    if tx.Gas() > maxTransactionGas {
+        return common.Address{}, &Error{Code: -32005, Message: fmt.Sprintf("Transaction gas limit '%d' exceeds max gas per sec limit '%d'", tx.Gas(), maxTransactionGas)}
+    }
+    if tx.Gas() < intrinsicGas {
+        return common.Address{}, &Error{Code: -32003, Message: fmt.Sprintf("Transaction gas limit provided '%d' is insufficient of intrinsic gas required '%d'", tx.Gas(), intrinsicGas)}
+    }
+
+    signer := types.LatestSignerForChainID(tx.ChainId())
+    if !tx.Protected() {
+        signer = types.HomesteadSigner{}
+    }
+    from, err := types.Sender(signer, tx)
+    if err != nil {
+        return common.Address{}, &Error{Code: -32602, Message: fmt.Sprintf("Invalid parameter 0: %v", err)}
+    }
+    nonce, err := r.backend.Client().NonceAt(ctx, from, nil)
+    if err != nil {
+        return common.Address{}, err
+    }
+    if tx.Nonce() < nonce {
+        return common.Address{}, &Error{Code: 32001, Message: fmt.Sprintf("Nonce too low. Provided nonce: %d, current nonce: %d", tx.Nonce(), nonce)}
+    }
+    if tx.Nonce() > nonce {
+        return common.Address{}, &Error{Code: 32002, Message: fmt.Sprintf("Nonce too high. Provided nonce: %d, current nonce: %d", tx.Nonce(), nonce)}
+    }
+
+    if tx.Protected() && tx.ChainId().Cmp(r.ChainId()) != 0 {
+        return common.Address{}, &Error{Code: -32000, Message: fmt.Sprintf("ChainId (%s) not supported. The correct chainId is %s", hexutil.EncodeBig(tx.ChainId()), hexutil.EncodeBig(r.ChainId()))}
+    }
+
+    tinybar := big.NewInt(hedera.WeibarsPerTinybar)
+    if tx.Value().Sign() > 0 && tx.Value().Cmp(tinybar) < 0 {
+        return common.Address{}, errValueTooLow
+    }
+
+    gasPrice := txGasPrice(tx)
+    withBuffer := new(big.Int).Add(gasPrice, tinybar)
+    if withBuffer.Cmp(r.config.GasPrice) < 0 {
+        return common.Address{}, &Error{Code: -32009, Message: fmt.Sprintf("Gas price '%s' is below configured minimum gas price '%s'", gasPrice, r.config.GasPrice)}
+    }
+
+    balance, err := r.backend.Client().BalanceAt(ctx, from, nil)
+    if err != nil {
+        return common.Address{}, err
+    }
+    total := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(tx.Gas()))
+    total.Add(total, tx.Value())
+    if toTinybarGranularity(balance).Cmp(total) < 0 {
+        return common.Address{}, errInsufficientFunds
+    }
+    return from, nil
+}
+
+// txGasPrice is the gas price the relay checks: the legacy gas price, or the
+// sum of the fee cap and tip for EIP-1559 transactions.
+func txGasPrice(tx *types.Transaction) *big.Int {
+    if tx.Type() == types.DynamicFeeTxType {
+        return new(big.Int).Add(tx.GasFeeCap(), tx.GasTipCap())
+    }
+    return tx.GasPrice()
+}
+
+func intrinsicGasCost(data []byte) uint64 {
+    gas := uint64(txBaseCost)
+    for _, b := range data {
+        if b == 0 {
+            gas += txDataZeroCost
+        } else {
+            gas += txDataNonZero
+        }
+    }
+    return gas
+}
@@ -0,0 +1,169 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package mockrelay
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "hedera-json-rpc-golang-tests-project/entity"
+)
+
+// firstEntityNum is the number given to the first address looked up, past
+// the system accounts of a Hedera network.
+const firstEntityNum = 1001
+
+// mirrorPrefix is where the mock serves the few mirror node REST endpoints
+// the harness needs to learn entity IDs.
+const mirrorPrefix = "/api/v1/"
+
+// entities gives every address an entity ID the first time it is looked
+// up, standing in for the IDs Hedera assigns when accounts and contracts
+// are created.
+type entities struct {
+    nums      map[common.Address]uint64
+    addresses map[uint64]common.Address
+}
+
+// entityOf returns the entity ID of an EVM address, assigning one if it has
+// none yet.
+func (r *Relay) entityOf(address common.Address) entity.ID {
+    r.entitiesMu.Lock()
+    defer r.entitiesMu.Unlock()
+    num, ok := r.entities.nums[address]
+    if !ok {
+        num = firstEntityNum + uint64(len(r.entities.nums))
+        r.entities.nums[address] = num
+        r.entities.addresses[num] = address
+    }
+    return entity.ID{Num: num}
+}
+
+// resolveAddress returns the EVM address behind a long-zero address of a
+// known entity, and any other address unchanged.
+func (r *Relay) resolveAddress(address common.Address) common.Address {
+    id, ok := entity.FromLongZeroAddress(address)
+    if !ok {
+        return address
+    }
+    r.entitiesMu.Lock()
+    defer r.entitiesMu.Unlock()
+    if evmAddress, ok := r.entities.addresses[id.Num]; ok {
+        return evmAddress
+    }
+    return address
+}
+
+// resolveAddressParam rewrites the address parameter at index, or the
+// address field of a filter object, so long-zero addresses reach the chain
+// as the EVM address of their entity.
+func (r *Relay) resolveAddressParam(params []json.RawMessage, index int) []json.RawMessage {
+    if len(params) <= index {
+        return params
+    }
+    var address common.Address
+    if err := json.Unmarshal(params[index], &address); err != nil {
+        return params
+    }
+    encoded, _ := json.Marshal(r.resolveAddress(address))
+    resolved := append([]json.RawMessage{}, params...)
+    resolved[index] = encoded
+    return resolved
+}
+
+func (r *Relay) resolveFilterAddresses(params []json.RawMessage) []json.RawMessage {
+    if len(params) < 1 {
+        return params
+    }
+    var query map[string]json.RawMessage
+    if err := json.Unmarshal(params[0], &query); err != nil || query["address"] == nil {
+        return params
+    }
+    var addresses []common.Address
+    if err := json.Unmarshal(query["address"], &addresses); err != nil {
+        var address common.Address
+        if err := json.Unmarshal(query["address"], &address); err != nil {
+            return params
+        }
+        addresses = []common.Address{address}
+    }
+    for i, address := range addresses {
+        addresses[i] = r.resolveAddress(address)
+    }
+    query["address"], _ = json.Marshal(addresses)
+    encoded, _ := json.Marshal(query)
+    return append([]json.RawMessage{encoded}, params[1:]...)
+}
+
+// serveMirror answers /api/v1/accounts/{address} and
+// /api/v1/contracts/{address} with the entity ID and EVM address, where the
+// address is an EVM address, a long-zero address or an entity ID.
+func (r *Relay) serveMirror(w http.ResponseWriter, req *http.Request) {
+    path := strings.TrimPrefix(req.URL.Path, mirrorPrefix)
+    kind, param, _ := strings.Cut(path, "/")
+    idField := map[string]string{"accounts": "account", "contracts": "contract_id"}[kind]
+    if idField == "" || param == "" {
+        writeMirrorNotFound(w)
+        return
+    }
+
+    var address common.Address
+    if id, err := entity.Parse(param); err == nil {
+        address = r.resolveAddress(id.LongZeroAddress())
+    } else if common.IsHexAddress(param) {
+        address = r.resolveAddress(common.HexToAddress(param))
+    } else {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusBadRequest)
+        json.NewEncoder(w).Encode(mirrorMessages("Invalid parameter: idOrAliasOrEvmAddress"))
+        return
+    }
+    if kind == "contracts" {
+        code, err := r.backend.Client().CodeAt(req.Context(), address, nil)
+        if err != nil || len(code) == 0 {
+            writeMirrorNotFound(w)
+            return
+        }
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{
+        idField:       r.entityOf(address).String(),
+        "evm_address": hexutil.Encode(address[:]),
+    })
+}
+
+func writeMirrorNotFound(w http.ResponseWriter) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusNotFound)
+    json.NewEncoder(w).Encode(mirrorMessages("Not found"))
+}
+
+func mirrorMessages(message string) interface{} {
+    return map[string]interface{}{
+        "_status": map[string]interface{}{
+            "messages": []map[string]string{{"message": message}},
+        },
+    }
+}
@@ -0,0 +1,103 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package mockrelay
+
+import (
+    "context"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// DefaultLimitDuration mirrors the relay's LIMIT_DURATION default.
+const DefaultLimitDuration = time.Minute
+
+// remoteIPKey holds the IP of an HTTP client in the request context.
+type remoteIPKey struct{}
+
+func withRemoteIP(ctx context.Context, req *http.Request) context.Context {
+    ip, _, err := net.SplitHostPort(req.RemoteAddr)
+    if err != nil {
+        ip = req.RemoteAddr
+    }
+    return context.WithValue(ctx, remoteIPKey{}, ip)
+}
+
+// remoteIP returns the IP of an HTTP client, or "" over WebSocket.
+func remoteIP(ctx context.Context) string {
+    ip, _ := ctx.Value(remoteIPKey{}).(string)
+    return ip
+}
+
+// rateLimiter counts requests the way the relay's rateLimit module does.
+// Every IP has one window, opened by its first request and reset by the
+// first request after it ends, in which each method has its own count.
+type rateLimiter struct {
+    mu       sync.Mutex
+    limits   map[string]int
+    duration time.Duration
+    windows  map[string]*rateLimitWindow
+}
+
+type rateLimitWindow struct {
+    reset     time.Time
+    remaining map[string]int
+}
+
+// allow counts a request of method from ip and reports whether it is within
+// the limit. Methods without a limit are always allowed.
+func (l *rateLimiter) allow(ip string, method string) bool {
+    limit, ok := l.limits[method]
+    if !ok {
+        return true
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := time.Now()
+    window, ok := l.windows[ip]
+    if !ok || now.After(window.reset) {
+        window = &rateLimitWindow{reset: now.Add(l.duration), remaining: map[string]int{}}
+        l.windows[ip] = window
+    }
+    remaining, ok := window.remaining[method]
+    if !ok {
+        remaining = limit
+    }
+    if remaining == 0 {
+        return false
+    }
+    window.remaining[method] = remaining - 1
+    return true
+}
+
+// rateLimit rejects a request over the limit of the IP it came from.
+// Requests over WebSocket are not limited.
+func (r *Relay) rateLimit(ctx context.Context, method string) error {
+    ip := remoteIP(ctx)
+    if ip == "" || r.limiter.allow(ip, method) {
+        return nil
+    }
+    return &Error{Code: hedera.IpRateLimitedCode, Message: hedera.IpRateLimitedMessage(method)}
+}
@@ -0,0 +1,467 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package mockrelay serves the JSON-RPC surface of the Hedera JSON RPC Relay
+// over HTTP and WebSocket from an in-memory chain, so the Go tools can run
+// without a network. The chain is a go-ethereum dev chain; methods
+// whose behaviour differs on Hedera are answered the way the relay answers
+// them.
+package mockrelay
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/eth/ethconfig"
+    "github.com/ethereum/go-ethereum/node"
+    "github.com/ethereum/go-ethereum/params"
+    "github.com/ethereum/go-ethereum/rpc"
+    "github.com/gorilla/websocket"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const maxRequestSize = 5 * 1024 * 1024
+
+const (
+    // batchMaxSize mirrors the relay's BATCH_REQUESTS_MAX_SIZE.
+    batchMaxSize = 100
+    // wsBatchMaxSize mirrors the relay's WS_BATCH_REQUESTS_MAX_SIZE.
+    wsBatchMaxSize = 20
+)
+
+// batchDisallowedMethods mirrors the relay's
+// BATCH_REQUESTS_DISALLOWED_METHODS.
+var batchDisallowedMethods = map[string]bool{
+    "debug_traceTransaction":          true,
+    "eth_newFilter":                   true,
+    "eth_uninstallFilter":             true,
+    "eth_getFilterChanges":            true,
+    "eth_getFilterLogs":               true,
+    "eth_newBlockFilter":              true,
+    "eth_newPendingTransactionFilter": true,
+}
+
+// DefaultGasPrice is the gas price of the Hedera networks, 71 tinybars.
+var DefaultGasPrice = big.NewInt(71 * hedera.WeibarsPerTinybar)
+
+// Config configures a mock relay.
+type Config struct {
+    // ChainId is reported by eth_chainId and net_version. Defaults to
+    // hedera.LocalChainId.
+    ChainId int64
+    // GasPrice is the network gas price in weibars. Defaults to
+    // DefaultGasPrice.
+    GasPrice *big.Int
+    // Accounts are funded at genesis with the given balances in weibars,
+    // truncated to whole tinybars.
+    Accounts map[common.Address]*big.Int
+    // FilterTTL is how long a polling filter lives after it was last
+    // queried. Defaults to DefaultFilterTTL.
+    FilterTTL time.Duration
+    // LogsBlockRangeLimit is the most blocks eth_getLogs spans for queries
+    // of more than one address. Defaults to DefaultLogsBlockRangeLimit.
+    LogsBlockRangeLimit uint64
+    // RateLimits is the most requests of each method one IP may send over
+    // HTTP per LimitDuration, as the relay's rate limit tiers. Methods not
+    // listed are not limited.
+    RateLimits map[string]int
+    // LimitDuration is the rate limit window. Defaults to
+    // DefaultLimitDuration.
+    LimitDuration time.Duration
+    // SpendingPlans are the preconfigured HBAR spending plans, as in the
+    // relay's HBAR_SPENDING_PLANS_CONFIG.
+    SpendingPlans []hedera.SpendingPlan
+    // HbarLimits is the budget in tinybars of each subscription tier, as the
+    // relay's HBAR_RATE_LIMIT_BASIC, HBAR_RATE_LIMIT_EXTENDED and
+    // HBAR_RATE_LIMIT_PRIVILEGED. HBAR spending is only limited when set.
+    HbarLimits map[string]int64
+    // HbarBudget is the total budget in tinybars, as the relay's
+    // HBAR_RATE_LIMIT_TINYBAR. Defaults to DefaultHbarBudget.
+    HbarBudget int64
+}
+
+// Relay is an in-process stand-in for the Hedera JSON RPC Relay.
+type Relay struct {
+    config  Config
+    backend *simulatedChain
+    client  *rpc.Client
+    ipcDir  string
+    methods map[string]methodHandler
+
+    // mu serialises transaction submission with the block commit that
+    // follows it, so every accepted transaction lands in its own block.
+    mu sync.Mutex
+
+    filtersMu sync.Mutex
+    filters   map[string]*filter
+
+    entitiesMu sync.Mutex
+    entities   entities
+
+    remaindersMu sync.Mutex
+    remainders   map[common.Address][]valueRemainder
+
+    limiter *rateLimiter
+    hbar    *hbarLimiter
+
+    listener net.Listener
+    server   *http.Server
+    upgrader websocket.Upgrader
+}
+
+// New creates a mock relay with a fresh in-memory chain. Call Start to serve
+// it, or use it directly as an http.Handler.
+func New(config Config) (*Relay, error) {
+    if config.ChainId == 0 {
+        config.ChainId = hedera.LocalChainId
+    }
+    if config.GasPrice == nil {
+        config.GasPrice = DefaultGasPrice
+    }
+    if config.FilterTTL == 0 {
+        config.FilterTTL = DefaultFilterTTL
+    }
+    if config.LogsBlockRangeLimit == 0 {
+        config.LogsBlockRangeLimit = DefaultLogsBlockRangeLimit
+    }
+    if config.LimitDuration == 0 {
+        config.LimitDuration = DefaultLimitDuration
+    }
+    if config.HbarBudget == 0 {
+        config.HbarBudget = DefaultHbarBudget
+    }
+
+    alloc := types.GenesisAlloc{}
+    for address, balance := range config.Accounts {
+        alloc[address] = types.Account{Balance: toTinybarGranularity(balance)}
+    }
+    // The chain is reached through a private IPC endpoint to forward calls
+    // generically.
+    ipcDir, err := os.MkdirTemp("", "mockrelay")
+    if err != nil {
+        return nil, err
+    }
+    ipcPath := filepath.Join(ipcDir, "geth.ipc")
+    backend, err := newSimulatedChain(alloc, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+        chainConfig := *params.AllDevChainProtocolChanges
+        chainConfig.ChainID = big.NewInt(config.ChainId)
+        ethConf.Genesis.Config = &chainConfig
+        ethConf.NetworkId = uint64(config.ChainId)
+        // Hedera transaction fees routinely exceed go-ethereum's 1 ether cap,
+        // and the relay accepts pre-EIP-155 transactions.
+        ethConf.RPCTxFeeCap = 0
+        nodeConf.AllowUnprotectedTxs = true
+        nodeConf.IPCPath = ipcPath
+    })
+    if err != nil {
+        os.RemoveAll(ipcDir)
+        return nil, err
+    }
+    client, err := rpc.Dial(ipcPath)
+    if err != nil {
+        backend.Close()
+        os.RemoveAll(ipcDir)
+        return nil, err
+    }
+
+    r := &Relay{
+        config:     config,
+        backend:    backend,
+        client:     client,
+        ipcDir:     ipcDir,
+        filters:    map[string]*filter{},
+        remainders: map[common.Address][]valueRemainder{},
+        entities: entities{
+            nums:      map[common.Address]uint64{},
+            addresses: map[uint64]common.Address{},
+        },
+        limiter: &rateLimiter{
+            limits:   config.RateLimits,
+            duration: config.LimitDuration,
+            windows:  map[string]*rateLimitWindow{},
+        },
+        hbar: newHbarLimiter(config),
+    }
+    r.methods = r.methodTable()
+    return r, nil
+}
+
+// Start serves the relay on addr, for example "127.0.0.1:0". HTTP requests
+// and WebSocket upgrades are both accepted on the same address.
+func (r *Relay) Start(addr string) error {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    r.listener = listener
+    r.server = &http.Server{Handler: r}
+    go r.server.Serve(listener)
+    return nil
+}
+
+// URL returns the HTTP endpoint of a started relay.
+func (r *Relay) URL() string {
+    return "http://" + r.listener.Addr().String()
+}
+
+// WSURL returns the WebSocket endpoint of a started relay.
+func (r *Relay) WSURL() string {
+    return "ws://" + r.listener.Addr().String()
+}
+
+// ChainId returns the chain ID the relay reports.
+func (r *Relay) ChainId() *big.Int {
+    return big.NewInt(r.config.ChainId)
+}
+
+// Close stops the server and discards the chain.
+func (r *Relay) Close() error {
+    var err error
+    if r.server != nil {
+        err = r.server.Close()
+    }
+    r.client.Close()
+    err = errors.Join(err, r.backend.Close())
+    return errors.Join(err, os.RemoveAll(r.ipcDir))
+}
+
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    if websocket.IsWebSocketUpgrade(req) {
+        r.serveWebsocket(w, req)
+        return
+    }
+    if req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, mirrorPrefix) {
+        r.serveMirror(w, req)
+        return
+    }
+    if req.Method == http.MethodGet && req.URL.Path == "/metrics" {
+        r.serveMetrics(w)
+        return
+    }
+    if req.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    body, err := io.ReadAll(io.LimitReader(req.Body, maxRequestSize))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    result, status := r.handleMessage(withRemoteIP(req.Context(), req), body, nil)
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(result)
+}
+
+// Error is a JSON-RPC error object as returned by the relay.
+type Error struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+    Data    string `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+    return e.Message
+}
+
+func (e *Error) ErrorCode() int {
+    return e.Code
+}
+
+type request struct {
+    JSONRPC string          `json:"jsonrpc"`
+    ID      json.RawMessage `json:"id"`
+    Method  string          `json:"method"`
+    Params  json.RawMessage `json:"params"`
+}
+
+type response struct {
+    JSONRPC string          `json:"jsonrpc"`
+    ID      json.RawMessage `json:"id"`
+    Result  json.RawMessage `json:"result,omitempty"`
+    Error   *Error          `json:"error,omitempty"`
+}
+
+// handleMessage answers a single request or a batch, together with the
+// HTTP status to answer it with. A single request that fails is answered
+// with the status the relay maps its error code to. Subscription methods
+// are only available when a WebSocket connection is passed in.
+func (r *Relay) handleMessage(ctx context.Context, body []byte, conn *wsConn) (interface{}, int) {
+    var batch []json.RawMessage
+    if err := json.Unmarshal(body, &batch); err == nil {
+        return r.handleBatch(ctx, batch, conn)
+    }
+    resp := r.handleRequest(ctx, body, conn)
+    if resp.Error != nil {
+        return resp, hedera.HttpStatus(resp.Error.Code)
+    }
+    return resp, http.StatusOK
+}
+
+// handleBatch answers a batch the way the relay does: a batch over the size
+// limit is rejected as a whole, over HTTP with a single error and over
+// WebSocket with a batch holding it, while disallowed methods are rejected
+// one by one.
+func (r *Relay) handleBatch(ctx context.Context, batch []json.RawMessage, conn *wsConn) (interface{}, int) {
+    maxSize := batchMaxSize
+    if conn != nil {
+        maxSize = wsBatchMaxSize
+    }
+    if len(batch) > maxSize {
+        resp := response{
+            JSONRPC: "2.0",
+            ID:      json.RawMessage("null"),
+            Error:   &Error{Code: -32203, Message: fmt.Sprintf("Batch request amount %d exceeds max %d", len(batch), maxSize)},
+        }
+        if conn != nil {
+            return []response{resp}, http.StatusOK
+        }
+        return resp, http.StatusBadRequest
+    }
+
+    responses := make([]response, 0, len(batch))
+    for _, message := range batch {
+        var req request
+        if err := json.Unmarshal(message, &req); err == nil && batchDisallowedMethods[req.Method] {
+            responses = append(responses, response{
+                JSONRPC: "2.0",
+                ID:      req.ID,
+                Error:   &Error{Code: -32007, Message: fmt.Sprintf("Method %s is not permitted as part of batch requests", req.Method)},
+            })
+            continue
+        }
+        responses = append(responses, r.handleRequest(ctx, message, conn))
+    }
+    return responses, http.StatusOK
+}
+
+func (r *Relay) handleRequest(ctx context.Context, message []byte, conn *wsConn) response {
+    var req request
+    if err := json.Unmarshal(message, &req); err != nil {
+        return response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: -32700, Message: "Parse error"}}
+    }
+    if req.ID == nil {
+        req.ID = json.RawMessage("null")
+    }
+    resp := response{JSONRPC: "2.0", ID: req.ID}
+    if req.JSONRPC != "2.0" || req.Method == "" {
+        resp.Error = &Error{Code: -32600, Message: "Invalid Request"}
+        return resp
+    }
+
+    var params []json.RawMessage
+    if len(req.Params) > 0 && string(req.Params) != "null" {
+        if err := json.Unmarshal(req.Params, &params); err != nil {
+            resp.Error = &Error{Code: -32602, Message: "Invalid params"}
+            return resp
+        }
+    }
+
+    result, err := r.call(ctx, req.Method, params, conn)
+    if err != nil {
+        resp.Error = toError(err)
+        return resp
+    }
+    encoded, err := json.Marshal(result)
+    if err != nil {
+        resp.Error = toError(err)
+        return resp
+    }
+    resp.Result = encoded
+    return resp
+}
+
+// call answers a method. Over WebSocket only the methods of the relay's
+// WebSocket server are served.
+func (r *Relay) call(ctx context.Context, method string, params []json.RawMessage, conn *wsConn) (interface{}, error) {
+    if conn != nil && !hedera.WsMethods[method] {
+        return nil, &Error{Code: -32601, Message: fmt.Sprintf("Method %s not found", method)}
+    }
+    if err := r.rateLimit(ctx, method); err != nil {
+        return nil, err
+    }
+    if conn != nil {
+        switch method {
+        case "eth_subscribe":
+            return conn.subscribe(ctx, params)
+        case "eth_unsubscribe":
+            return conn.unsubscribe(params)
+        }
+    }
+    handler, ok := r.methods[method]
+    if !ok {
+        return nil, &Error{Code: -32601, Message: fmt.Sprintf("Method %s not found", method)}
+    }
+    return handler(ctx, params)
+}
+
+// forward passes a call through to the simulated chain unchanged.
+func (r *Relay) forward(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error) {
+    args := make([]interface{}, len(params))
+    for i, param := range params {
+        args[i] = param
+    }
+    var result json.RawMessage
+    if err := r.client.CallContext(ctx, &result, method, args...); err != nil {
+        return nil, err
+    }
+    if result == nil {
+        result = json.RawMessage("null")
+    }
+    return result, nil
+}
+
+func toError(err error) *Error {
+    var relayErr *Error
+    if errors.As(err, &relayErr) {
+        return relayErr
+    }
+    var rpcErr rpc.Error
+    if errors.As(err, &rpcErr) {
+        e := &Error{Code: rpcErr.ErrorCode(), Message: rpcErr.Error()}
+        var dataErr rpc.DataError
+        if errors.As(err, &dataErr) {
+            if data, ok := dataErr.ErrorData().(string); ok {
+                e.Data = data
+            }
+        }
+        return e
+    }
+    return &Error{Code: -32603, Message: "Error invoking RPC: " + err.Error()}
+}
+
+func toTinybarGranularity(weibars *big.Int) *big.Int {
+    tinybar := big.NewInt(hedera.WeibarsPerTinybar)
+    return new(big.Int).Mul(new(big.Int).Quo(weibars, tinybar), tinybar)
+}
@@ -0,0 +1,492 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/ethclient"
+    "github.com/ethereum/go-ethereum/rpc"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+    "hedera-json-rpc-golang-tests-project/entity"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+var operatorBalance = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+
+func setup(t *testing.T, url func(*Relay) string) (*ethclient.Client, *ecdsa.PrivateKey, common.Address) {
+    return setupWithConfig(t, url, Config{ChainId: hedera.TestnetChainId})
+}
+
+func setupWithConfig(t *testing.T, url func(*Relay) string, config Config) (*ethclient.Client, *ecdsa.PrivateKey, common.Address) {
+    privateKey, err := crypto.GenerateKey()
+    require.NoError(t, err)
+    address := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+    config.Accounts = map[common.Address]*big.Int{address: operatorBalance}
+    relay, err := New(config)
+    require.NoError(t, err)
+    require.NoError(t, relay.Start("127.0.0.1:0"))
+    t.Cleanup(func() { relay.Close() })
+
+    client, err := ethclient.Dial(url(relay))
+    require.NoError(t, err)
+    t.Cleanup(client.Close)
+    return client, privateKey, address
+}
+
+func signTransfer(t *testing.T, client *ethclient.Client, privateKey *ecdsa.PrivateKey, from common.Address, value *big.Int) *types.Transaction {
+    nonce, err := client.PendingNonceAt(context.Background(), from)
+    require.NoError(t, err)
+    tx, err := types.SignNewTx(privateKey, types.NewEIP155Signer(big.NewInt(hedera.TestnetChainId)), &types.LegacyTx{
+        Nonce:    nonce,
+        GasPrice: DefaultGasPrice,
+        Gas:      21000,
+        To:       &common.Address{1},
+        Value:    value,
+    })
+    require.NoError(t, err)
+    return tx
+}
+
+// requireHttpError checks that a single request failed with the given HTTP
+// status and JSON-RPC error, which go-ethereum's client returns as an
+// rpc.HTTPError.
+func requireHttpError(t *testing.T, err error, status int, code int, message string) {
+    var httpErr rpc.HTTPError
+    require.ErrorAs(t, err, &httpErr)
+    assert.Equal(t, status, httpErr.StatusCode)
+    var body response
+    require.NoError(t, json.Unmarshal(httpErr.Body, &body))
+    require.NotNil(t, body.Error)
+    assert.Equal(t, code, body.Error.Code)
+    if message != "" {
+        assert.Equal(t, message, body.Error.Message)
+    }
+}
+
+func TestChainIdentity(t *testing.T) {
+    client, _, _ := setup(t, (*Relay).URL)
+
+    chainId, err := client.ChainID(context.Background())
+    require.NoError(t, err)
+    assert.Equal(t, int64(hedera.TestnetChainId), chainId.Int64())
+
+    networkId, err := client.NetworkID(context.Background())
+    require.NoError(t, err)
+    assert.Equal(t, int64(hedera.TestnetChainId), networkId.Int64())
+
+    gasPrice, err := client.SuggestGasPrice(context.Background())
+    require.NoError(t, err)
+    assert.Equal(t, DefaultGasPrice, gasPrice)
+}
+
+func TestSendRawTransactionIsMinedImmediately(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(context.Background(), tx))
+
+    receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+    require.NoError(t, err)
+    assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+    balance, err := client.BalanceAt(context.Background(), common.Address{1}, nil)
+    require.NoError(t, err)
+    assert.Equal(t, big.NewInt(hedera.WeibarsPerTinybar), balance)
+}
+
+func TestSendRawTransactionRejectsSubTinybarValue(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar-1))
+    err := client.SendTransaction(context.Background(), tx)
+
+    requireHttpError(t, err, http.StatusBadRequest, -32602, errValueTooLow.Message)
+}
+
+func TestSubTinybarRemainderIsNotTransferred(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+    ctx := context.Background()
+    to := common.Address{1}
+    before, err := client.BalanceAt(ctx, from, nil)
+    require.NoError(t, err)
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(2*hedera.WeibarsPerTinybar-1))
+    require.NoError(t, client.SendTransaction(ctx, tx))
+
+    received, err := client.BalanceAt(ctx, to, nil)
+    require.NoError(t, err)
+    assert.Equal(t, big.NewInt(hedera.WeibarsPerTinybar), received)
+    after, err := client.BalanceAt(ctx, from, nil)
+    require.NoError(t, err)
+    paid := new(big.Int).Mul(DefaultGasPrice, big.NewInt(21000))
+    paid.Add(paid, big.NewInt(hedera.WeibarsPerTinybar))
+    assert.Equal(t, paid, new(big.Int).Sub(before, after))
+
+    // Move the head past the relay's tolerance for latest balances.
+    require.NoError(t, client.SendTransaction(ctx, signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))))
+    previous, err := client.BalanceAt(ctx, to, big.NewInt(0))
+    require.NoError(t, err)
+    assert.Zero(t, previous.Sign())
+}
+
+func TestBalanceNearHeadIsLatest(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+    ctx := context.Background()
+    to := common.Address{1}
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(ctx, tx))
+    head, err := client.BlockNumber(ctx)
+    require.NoError(t, err)
+
+    // The block before the transfer is within the tolerance of the head.
+    balance, err := client.BalanceAt(ctx, to, new(big.Int).SetUint64(head-1))
+    require.NoError(t, err)
+    assert.Equal(t, big.NewInt(hedera.WeibarsPerTinybar), balance)
+
+    require.NoError(t, client.SendTransaction(ctx, signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))))
+    balance, err = client.BalanceAt(ctx, to, new(big.Int).SetUint64(head-1))
+    require.NoError(t, err)
+    assert.Zero(t, balance.Sign())
+}
+
+func TestBlockTagsAreLatest(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+    ctx := context.Background()
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(ctx, tx))
+
+    for _, tag := range []string{"latest", "pending", "safe", "finalized"} {
+        var balance hexutil.Big
+        require.NoError(t, client.Client().CallContext(ctx, &balance, "eth_getBalance", common.Address{1}, tag))
+        assert.Equal(t, big.NewInt(hedera.WeibarsPerTinybar), balance.ToInt(), tag)
+    }
+    var earliest hexutil.Big
+    require.NoError(t, client.Client().CallContext(ctx, &earliest, "eth_getBalance", common.Address{1}, "earliest"))
+    assert.Zero(t, earliest.ToInt().Sign())
+}
+
+func TestBlockObjectsAreOnlyTakenByCall(t *testing.T) {
+    client, _, from := setup(t, (*Relay).URL)
+    ctx := context.Background()
+    var head struct {
+        Hash common.Hash `json:"hash"`
+    }
+    require.NoError(t, client.Client().CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false))
+    byHash := map[string]interface{}{"blockHash": head.Hash}
+
+    var balance hexutil.Big
+    err := client.Client().CallContext(ctx, &balance, "eth_getBalance", from, byHash)
+    requireHttpError(t, err, http.StatusBadRequest, -32602, "Invalid parameter 1: The value passed is not valid: [object Object]. "+hedera.BlockNumberError+" OR "+hedera.BlockHashError)
+
+    var result hexutil.Bytes
+    call := map[string]interface{}{"from": from, "to": common.Address{1}}
+    require.NoError(t, client.Client().CallContext(ctx, &result, "eth_call", call, byHash))
+    require.NoError(t, client.Client().CallContext(ctx, &result, "eth_call", call, map[string]interface{}{"blockNumber": "latest"}))
+
+    err = client.Client().CallContext(ctx, &result, "eth_call", call, map[string]interface{}{"blockHash": head.Hash, "requireCanonical": true})
+    requireHttpError(t, err, http.StatusBadRequest, -32602, "Invalid parameter 'requireCanonical' for BlockHashObject: Unknown parameter")
+}
+
+func TestUnknownMethod(t *testing.T) {
+    client, _, _ := setup(t, (*Relay).URL)
+
+    var result interface{}
+    err := client.Client().CallContext(context.Background(), &result, "eth_unknownMethod")
+
+    requireHttpError(t, err, http.StatusBadRequest, -32601, "Method eth_unknownMethod not found")
+}
+
+func TestWebsocketServesRelayMethodsOnly(t *testing.T) {
+    client, _, _ := setup(t, (*Relay).WSURL)
+
+    _, err := client.ChainID(context.Background())
+    require.NoError(t, err)
+
+    var result interface{}
+    err = client.Client().CallContext(context.Background(), &result, "eth_feeHistory", "0x1", "latest")
+    var rpcErr rpc.Error
+    require.ErrorAs(t, err, &rpcErr)
+    assert.Equal(t, -32601, rpcErr.ErrorCode())
+    assert.Equal(t, "Method eth_feeHistory not found", rpcErr.Error())
+}
+
+func TestBatchLimits(t *testing.T) {
+    client, _, _ := setup(t, (*Relay).URL)
+
+    batch := []rpc.BatchElem{
+        {Method: "eth_chainId", Result: new(string)},
+        {Method: "eth_newBlockFilter", Result: new(string)},
+    }
+    require.NoError(t, client.Client().BatchCallContext(context.Background(), batch))
+    assert.NoError(t, batch[0].Error)
+    var rpcErr rpc.Error
+    require.ErrorAs(t, batch[1].Error, &rpcErr)
+    assert.Equal(t, -32007, rpcErr.ErrorCode())
+    assert.Equal(t, "Method eth_newBlockFilter is not permitted as part of batch requests", rpcErr.Error())
+
+    oversized := make([]rpc.BatchElem, batchMaxSize+1)
+    for i := range oversized {
+        oversized[i] = rpc.BatchElem{Method: "eth_chainId", Result: new(string)}
+    }
+    err := client.Client().BatchCallContext(context.Background(), oversized)
+    var httpErr rpc.HTTPError
+    require.ErrorAs(t, err, &httpErr)
+    assert.Equal(t, 400, httpErr.StatusCode)
+    assert.Contains(t, string(httpErr.Body), "Batch request amount 101 exceeds max 100")
+}
+
+func TestRateLimit(t *testing.T) {
+    client, _, _ := setupWithConfig(t, (*Relay).URL, Config{
+        RateLimits:    map[string]int{"eth_blockNumber": 2},
+        LimitDuration: 100 * time.Millisecond,
+    })
+    ctx := context.Background()
+    for i := 0; i < 2; i++ {
+        _, err := client.BlockNumber(ctx)
+        require.NoError(t, err)
+    }
+    _, err := client.BlockNumber(ctx)
+    var httpErr rpc.HTTPError
+    require.ErrorAs(t, err, &httpErr)
+    assert.Equal(t, http.StatusConflict, httpErr.StatusCode)
+    assert.Contains(t, string(httpErr.Body), hedera.IpRateLimitedMessage("eth_blockNumber"))
+
+    _, err = client.ChainID(ctx)
+    assert.NoError(t, err, "methods without a limit are not limited")
+
+    time.Sleep(150 * time.Millisecond)
+    _, err = client.BlockNumber(ctx)
+    assert.NoError(t, err, "the count resets once the window ends")
+}
+
+func TestHbarSpendingPlans(t *testing.T) {
+    var relay *Relay
+    config := Config{
+        ChainId:    hedera.TestnetChainId,
+        HbarLimits: map[string]int64{hedera.TierBasic: 2 * transactionExpense, hedera.TierExtended: 3 * transactionExpense},
+    }
+    client, privateKey, from := setupWithConfig(t, func(r *Relay) string {
+        relay = r
+        return r.URL()
+    }, config)
+    relay.hbar.addresses[from] = &hbarPlan{tier: hedera.TierExtended}
+
+    for i := 0; i < 3; i++ {
+        tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+        require.NoError(t, client.SendTransaction(context.Background(), tx))
+    }
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    err := client.SendTransaction(context.Background(), tx)
+    requireHttpError(t, err, http.StatusBadRequest, hedera.HbarRateLimitedCode, "HBAR Rate limit exceeded")
+
+    resp, err := http.Get(relay.URL() + "/metrics")
+    require.NoError(t, err)
+    defer resp.Body.Close()
+    body, err := io.ReadAll(resp.Body)
+    require.NoError(t, err)
+    assert.Contains(t, string(body), fmt.Sprintf("%s %d\n", hedera.HbarRemainingMetric, DefaultHbarBudget-3*transactionExpense))
+}
+
+func TestFeeHistoryBlockCountIsCapped(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+    for i := 0; i < feeHistoryMaxResults+2; i++ {
+        tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+        require.NoError(t, client.SendTransaction(context.Background(), tx))
+    }
+
+    history, err := client.FeeHistory(context.Background(), feeHistoryMaxResults+2, nil, []float64{50})
+    require.NoError(t, err)
+    assert.Len(t, history.GasUsedRatio, feeHistoryMaxResults)
+    assert.Len(t, history.BaseFee, feeHistoryMaxResults+1)
+    assert.Len(t, history.Reward, feeHistoryMaxResults)
+    for _, ratio := range history.GasUsedRatio {
+        assert.Equal(t, 0.5, ratio)
+    }
+}
+
+func TestFeeHistoryFromGenesisIsClampedToBlockOne(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(context.Background(), tx))
+
+    var history struct {
+        OldestBlock  hexutil.Uint64 `json:"oldestBlock"`
+        GasUsedRatio []float64      `json:"gasUsedRatio"`
+    }
+    require.NoError(t, client.Client().CallContext(context.Background(), &history, "eth_feeHistory", "0x5", "earliest", []float64{101, 10}))
+    assert.Equal(t, hexutil.Uint64(1), history.OldestBlock)
+    assert.Len(t, history.GasUsedRatio, 1)
+
+    var result interface{}
+    err := client.Client().CallContext(context.Background(), &result, "eth_feeHistory", "0x1", "0x10")
+    requireHttpError(t, err, http.StatusBadRequest, -32000, "Request beyond head block: requested 16, head 1")
+}
+
+func TestGetLogsBlockRange(t *testing.T) {
+    client, privateKey, from := setupWithConfig(t, (*Relay).URL, Config{ChainId: hedera.TestnetChainId, LogsBlockRangeLimit: 1})
+    for i := 0; i < 3; i++ {
+        tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+        require.NoError(t, client.SendTransaction(context.Background(), tx))
+    }
+
+    _, err := client.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(0)})
+    requireHttpError(t, err, http.StatusBadRequest, -32000, "Exceeded maximum block range: 1")
+
+    _, err = client.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(0), Addresses: []common.Address{from}})
+    assert.NoError(t, err)
+
+    _, err = client.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(2), ToBlock: big.NewInt(1)})
+    requireHttpError(t, err, http.StatusBadRequest, -39013, "")
+}
+
+func TestMirrorEntityLookup(t *testing.T) {
+    var baseUrl string
+    client, _, from := setup(t, func(r *Relay) string {
+        baseUrl = r.URL()
+        return baseUrl
+    })
+
+    res, err := http.Get(baseUrl + "/api/v1/accounts/" + from.Hex())
+    require.NoError(t, err)
+    defer res.Body.Close()
+    require.Equal(t, http.StatusOK, res.StatusCode)
+    var account struct {
+        Account    string `json:"account"`
+        EvmAddress string `json:"evm_address"`
+    }
+    require.NoError(t, json.NewDecoder(res.Body).Decode(&account))
+    assert.Equal(t, "0.0.1001", account.Account)
+    assert.Equal(t, from, common.HexToAddress(account.EvmAddress))
+
+    longZero := entity.ID{Num: 1001}.LongZeroAddress()
+    balance, err := client.BalanceAt(context.Background(), from, nil)
+    require.NoError(t, err)
+    longZeroBalance, err := client.BalanceAt(context.Background(), longZero, nil)
+    require.NoError(t, err)
+    assert.Equal(t, balance, longZeroBalance)
+
+    res, err = http.Get(baseUrl + "/api/v1/contracts/" + from.Hex())
+    require.NoError(t, err)
+    res.Body.Close()
+    assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestNewHeadsSubscription(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).WSURL)
+
+    heads := make(chan *types.Header, 1)
+    sub, err := client.SubscribeNewHead(context.Background(), heads)
+    require.NoError(t, err)
+    defer sub.Unsubscribe()
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(context.Background(), tx))
+
+    select {
+    case head := <-heads:
+        receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+        require.NoError(t, err)
+        assert.Equal(t, receipt.BlockHash, head.Hash())
+    case err := <-sub.Err():
+        t.Fatalf("subscription failed: %v", err)
+    }
+}
+
+func TestBlockFilterChanges(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+
+    var id string
+    require.NoError(t, client.Client().CallContext(context.Background(), &id, "eth_newBlockFilter"))
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(context.Background(), tx))
+    receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+    require.NoError(t, err)
+
+    var hashes []common.Hash
+    require.NoError(t, client.Client().CallContext(context.Background(), &hashes, "eth_getFilterChanges", id))
+    assert.Equal(t, []common.Hash{receipt.BlockHash}, hashes)
+
+    require.NoError(t, client.Client().CallContext(context.Background(), &hashes, "eth_getFilterChanges", id))
+    assert.Empty(t, hashes)
+}
+
+func TestFilterExpires(t *testing.T) {
+    client, _, _ := setupWithConfig(t, (*Relay).URL, Config{FilterTTL: 50 * time.Millisecond})
+
+    var id string
+    require.NoError(t, client.Client().CallContext(context.Background(), &id, "eth_newBlockFilter"))
+    time.Sleep(100 * time.Millisecond)
+
+    var hashes []common.Hash
+    err := client.Client().CallContext(context.Background(), &hashes, "eth_getFilterChanges", id)
+    requireHttpError(t, err, http.StatusBadRequest, errFilterNotFound.Code, errFilterNotFound.Message)
+}
+
+func TestTraceTransaction(t *testing.T) {
+    client, privateKey, from := setup(t, (*Relay).URL)
+
+    tx := signTransfer(t, client, privateKey, from, big.NewInt(hedera.WeibarsPerTinybar))
+    require.NoError(t, client.SendTransaction(context.Background(), tx))
+
+    var call struct {
+        Type  string         `json:"type"`
+        From  common.Address `json:"from"`
+        Value string         `json:"value"`
+        Calls []interface{}  `json:"calls"`
+    }
+    options := map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]interface{}{"onlyTopCall": true}}
+    require.NoError(t, client.Client().CallContext(context.Background(), &call, "debug_traceTransaction", tx.Hash(), options))
+    assert.Equal(t, "CALL", call.Type)
+    assert.Equal(t, from, call.From)
+    assert.Equal(t, "0x1", call.Value, "the value is in tinybars")
+    assert.Nil(t, call.Calls)
+
+    var opcodes struct {
+        Gas        uint64        `json:"gas"`
+        Failed     bool          `json:"failed"`
+        StructLogs []interface{} `json:"structLogs"`
+    }
+    require.NoError(t, client.Client().CallContext(context.Background(), &opcodes, "debug_traceTransaction", tx.Hash()))
+    assert.False(t, opcodes.Failed)
+    assert.Equal(t, uint64(21000), opcodes.Gas)
+    assert.Empty(t, opcodes.StructLogs)
+
+    var result interface{}
+    err := client.Client().CallContext(context.Background(), &result, "debug_traceTransaction", tx.Hash(), "prestateTracer")
+    requireHttpError(t, err, http.StatusBadRequest, -32602, "")
+}
@@ -0,0 +1,119 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package mockrelay
+
+import (
+    "context"
+    "encoding/json"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/rpc"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// valueRemainder is the part of a transferred value below a whole tinybar,
+// which Hedera does not move but the chain did. It is added back to the
+// sender and taken from the recipient when balances are read.
+type valueRemainder struct {
+    block  uint64
+    amount *big.Int
+}
+
+// recordValueRemainder notes the sub-tinybar part of the value of a mined
+// transaction.
+func (r *Relay) recordValueRemainder(ctx context.Context, tx *types.Transaction, from common.Address) error {
+    remainder := new(big.Int).Rem(tx.Value(), big.NewInt(hedera.WeibarsPerTinybar))
+    if remainder.Sign() == 0 {
+        return nil
+    }
+    receipt, err := r.backend.Client().TransactionReceipt(ctx, tx.Hash())
+    if err != nil {
+        return err
+    }
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return nil
+    }
+    to := receipt.ContractAddress
+    if tx.To() != nil {
+        to = *tx.To()
+    }
+
+    r.remaindersMu.Lock()
+    defer r.remaindersMu.Unlock()
+    block := receipt.BlockNumber.Uint64()
+    r.remainders[from] = append(r.remainders[from], valueRemainder{block: block, amount: remainder})
+    r.remainders[to] = append(r.remainders[to], valueRemainder{block: block, amount: new(big.Int).Neg(remainder)})
+    return nil
+}
+
+// hederaBalance returns the balance Hedera would hold for an account at a
+// block, given its balance on the chain.
+func (r *Relay) hederaBalance(ctx context.Context, address common.Address, balance *big.Int, params []json.RawMessage) (*big.Int, error) {
+    r.remaindersMu.Lock()
+    remainders := r.remainders[address]
+    r.remaindersMu.Unlock()
+    if len(remainders) == 0 {
+        return toTinybarGranularity(balance), nil
+    }
+
+    block, err := r.blockNumberParam(ctx, params, 1)
+    if err != nil {
+        return nil, err
+    }
+    adjusted := new(big.Int).Set(balance)
+    for _, remainder := range remainders {
+        if remainder.block <= block {
+            adjusted.Add(adjusted, remainder.amount)
+        }
+    }
+    return toTinybarGranularity(adjusted), nil
+}
+
+// blockNumberParam resolves the block number or hash parameter at index,
+// where a missing parameter and every tag but earliest mean the latest
+// block.
+func (r *Relay) blockNumberParam(ctx context.Context, params []json.RawMessage, index int) (uint64, error) {
+    latest, err := r.backend.Client().BlockNumber(ctx)
+    if err != nil {
+        return 0, err
+    }
+    if len(params) <= index {
+        return latest, nil
+    }
+    var blockNrOrHash rpc.BlockNumberOrHash
+    if err := json.Unmarshal(params[index], &blockNrOrHash); err != nil {
+        return 0, err
+    }
+    if hash, ok := blockNrOrHash.Hash(); ok {
+        header, err := r.backend.Client().HeaderByHash(ctx, hash)
+        if err != nil {
+            return 0, err
+        }
+        return header.Number.Uint64(), nil
+    }
+    if number, ok := blockNrOrHash.Number(); ok && number >= 0 {
+        return uint64(number), nil
+    }
+    return latest, nil
+}
@@ -0,0 +1,160 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mockrelay
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/json"
+    "net/http"
+    "sync"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/rpc"
+    "github.com/gorilla/websocket"
+)
+
+// wsConn is a single WebSocket client. Besides the regular methods it
+// supports eth_subscribe and eth_unsubscribe, whose notifications are
+// relayed from subscriptions on the simulated chain.
+type wsConn struct {
+    relay *Relay
+    conn  *websocket.Conn
+
+    writeMu sync.Mutex
+
+    mu            sync.Mutex
+    subscriptions map[string]*rpc.ClientSubscription
+}
+
+type subscriptionNotification struct {
+    JSONRPC string             `json:"jsonrpc"`
+    Method  string             `json:"method"`
+    Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+    Subscription string          `json:"subscription"`
+    Result       json.RawMessage `json:"result"`
+}
+
+func (r *Relay) serveWebsocket(w http.ResponseWriter, req *http.Request) {
+    conn, err := r.upgrader.Upgrade(w, req, nil)
+    if err != nil {
+        return
+    }
+    c := &wsConn{
+        relay:         r,
+        conn:          conn,
+        subscriptions: map[string]*rpc.ClientSubscription{},
+    }
+    defer c.close()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    for {
+        _, message, err := conn.ReadMessage()
+        if err != nil {
+            return
+        }
+        result, _ := r.handleMessage(ctx, message, c)
+        if err := c.write(result); err != nil {
+            return
+        }
+    }
+}
+
+func (c *wsConn) write(v interface{}) error {
+    c.writeMu.Lock()
+    defer c.writeMu.Unlock()
+    return c.conn.WriteJSON(v)
+}
+
+func (c *wsConn) subscribe(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+    if len(params) < 1 {
+        return nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    args := make([]interface{}, len(params))
+    for i, param := range params {
+        args[i] = param
+    }
+    notifications := make(chan json.RawMessage, 16)
+    sub, err := c.relay.client.EthSubscribe(context.Background(), notifications, args...)
+    if err != nil {
+        return nil, err
+    }
+
+    id := newSubscriptionId()
+    c.mu.Lock()
+    c.subscriptions[id] = sub
+    c.mu.Unlock()
+
+    go func() {
+        for {
+            select {
+            case result := <-notifications:
+                c.write(subscriptionNotification{
+                    JSONRPC: "2.0",
+                    Method:  "eth_subscription",
+                    Params:  subscriptionResult{Subscription: id, Result: result},
+                })
+            case <-sub.Err():
+                return
+            }
+        }
+    }()
+    return id, nil
+}
+
+func (c *wsConn) unsubscribe(params []json.RawMessage) (interface{}, error) {
+    if len(params) < 1 {
+        return nil, &Error{Code: -32602, Message: "Missing value for required parameter 0"}
+    }
+    var id string
+    if err := json.Unmarshal(params[0], &id); err != nil {
+        return nil, &Error{Code: -32602, Message: "Invalid parameter 0: " + err.Error()}
+    }
+    c.mu.Lock()
+    sub, ok := c.subscriptions[id]
+    delete(c.subscriptions, id)
+    c.mu.Unlock()
+    if !ok {
+        return false, nil
+    }
+    sub.Unsubscribe()
+    return true, nil
+}
+
+func (c *wsConn) close() {
+    c.mu.Lock()
+    for id, sub := range c.subscriptions {
+        sub.Unsubscribe()
+        delete(c.subscriptions, id)
+    }
+    c.mu.Unlock()
+    c.conn.Close()
+}
+
+func newSubscriptionId() string {
+    id := make([]byte, 16)
+    rand.Read(id)
+    return hexutil.Encode(id)
+}
@@ -0,0 +1,331 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// openrpcErrorRef is the result of methods the relay documents as
+// unsupported. Those are expected to fail with the described error.
+const openrpcErrorRef = schemaRefPrefix + "unsupportedError"
+
+type openrpcDocument struct {
+    Methods    []openrpcMethod `json:"methods"`
+    Components struct {
+        Schemas map[string]interface{} `json:"schemas"`
+    } `json:"components"`
+}
+
+type openrpcMethod struct {
+    Name   string                     `json:"name"`
+    Params []openrpcContentDescriptor `json:"params"`
+    Result map[string]interface{}     `json:"result"`
+}
+
+type openrpcContentDescriptor struct {
+    Name     string      `json:"name"`
+    Required bool        `json:"required"`
+    Schema   interface{} `json:"schema"`
+}
+
+func loadOpenRpcDocument(path string) (*openrpcDocument, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read OpenRPC document: %v", err)
+    }
+    var doc openrpcDocument
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return nil, fmt.Errorf("Failed to parse OpenRPC document: %v", err)
+    }
+    return &doc, nil
+}
+
+// loadOpenRpcParams reads the params to call methods with, as a JSON object
+// mapping method names to params arrays. They take precedence over the
+// params generated from the harness state.
+func loadOpenRpcParams(path string) (map[string][]interface{}, error) {
+    params := map[string][]interface{}{}
+    if path == "" {
+        return params, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read OpenRPC params: %v", err)
+    }
+    if err := json.Unmarshal(data, &params); err != nil {
+        return nil, fmt.Errorf("Failed to parse OpenRPC params: %v", err)
+    }
+    return params, nil
+}
+
+// registerOpenRpcCases adds a case per method declared in the document,
+// which calls the method and validates the raw result against the declared
+// result schema.
+func registerOpenRpcCases(r *runner, h *harness, doc *openrpcDocument, params map[string][]interface{}, wss bool) {
+    validator := newSchemaValidator(doc.Components.Schemas)
+    for _, method := range doc.Methods {
+        method := method
+        r.add(method.Name+" (openrpc)", func() error {
+            args, ok := params[method.Name]
+            if !ok {
+                var err error
+                args, err = h.openrpcParams(method, wss)
+                if err != nil {
+                    return err
+                }
+                defer h.uninstallOpenRpcFilter(method.Name, args)
+            }
+            return h.testOpenRpcMethod(validator, method, args)
+        })
+    }
+}
+
+func (h *harness) testOpenRpcMethod(validator *schemaValidator, method openrpcMethod, args []interface{}) error {
+    ctx := context.Background()
+    var raw json.RawMessage
+    err := h.client.Client().CallContext(ctx, &raw, method.Name, args...)
+    if ref, _ := method.Result["$ref"].(string); ref == openrpcErrorRef {
+        return checkOpenRpcError(validator, method, err)
+    }
+    if err != nil {
+        return fmt.Errorf("Failed to call %s: %v", method.Name, err)
+    }
+    switch method.Name {
+    case "eth_subscribe":
+        var id string
+        if json.Unmarshal(raw, &id) == nil {
+            h.client.Client().CallContext(ctx, nil, "eth_unsubscribe", id)
+        }
+    case "eth_newFilter", "eth_newBlockFilter":
+        var id string
+        if json.Unmarshal(raw, &id) == nil {
+            h.client.Client().CallContext(ctx, nil, "eth_uninstallFilter", id)
+        }
+    }
+
+    value, err := decodeJSON(raw)
+    if err != nil {
+        return fmt.Errorf("Failed to decode %s result: %v", method.Name, err)
+    }
+    return schemaViolationsError(validator.validate(method.Result, value, "result"))
+}
+
+func checkOpenRpcError(validator *schemaValidator, method openrpcMethod, err error) error {
+    if err == nil {
+        return fmt.Errorf("Expected %s to be unsupported, got a result", method.Name)
+    }
+    rpcErr, ok := asRpcError(err)
+    if !ok {
+        return fmt.Errorf("Failed to call %s: %v", method.Name, err)
+    }
+    value := map[string]interface{}{
+        "code":    json.Number(strconv.Itoa(rpcErr.Code)),
+        "message": rpcErr.Message,
+    }
+    return schemaViolationsError(validator.validate(method.Result, value, "error"))
+}
+
+func schemaViolationsError(violations []schemaViolation) error {
+    if len(violations) == 0 {
+        return nil
+    }
+    messages := make([]string, len(violations))
+    for i, violation := range violations {
+        fmt.Printf("Schema violation: %s\n", violation)
+        messages[i] = violation.String()
+    }
+    return fmt.Errorf("%d schema violations: %s", len(violations), strings.Join(messages, "; "))
+}
+
+// openrpcParams returns the params to call a method with, built from the
+// transactions and contract the write cases produced. Methods this does not
+// know get params generated from their declared param schemas.
+func (h *harness) openrpcParams(method openrpcMethod, wss bool) ([]interface{}, error) {
+    switch method.Name {
+    case "eth_call":
+        return []interface{}{map[string]interface{}{"from": h.fromAddress, "to": h.fromAddress, "data": "0x"}, "latest"}, nil
+    case "eth_estimateGas":
+        return []interface{}{map[string]interface{}{"from": h.fromAddress, "to": h.fromAddress, "value": hexutil.EncodeBig(big.NewInt(hedera.WeibarsPerTinybar))}}, nil
+    case "eth_feeHistory":
+        return []interface{}{"0x5", "latest", []interface{}{10, 50, 90}}, nil
+    case "eth_getBalance", "eth_getTransactionCount":
+        return []interface{}{h.fromAddress, "latest"}, nil
+    case "web3_sha3":
+        return []interface{}{"0x68656c6c6f"}, nil
+    case "eth_sendRawTransaction":
+        raw, err := h.signOpenRpcTransfer()
+        if err != nil {
+            return nil, err
+        }
+        return []interface{}{raw}, nil
+    case "eth_getBlockByHash", "eth_getBlockByNumber", "eth_getBlockTransactionCountByHash", "eth_getBlockTransactionCountByNumber",
+        "eth_getTransactionByBlockHashAndIndex", "eth_getTransactionByBlockNumberAndIndex", "eth_getTransactionByHash",
+        "eth_getUncleByBlockHashAndIndex", "eth_getUncleByBlockNumberAndIndex", "eth_getUncleCountByBlockHash",
+        "eth_getUncleCountByBlockNumber", "debug_traceTransaction":
+        if err := h.requireTransfer(); err != nil {
+            return nil, err
+        }
+        return h.openrpcTransferParams(method.Name), nil
+    case "eth_getCode", "eth_getStorageAt", "eth_getLogs", "eth_getTransactionReceipt", "eth_newFilter",
+        "eth_getFilterLogs", "eth_getFilterChanges", "eth_uninstallFilter", "eth_subscribe", "eth_unsubscribe":
+        if err := h.requireContract(); err != nil {
+            return nil, err
+        }
+        return h.openrpcContractParams(method.Name, wss)
+    }
+
+    var args []interface{}
+    for _, param := range method.Params {
+        value, ok := h.exampleParam(param.Schema)
+        if !ok {
+            if !param.Required {
+                break
+            }
+            return nil, skipf("no value for param %q, provide params with --openrpc-params", param.Name)
+        }
+        args = append(args, value)
+    }
+    return args, nil
+}
+
+// uninstallOpenRpcFilter uninstalls the filter created for the params of
+// eth_getFilterLogs or eth_getFilterChanges, so repeated runs do not leave
+// filters behind on the relay. eth_uninstallFilter removes its own.
+func (h *harness) uninstallOpenRpcFilter(method string, args []interface{}) {
+    switch method {
+    case "eth_getFilterLogs", "eth_getFilterChanges":
+        h.client.Client().CallContext(context.Background(), nil, "eth_uninstallFilter", args...)
+    }
+}
+
+// openrpcTransferParams returns params referring to the dummy transfer.
+func (h *harness) openrpcTransferParams(method string) []interface{} {
+    blockHash := h.transferReceipt.BlockHash
+    blockNumber := hexutil.EncodeBig(h.transferReceipt.BlockNumber)
+    index := hexutil.EncodeUint64(uint64(h.transferReceipt.TransactionIndex))
+    switch method {
+    case "eth_getBlockByHash":
+        return []interface{}{blockHash, false}
+    case "eth_getBlockByNumber":
+        return []interface{}{blockNumber, true}
+    case "eth_getBlockTransactionCountByHash", "eth_getUncleCountByBlockHash":
+        return []interface{}{blockHash}
+    case "eth_getBlockTransactionCountByNumber", "eth_getUncleCountByBlockNumber":
+        return []interface{}{blockNumber}
+    case "eth_getTransactionByBlockHashAndIndex", "eth_getUncleByBlockHashAndIndex":
+        return []interface{}{blockHash, index}
+    case "eth_getTransactionByBlockNumberAndIndex", "eth_getUncleByBlockNumberAndIndex":
+        return []interface{}{blockNumber, index}
+    case "debug_traceTransaction":
+        return []interface{}{h.transferTx.Hash(), map[string]interface{}{"tracer": "callTracer"}}
+    }
+    return []interface{}{h.transferTx.Hash()}
+}
+
+// openrpcContractParams returns params referring to the deployed contract.
+// Filter and subscription IDs are created on the fly.
+func (h *harness) openrpcContractParams(method string, wss bool) ([]interface{}, error) {
+    logFilter := map[string]interface{}{"address": h.contractAddress}
+    switch method {
+    case "eth_getCode":
+        return []interface{}{h.contractAddress, "latest"}, nil
+    case "eth_getStorageAt":
+        return []interface{}{h.contractAddress, "0x0", "latest"}, nil
+    case "eth_getLogs":
+        return []interface{}{map[string]interface{}{"address": h.contractAddress, "blockHash": h.contractReceipt.BlockHash}}, nil
+    case "eth_getTransactionReceipt":
+        return []interface{}{h.contractTx.Hash()}, nil
+    case "eth_newFilter":
+        return []interface{}{logFilter}, nil
+    case "eth_getFilterLogs", "eth_getFilterChanges", "eth_uninstallFilter":
+        var id string
+        if err := h.client.Client().CallContext(context.Background(), &id, "eth_newFilter", logFilter); err != nil {
+            return nil, fmt.Errorf("Failed to create filter: %v", err)
+        }
+        return []interface{}{id}, nil
+    }
+
+    if !wss {
+        return nil, skipf("%s is only available over WebSocket, run with --wss", method)
+    }
+    if method == "eth_subscribe" {
+        return []interface{}{"logs", logFilter}, nil
+    }
+    var id string
+    if err := h.client.Client().CallContext(context.Background(), &id, "eth_subscribe", "logs", logFilter); err != nil {
+        return nil, fmt.Errorf("Failed to subscribe: %v", err)
+    }
+    return []interface{}{id}, nil
+}
+
+// exampleParam generates a value for the common param schemas.
+func (h *harness) exampleParam(schema interface{}) (interface{}, bool) {
+    node, _ := schema.(map[string]interface{})
+    ref, _ := node["$ref"].(string)
+    switch strings.TrimPrefix(ref, schemaRefPrefix) {
+    case "address":
+        return h.fromAddress, true
+    case "uint":
+        return "0x0", true
+    case "bytes":
+        return "0x", true
+    case "BlockNumberOrTag", "BlockNumberOrTagOrHash":
+        return "latest", true
+    }
+    if node["type"] == "boolean" {
+        return false, true
+    }
+    return nil, false
+}
+
+func (h *harness) signOpenRpcTransfer() (hexutil.Bytes, error) {
+    ctx := context.Background()
+    nonce, err := h.client.PendingNonceAt(ctx, h.fromAddress)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    tx, err := types.SignNewTx(h.privateKey, types.NewEIP155Signer(h.chainId), &types.LegacyTx{
+        Nonce:    nonce,
+        GasPrice: gasPrice,
+        Gas:      21000,
+        To:       &h.fromAddress,
+        Value:    big.NewInt(hedera.WeibarsPerTinybar),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    return tx.MarshalBinary()
+}
@@ -0,0 +1,217 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "os"
+)
+
+// Scenario tags. Every scenario is tagged with the transports it runs over,
+// and with write when it submits transactions or read when it only reads.
+const (
+    tagHttp  = "http"
+    tagWs    = "ws"
+    tagRead  = "read"
+    tagWrite = "write"
+)
+
+var knownTags = map[string]bool{tagHttp: true, tagWs: true, tagRead: true, tagWrite: true}
+
+// scenario is a group of cases registered together.
+type scenario struct {
+    name     string
+    tags     []string
+    register func(r *runner)
+}
+
+func (s scenario) hasTag(tag string) bool {
+    for _, t := range s.tags {
+        if t == tag {
+            return true
+        }
+    }
+    return false
+}
+
+func (s scenario) hasAnyTag(tags []string) bool {
+    for _, tag := range tags {
+        if s.hasTag(tag) {
+            return true
+        }
+    }
+    return false
+}
+
+// testPlan selects the scenarios and methods a run executes and the values
+// the network must report. Empty lists select everything.
+type testPlan struct {
+    Name string `json:"name"`
+    // Scenarios lists the scenarios to run by name.
+    Scenarios []string `json:"scenarios"`
+    // Tags keeps the scenarios having any of these tags.
+    Tags []string `json:"tags"`
+    // ExcludeTags drops the scenarios having any of these tags.
+    ExcludeTags []string `json:"excludeTags"`
+    // Methods keeps only the cases checking these JSON-RPC methods.
+    Methods []string `json:"methods"`
+    // Networks maps mainnet, testnet, previewnet and local to what the
+    // relay of that network must report.
+    Networks  map[string]networkExpectations `json:"networks"`
+    Contracts contractArtifacts              `json:"contracts"`
+}
+
+type networkExpectations struct {
+    ChainId *big.Int `json:"chainId"`
+    // MinGasPrice is the lowest eth_gasPrice accepted, in weibars.
+    MinGasPrice *big.Int `json:"minGasPrice"`
+}
+
+// contractArtifacts are the paths of the compiled contracts, relative to
+// the working directory.
+type contractArtifacts struct {
+    // SampleContract is the hex bytecode of the contract the write cases
+    // deploy.
+    SampleContract string `json:"sampleContract"`
+    // Dir holds the <name>.abi and <name>.bin files of the other contracts.
+    Dir string `json:"dir"`
+}
+
+// artifacts are the contract paths in use, overridden by the plan.
+var artifacts = contractArtifacts{
+    SampleContract: "contracts/input.bin",
+    Dir:            "contracts",
+}
+
+// loadPlan reads a JSON test plan. Unknown fields are rejected so a typo
+// does not silently select everything.
+func loadPlan(path string) (*testPlan, error) {
+    file, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read test plan: %v", err)
+    }
+    decoder := json.NewDecoder(bytes.NewReader(file))
+    decoder.DisallowUnknownFields()
+    var plan testPlan
+    if err := decoder.Decode(&plan); err != nil {
+        return nil, fmt.Errorf("Failed to parse test plan: %v", err)
+    }
+    for _, tag := range append(append([]string{}, plan.Tags...), plan.ExcludeTags...) {
+        if !knownTags[tag] {
+            return nil, fmt.Errorf("Test plan has unknown tag %q", tag)
+        }
+    }
+    for network := range plan.Networks {
+        switch network {
+        case "mainnet", "testnet", "previewnet", "local":
+        default:
+            return nil, fmt.Errorf("Test plan has unknown network %q", network)
+        }
+    }
+    return &plan, nil
+}
+
+// applyArtifacts points the contract paths at the plan's artifacts.
+func (p *testPlan) applyArtifacts() {
+    if p.Contracts.SampleContract != "" {
+        artifacts.SampleContract = p.Contracts.SampleContract
+    }
+    if p.Contracts.Dir != "" {
+        artifacts.Dir = p.Contracts.Dir
+    }
+}
+
+// selectScenarios returns the scenarios the plan runs over the given
+// transport, in table order.
+func (p *testPlan) selectScenarios(scenarios []scenario, transport string) ([]scenario, error) {
+    names := map[string]bool{}
+    for _, s := range scenarios {
+        names[s.name] = true
+    }
+    listed := map[string]bool{}
+    for _, name := range p.Scenarios {
+        if !names[name] {
+            return nil, fmt.Errorf("Test plan has unknown scenario %q", name)
+        }
+        listed[name] = true
+    }
+
+    var selected []scenario
+    for _, s := range scenarios {
+        if !s.hasTag(transport) {
+            continue
+        }
+        if len(listed) > 0 && !listed[s.name] {
+            continue
+        }
+        if len(p.Tags) > 0 && !s.hasAnyTag(p.Tags) {
+            continue
+        }
+        if s.hasAnyTag(p.ExcludeTags) {
+            continue
+        }
+        selected = append(selected, s)
+    }
+    return selected, nil
+}
+
+// methodFilter returns the set of methods the plan keeps, or nil to keep
+// all of them.
+func (p *testPlan) methodFilter() map[string]bool {
+    if len(p.Methods) == 0 {
+        return nil
+    }
+    methods := map[string]bool{}
+    for _, method := range p.Methods {
+        methods[method] = true
+    }
+    return methods
+}
+
+// registerNetworkCases checks the chain ID and gas price the plan expects of
+// the network.
+func registerNetworkCases(r *runner, h *harness, expected networkExpectations) {
+    if expected.ChainId != nil {
+        r.add("eth_chainId (plan)", func() error {
+            if h.chainId.Cmp(expected.ChainId) != 0 {
+                return fmt.Errorf("Chain ID is %s, expected %s", h.chainId.String(), expected.ChainId.String())
+            }
+            return nil
+        })
+    }
+    if expected.MinGasPrice != nil {
+        r.add("eth_gasPrice (plan)", func() error {
+            gasPrice, err := h.client.SuggestGasPrice(context.Background())
+            if err != nil {
+                return fmt.Errorf("Failed to get gas price: %v", err)
+            }
+            if gasPrice.Cmp(expected.MinGasPrice) < 0 {
+                return fmt.Errorf("Gas price is %s, expected at least %s", gasPrice.String(), expected.MinGasPrice.String())
+            }
+            return nil
+        })
+    }
+}
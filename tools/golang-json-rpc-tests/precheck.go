@@ -0,0 +1,190 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const (
+    // relayMaxTransactionGas is the relay's default MAX_TRANSACTION_FEE_THRESHOLD.
+    relayMaxTransactionGas = 15_000_000
+    // relayTransactionSizeLimit is the relay's default SEND_RAW_TRANSACTION_SIZE_LIMIT.
+    relayTransactionSizeLimit = 131072
+
+    valueTooLowMessage       = "Value can't be non-zero and less than 10_000_000_000 wei which is 1 tinybar"
+    insufficientFundsMessage = "Insufficient funds for transfer"
+)
+
+// precheckCase is a transaction the relay must reject before submitting it.
+type precheckCase struct {
+    name string
+    code int
+    // alter changes the dummy transfer so that it fails the precheck, and
+    // returns the message of the expected error.
+    alter func(h *harness, txData *types.AccessListTx) (string, error)
+}
+
+var precheckCases = []precheckCase{
+    {
+        name: "nonce too low",
+        code: 32001,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            if txData.Nonce == 0 {
+                return "", skipf("account has not sent a transaction yet")
+            }
+            nonce := txData.Nonce
+            txData.Nonce--
+            return fmt.Sprintf("Nonce too low. Provided nonce: %d, current nonce: %d", txData.Nonce, nonce), nil
+        },
+    },
+    {
+        name: "nonce too high",
+        code: 32002,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            nonce := txData.Nonce
+            txData.Nonce++
+            return fmt.Sprintf("Nonce too high. Provided nonce: %d, current nonce: %d", txData.Nonce, nonce), nil
+        },
+    },
+    {
+        name: "gas price too low",
+        code: -32009,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            // Half the price is far below the tinybar the relay tolerates.
+            minGasPrice := txData.GasPrice
+            txData.GasPrice = new(big.Int).Div(minGasPrice, big.NewInt(2))
+            return fmt.Sprintf("Gas price '%s' is below configured minimum gas price '%s'", txData.GasPrice, minGasPrice), nil
+        },
+    },
+    {
+        name: "gas limit below intrinsic gas",
+        code: -32003,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            intrinsicGas := txData.Gas
+            txData.Gas--
+            return fmt.Sprintf("Transaction gas limit provided '%d' is insufficient of intrinsic gas required '%d'", txData.Gas, intrinsicGas), nil
+        },
+    },
+    {
+        name: "gas limit too high",
+        code: -32005,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            txData.Gas = relayMaxTransactionGas + 1
+            return fmt.Sprintf("Transaction gas limit '%d' exceeds max gas per sec limit '%d'", txData.Gas, relayMaxTransactionGas), nil
+        },
+    },
+    {
+        name: "wrong chain ID",
+        code: -32000,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            txData.ChainID = new(big.Int).Add(h.chainId, big.NewInt(1))
+            return fmt.Sprintf("ChainId (%s) not supported. The correct chainId is %s", hexutil.EncodeBig(txData.ChainID), hexutil.EncodeBig(h.chainId)), nil
+        },
+    },
+    {
+        name: "insufficient balance",
+        code: -32000,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            // The whole balance leaves nothing to pay for gas.
+            balance, err := h.client.BalanceAt(context.Background(), h.fromAddress, nil)
+            if err != nil {
+                return "", fmt.Errorf("Failed to get balance: %v", err)
+            }
+            txData.Value = balance
+            return insufficientFundsMessage, nil
+        },
+    },
+    {
+        name: "oversized data",
+        code: -32201,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            txData.Data = make([]byte, relayTransactionSizeLimit)
+            txData.Gas = relayMaxTransactionGas
+            signedTx, err := signDummyTransaction(txData, h.privateKey)
+            if err != nil {
+                return "", err
+            }
+            raw, err := signedTx.MarshalBinary()
+            if err != nil {
+                return "", fmt.Errorf("Failed to encode transaction: %v", err)
+            }
+            return fmt.Sprintf("Oversized data: transaction size %d, transaction limit %d", len(raw), relayTransactionSizeLimit), nil
+        },
+    },
+    {
+        name: "value below one tinybar",
+        code: -32602,
+        alter: func(h *harness, txData *types.AccessListTx) (string, error) {
+            txData.Value = big.NewInt(hedera.WeibarsPerTinybar - 1)
+            return valueTooLowMessage, nil
+        },
+    },
+}
+
+func registerPrecheckCases(r *runner, h *harness) {
+    for _, c := range precheckCases {
+        c := c
+        r.add(fmt.Sprintf("eth_sendRawTransaction (%s)", c.name), func() error {
+            return testPrecheck(h, c)
+        })
+    }
+}
+
+// testPrecheck sends the dummy transfer altered by c and expects the relay
+// to reject it with the error of the precheck.
+func testPrecheck(h *harness, c precheckCase) error {
+    ctx := context.Background()
+    nonce, err := h.client.PendingNonceAt(ctx, h.fromAddress)
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    txData := dummyTransactionData(h.fromAddress, h.chainId, nonce, gasPrice)
+    message, err := c.alter(h, txData)
+    if err != nil {
+        return err
+    }
+    signedTx, err := signDummyTransaction(txData, h.privateKey)
+    if err != nil {
+        return err
+    }
+
+    err = h.client.SendTransaction(ctx, signedTx)
+    if err == nil {
+        return fmt.Errorf("Expected transaction %s to be rejected with %d %q", signedTx.Hash().Hex(), c.code, message)
+    }
+    if err := h.expectRelayError(err, c.code, message); err != nil {
+        return err
+    }
+    fmt.Printf("%s: %d %s\n", c.name, c.code, message)
+    return nil
+}
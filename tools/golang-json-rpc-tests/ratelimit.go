@@ -0,0 +1,455 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "sync"
+    "text/tabwriter"
+    "time"
+
+    "github.com/joho/godotenv"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// Names of the relay settings read from a rate limit config.
+const (
+    defaultRateLimit  = "DEFAULT_RATE_LIMIT"
+    tier1RateLimit    = "TIER_1_RATE_LIMIT"
+    tier2RateLimit    = "TIER_2_RATE_LIMIT"
+    tier3RateLimit    = "TIER_3_RATE_LIMIT"
+    limitDuration     = "LIMIT_DURATION"
+    rateLimitDisabled = "RATE_LIMIT_DISABLED"
+)
+
+const (
+    // rateLimitProbe is how often a limited method is called to see whether
+    // it recovered.
+    rateLimitProbe = time.Second
+    // rateLimitGrace is how long after the window a method may take to
+    // recover, covering the latency of the probes.
+    rateLimitGrace = 5 * time.Second
+)
+
+// relayRateLimitDefaults are the relay's defaults, documented in
+// docs/rate-limiting.md.
+var relayRateLimitDefaults = map[string]string{
+    defaultRateLimit:  "200",
+    tier1RateLimit:    "100",
+    tier2RateLimit:    "800",
+    tier3RateLimit:    "1600",
+    limitDuration:     "60000",
+    rateLimitDisabled: "false",
+}
+
+// rateLimitTiers mirrors methodConfiguration.ts of the relay's server. Other
+// methods fall back to DEFAULT_RATE_LIMIT.
+var rateLimitTiers = map[string]string{
+    "web3_clientVersion":                      tier3RateLimit,
+    "web3_sha3":                               tier3RateLimit,
+    "net_listening":                           tier3RateLimit,
+    "net_version":                             tier3RateLimit,
+    "net_peerCount":                           tier3RateLimit,
+    "eth_blockNumber":                         tier2RateLimit,
+    "eth_call":                                tier1RateLimit,
+    "eth_coinbase":                            tier2RateLimit,
+    "eth_estimateGas":                         tier2RateLimit,
+    "eth_gasPrice":                            tier2RateLimit,
+    "eth_getBalance":                          tier2RateLimit,
+    "eth_getBlockByHash":                      tier2RateLimit,
+    "eth_getBlockByNumber":                    tier2RateLimit,
+    "eth_getBlockTransactionCountByHash":      tier2RateLimit,
+    "eth_getBlockTransactionCountByNumber":    tier2RateLimit,
+    "eth_getCode":                             tier2RateLimit,
+    "eth_chainId":                             tier2RateLimit,
+    "eth_getFilterChanges":                    tier2RateLimit,
+    "eth_getLogs":                             tier2RateLimit,
+    "eth_getStorageAt":                        tier2RateLimit,
+    "eth_getTransactionByBlockHashAndIndex":   tier2RateLimit,
+    "eth_getTransactionByBlockNumberAndIndex": tier2RateLimit,
+    "eth_getTransactionByHash":                tier2RateLimit,
+    "eth_getTransactionCount":                 tier2RateLimit,
+    "eth_getTransactionReceipt":               tier2RateLimit,
+    "eth_getUncleByBlockHashAndIndex":         tier2RateLimit,
+    "eth_getUncleByBlockNumberAndIndex":       tier2RateLimit,
+    "eth_getUncleCountByBlockHash":            tier2RateLimit,
+    "eth_getUncleCountByBlockNumber":          tier2RateLimit,
+    "eth_getWork":                             tier2RateLimit,
+    "eth_feeHistory":                          tier2RateLimit,
+    "eth_hashrate":                            tier1RateLimit,
+    "eth_maxPriorityFeePerGas":                tier1RateLimit,
+    "eth_mining":                              tier1RateLimit,
+    "eth_protocolVersion":                     tier2RateLimit,
+    "eth_sendRawTransaction":                  tier1RateLimit,
+    "eth_sendTransaction":                     tier1RateLimit,
+    "eth_sign":                                tier1RateLimit,
+    "eth_signTransaction":                     tier1RateLimit,
+    "eth_submitHashrate":                      tier1RateLimit,
+    "eth_submitWork":                          tier1RateLimit,
+    "eth_syncing":                             tier1RateLimit,
+    "eth_accounts":                            tier2RateLimit,
+    "eth_newBlockFilter":                      tier2RateLimit,
+    "eth_newPendingTransactionFilter":         tier2RateLimit,
+    "eth_newFilter":                           tier2RateLimit,
+    "eth_uninstallFilter":                     tier2RateLimit,
+    "eth_getFilterLogs":                       tier2RateLimit,
+    "debug_traceTransaction":                  tier1RateLimit,
+}
+
+// rateLimitConfig is the rate limit the relay is expected to apply to one
+// method.
+type rateLimitConfig struct {
+    method   string
+    params   json.RawMessage
+    workers  int
+    tier     string
+    limit    int
+    window   time.Duration
+    disabled bool
+}
+
+// readRelaySettings reads the given relay settings from a .env style file,
+// such as the relay's own. Settings the file leaves out keep their defaults,
+// and so does everything without a file.
+func readRelaySettings(path string, defaults map[string]string) (map[string]string, error) {
+    settings := map[string]string{}
+    for name, value := range defaults {
+        settings[name] = value
+    }
+    if path == "" {
+        return settings, nil
+    }
+    values, err := godotenv.Read(path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read relay settings: %v", err)
+    }
+    for name := range defaults {
+        if value, ok := values[name]; ok {
+            settings[name] = value
+        }
+    }
+    return settings, nil
+}
+
+// loadRateLimitConfig reads the rate limit settings of the relay and picks
+// the tier of method.
+func loadRateLimitConfig(path string, method string, params string, workers int) (rateLimitConfig, error) {
+    config := rateLimitConfig{method: method, params: json.RawMessage(params), workers: workers}
+    if workers < 1 {
+        return config, fmt.Errorf("--rate-limit-workers must be at least 1")
+    }
+    var args []json.RawMessage
+    if err := json.Unmarshal(config.params, &args); err != nil {
+        return config, fmt.Errorf("Failed to parse --rate-limit-params as a JSON array: %v", err)
+    }
+    settings, err := readRelaySettings(path, relayRateLimitDefaults)
+    if err != nil {
+        return config, err
+    }
+
+    config.tier = defaultRateLimit
+    if tier, ok := rateLimitTiers[method]; ok {
+        config.tier = tier
+    }
+    limit, err := strconv.Atoi(settings[config.tier])
+    if err != nil || limit < 1 {
+        return config, fmt.Errorf("%s must be a positive number, got %q", config.tier, settings[config.tier])
+    }
+    config.limit = limit
+    duration, err := strconv.ParseInt(settings[limitDuration], 10, 64)
+    if err != nil || duration < 1 {
+        return config, fmt.Errorf("%s must be a positive number of milliseconds, got %q", limitDuration, settings[limitDuration])
+    }
+    config.window = time.Duration(duration) * time.Millisecond
+    config.disabled, err = strconv.ParseBool(settings[rateLimitDisabled])
+    if err != nil {
+        return config, fmt.Errorf("%s must be true or false, got %q", rateLimitDisabled, settings[rateLimitDisabled])
+    }
+    return config, nil
+}
+
+// mockRateLimits is the limit a mock relay applies to match the config.
+func (c rateLimitConfig) mockRateLimits() map[string]int {
+    if c.disabled {
+        return nil
+    }
+    return map[string]int{c.method: c.limit}
+}
+
+// rateLimitResponse is the outcome of one call: the HTTP status and the
+// JSON-RPC error, if any.
+type rateLimitResponse struct {
+    status  int
+    code    int
+    message string
+}
+
+// limited reports whether the response rejects the call for the rate
+// limit, by its error code or by an HTTP status meant for it.
+func (r rateLimitResponse) limited() bool {
+    return r.code == hedera.IpRateLimitedCode || r.status == http.StatusConflict || r.status == http.StatusTooManyRequests
+}
+
+// burst is the outcome of calling a method from every worker until it was
+// limited.
+type burst struct {
+    sent     int
+    accepted int
+    limit    *rateLimitResponse
+    elapsed  time.Duration
+}
+
+// rateLimitReport holds what a rate limit run observed.
+type rateLimitReport struct {
+    // opening is the burst that exhausted the window open when the run
+    // started, measured the burst in the fresh window after recovery.
+    opening  burst
+    measured burst
+    recovery time.Duration
+}
+
+type rateLimitSession struct {
+    endpointUrl string
+    config      rateLimitConfig
+    client      *http.Client
+}
+
+// runRateLimit calls the method from a pool of workers until it is limited,
+// probes it until the window ends and it recovers, then calls it again from
+// the fresh window until it is limited once more. Only the second burst
+// counts every request of the window, so it gives the observed limit.
+// Bursts stop at twice the expected limit, so a relay without limits is not
+// called forever.
+func runRateLimit(endpointUrl string, config rateLimitConfig) (*rateLimitReport, error) {
+    s := &rateLimitSession{
+        endpointUrl: endpointUrl,
+        config:      config,
+        client:      &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: config.workers}},
+    }
+    defer s.client.CloseIdleConnections()
+    report := &rateLimitReport{}
+
+    var err error
+    report.opening, err = s.burst(2 * config.limit)
+    if err != nil {
+        return nil, err
+    }
+    if report.opening.limit == nil {
+        return report, nil
+    }
+    fmt.Printf("%s limited after %d accepted requests, waiting up to %s for it to recover\n", config.method, report.opening.accepted, config.window+rateLimitGrace)
+
+    start := time.Now()
+    deadline := start.Add(config.window + rateLimitGrace)
+    for {
+        response, err := s.call()
+        if err != nil {
+            return nil, err
+        }
+        if !response.limited() {
+            if response.code != 0 {
+                return nil, fmt.Errorf("Failed to call %s: %d %s", config.method, response.code, response.message)
+            }
+            report.recovery = time.Since(start)
+            break
+        }
+        if time.Now().After(deadline) {
+            return report, nil
+        }
+        time.Sleep(rateLimitProbe)
+    }
+
+    report.measured, err = s.burst(2 * config.limit)
+    if err != nil {
+        return nil, err
+    }
+    // The probe that recovered opened the window.
+    report.measured.sent++
+    report.measured.accepted++
+    return report, nil
+}
+
+// burst calls the method from every worker until the first limited response
+// or until max requests were sent. Requests in flight when the limit trips
+// still count once they are answered.
+func (s *rateLimitSession) burst(max int) (burst, error) {
+    var mu sync.Mutex
+    var result burst
+    var failure error
+    var wg sync.WaitGroup
+    start := time.Now()
+    for i := 0; i < s.config.workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                mu.Lock()
+                if result.limit != nil || failure != nil || result.sent >= max {
+                    mu.Unlock()
+                    return
+                }
+                result.sent++
+                mu.Unlock()
+
+                response, err := s.call()
+                mu.Lock()
+                switch {
+                case err != nil:
+                    failure = err
+                case response.limited():
+                    if result.limit == nil {
+                        result.limit = &response
+                    }
+                case response.code != 0:
+                    failure = fmt.Errorf("Failed to call %s: %d %s", s.config.method, response.code, response.message)
+                default:
+                    result.accepted++
+                }
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    result.elapsed = time.Since(start)
+    return result, failure
+}
+
+// call sends one request over plain HTTP, since go-ethereum's client does
+// not expose the status of responses it accepts.
+func (s *rateLimitSession) call() (rateLimitResponse, error) {
+    body, err := json.Marshal(map[string]interface{}{
+        "jsonrpc": "2.0",
+        "id":      1,
+        "method":  s.config.method,
+        "params":  s.config.params,
+    })
+    if err != nil {
+        return rateLimitResponse{}, err
+    }
+    resp, err := s.client.Post(s.endpointUrl, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return rateLimitResponse{}, fmt.Errorf("Failed to call %s: %v", s.config.method, err)
+    }
+    defer resp.Body.Close()
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return rateLimitResponse{}, fmt.Errorf("Failed to read response of %s: %v", s.config.method, err)
+    }
+    var decoded struct {
+        Error *struct {
+            Code    int    `json:"code"`
+            Message string `json:"message"`
+        } `json:"error"`
+    }
+    if err := json.Unmarshal(data, &decoded); err != nil {
+        return rateLimitResponse{}, fmt.Errorf("Failed to parse response of %s with HTTP status %d: %v", s.config.method, resp.StatusCode, err)
+    }
+    response := rateLimitResponse{status: resp.StatusCode}
+    if decoded.Error != nil {
+        response.code = decoded.Error.Code
+        response.message = decoded.Error.Message
+    }
+    return response, nil
+}
+
+// mismatches lists where the report differs from the config.
+func (r *rateLimitReport) mismatches(config rateLimitConfig) []string {
+    var mismatches []string
+    if config.disabled {
+        if r.opening.limit != nil {
+            mismatches = append(mismatches, fmt.Sprintf("%s was limited after %d requests with %s set", config.method, r.opening.accepted, rateLimitDisabled))
+        }
+        return mismatches
+    }
+    if r.opening.limit == nil {
+        return append(mismatches, fmt.Sprintf("%s was not limited after %d requests", config.method, r.opening.sent))
+    }
+    if r.measured.sent == 0 {
+        return append(mismatches, fmt.Sprintf("%s did not recover within %s", config.method, config.window+rateLimitGrace))
+    }
+    if r.measured.limit == nil {
+        mismatches = append(mismatches, fmt.Sprintf("%s was not limited after %d requests in a fresh window", config.method, r.measured.sent))
+    } else if r.measured.accepted != config.limit {
+        mismatches = append(mismatches, fmt.Sprintf("%s accepted %d requests per window, expected %d", config.method, r.measured.accepted, config.limit))
+    }
+    for _, limit := range []*rateLimitResponse{r.opening.limit, r.measured.limit} {
+        if limit == nil {
+            continue
+        }
+        if limit.code != hedera.IpRateLimitedCode {
+            mismatches = append(mismatches, fmt.Sprintf("Limited response has error code %d, expected %d", limit.code, hedera.IpRateLimitedCode))
+        }
+        if limit.status != http.StatusConflict {
+            mismatches = append(mismatches, fmt.Sprintf("Limited response has HTTP status %d, expected %d", limit.status, http.StatusConflict))
+        }
+        if expected := hedera.IpRateLimitedMessage(config.method); limit.message != expected {
+            mismatches = append(mismatches, fmt.Sprintf("Limited response has message %q, expected %q", limit.message, expected))
+        }
+    }
+    if r.measured.elapsed > config.window {
+        mismatches = append(mismatches, fmt.Sprintf("The burst took %s, longer than the window, so the observed limit spans two windows; use more --rate-limit-workers", r.measured.elapsed.Round(time.Millisecond)))
+    }
+    return mismatches
+}
+
+func (r *rateLimitReport) print(out io.Writer, config rateLimitConfig) {
+    fmt.Fprintf(out, "\n%s (%s), %d workers\n", config.method, config.tier, config.workers)
+    w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "\n\tOBSERVED\tEXPECTED")
+    observedLimit := "none"
+    if r.measured.limit != nil {
+        observedLimit = fmt.Sprintf("%d per window", r.measured.accepted)
+    } else if r.opening.limit == nil {
+        observedLimit = fmt.Sprintf("none in %d requests", r.opening.sent)
+    }
+    expectedLimit := fmt.Sprintf("%d per window", config.limit)
+    if config.disabled {
+        expectedLimit = fmt.Sprintf("none, %s is set", rateLimitDisabled)
+    }
+    fmt.Fprintf(w, "limit\t%s\t%s\n", observedLimit, expectedLimit)
+    if limit := r.opening.limit; limit != nil {
+        fmt.Fprintf(w, "error code\t%d\t%d\n", limit.code, hedera.IpRateLimitedCode)
+        fmt.Fprintf(w, "HTTP status\t%d\t%d\n", limit.status, http.StatusConflict)
+        fmt.Fprintf(w, "message\t%s\t%s\n", limit.message, hedera.IpRateLimitedMessage(config.method))
+        recovery := "none"
+        if r.measured.sent > 0 {
+            recovery = r.recovery.Round(time.Millisecond).String()
+        }
+        fmt.Fprintf(w, "recovered after\t%s\t%s window, probed every %s\n", recovery, config.window, rateLimitProbe)
+    }
+    w.Flush()
+
+    mismatches := r.mismatches(config)
+    if len(mismatches) == 0 {
+        fmt.Fprintln(out, "\nRate limit as expected")
+        return
+    }
+    fmt.Fprintln(out)
+    for _, mismatch := range mismatches {
+        fmt.Fprintln(out, mismatch)
+    }
+}
@@ -0,0 +1,165 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/trie"
+)
+
+// rpcReceiptsBlock holds the fields of a block its receipts are verified
+// against.
+type rpcReceiptsBlock struct {
+    Number       *hexutil.Big  `json:"number"`
+    Hash         common.Hash   `json:"hash"`
+    ReceiptsRoot common.Hash   `json:"receiptsRoot"`
+    Transactions []common.Hash `json:"transactions"`
+}
+
+// registerReceiptCases verifies the receipts of the blocks the write cases
+// were mined in, of a block with logs of every number of topics, and of the
+// given blocks.
+func registerReceiptCases(r *runner, h *harness, blocks []*big.Int) {
+    r.add("eth_getTransactionReceipt (integrity, transfer block)", func() error {
+        if err := h.requireTransfer(); err != nil {
+            return err
+        }
+        return verifyBlockReceipts(h, h.transferReceipt.BlockNumber)
+    })
+    r.add("eth_getTransactionReceipt (integrity, contract creation block)", func() error {
+        if h.contractReceipt == nil {
+            return skipf("contract was not deployed")
+        }
+        return verifyBlockReceipts(h, h.contractReceipt.BlockNumber)
+    })
+    r.add("eth_getTransactionReceipt (integrity, logs block)", func() error {
+        logs, err := loadContract("Logs")
+        if err != nil {
+            return err
+        }
+        address, _, err := logs.deploy(h)
+        if err != nil {
+            return err
+        }
+        receipt, err := logs.transact(h, address, "log4", big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4))
+        if err != nil {
+            return err
+        }
+        if len(receipt.Logs) == 0 {
+            return fmt.Errorf("Receipt of %s has no logs", receipt.TxHash.Hex())
+        }
+        return verifyBlockReceipts(h, receipt.BlockNumber)
+    })
+    for _, number := range blocks {
+        number := number
+        r.add(fmt.Sprintf("eth_getTransactionReceipt (integrity, block %s)", number.String()), func() error {
+            return verifyBlockReceipts(h, number)
+        })
+    }
+}
+
+// parseBlockNumbers parses comma-separated decimal or 0x-prefixed block
+// numbers.
+func parseBlockNumbers(list string) ([]*big.Int, error) {
+    var numbers []*big.Int
+    if list == "" {
+        return numbers, nil
+    }
+    for _, field := range strings.Split(list, ",") {
+        number, ok := new(big.Int).SetString(strings.TrimSpace(field), 0)
+        if !ok || number.Sign() < 0 {
+            return nil, fmt.Errorf("Invalid block number %q", field)
+        }
+        numbers = append(numbers, number)
+    }
+    return numbers, nil
+}
+
+// verifyBlockReceipts fetches a block and the receipt of each of its
+// transactions, then recomputes each logsBloom from the logs, checks
+// cumulativeGasUsed never decreases and rebuilds the receipts trie to
+// compare with the block's receiptsRoot. Every mismatch is reported, one per
+// line.
+func verifyBlockReceipts(h *harness, number *big.Int) error {
+    ctx := context.Background()
+    var block *rpcReceiptsBlock
+    if err := h.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(number), false); err != nil {
+        return fmt.Errorf("Failed to get block by number: %v", err)
+    }
+    if block == nil {
+        return fmt.Errorf("Block %s not found", number.String())
+    }
+
+    var diffs []string
+    receipts := make(types.Receipts, 0, len(block.Transactions))
+    for i, txHash := range block.Transactions {
+        receipt, err := h.client.TransactionReceipt(ctx, txHash)
+        if err != nil {
+            return fmt.Errorf("Failed to get transaction receipt %s: %v", txHash.Hex(), err)
+        }
+        var previous *types.Receipt
+        if i > 0 {
+            previous = receipts[i-1]
+        }
+        for _, diff := range receiptDiffs(block, uint(i), receipt, previous) {
+            diffs = append(diffs, fmt.Sprintf("receipt %d (%s): %s", i, txHash.Hex(), diff))
+        }
+        receipts = append(receipts, receipt)
+    }
+    if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptsRoot {
+        diffs = append(diffs, fmt.Sprintf("receiptsRoot is %s, rebuilt %s", block.ReceiptsRoot.Hex(), root.Hex()))
+    }
+    if len(diffs) > 0 {
+        return fmt.Errorf("Receipts of block %s do not match:\n    %s", number.String(), strings.Join(diffs, "\n    "))
+    }
+    fmt.Printf("Block %s: %d receipts, receiptsRoot %s\n", number.String(), len(receipts), block.ReceiptsRoot.Hex())
+    return nil
+}
+
+// receiptDiffs compares a receipt with the block it belongs to and with the
+// receipt before it.
+func receiptDiffs(block *rpcReceiptsBlock, index uint, receipt, previous *types.Receipt) []string {
+    var diffs []string
+    if receipt.BlockHash != block.Hash {
+        diffs = append(diffs, fmt.Sprintf("blockHash is %s, expected %s", receipt.BlockHash.Hex(), block.Hash.Hex()))
+    }
+    if receipt.TransactionIndex != index {
+        diffs = append(diffs, fmt.Sprintf("transactionIndex is %d, expected %d", receipt.TransactionIndex, index))
+    }
+    if bloom := types.BytesToBloom(types.LogsBloom(receipt.Logs)); receipt.Bloom != bloom {
+        diffs = append(diffs, fmt.Sprintf("logsBloom is %s, recomputed %s", hexutil.Encode(receipt.Bloom[:]), hexutil.Encode(bloom[:])))
+    }
+    if receipt.CumulativeGasUsed < receipt.GasUsed {
+        diffs = append(diffs, fmt.Sprintf("cumulativeGasUsed %d is below its gasUsed %d", receipt.CumulativeGasUsed, receipt.GasUsed))
+    }
+    if previous != nil && receipt.CumulativeGasUsed < previous.CumulativeGasUsed {
+        diffs = append(diffs, fmt.Sprintf("cumulativeGasUsed %d is below %d of the previous receipt", receipt.CumulativeGasUsed, previous.CumulativeGasUsed))
+    }
+    return diffs
+}
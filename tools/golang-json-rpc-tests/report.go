@@ -0,0 +1,245 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+)
+
+const reportSuiteName = "golang-json-rpc-tests"
+
+type reportTarget struct {
+    format string
+    path   string
+}
+
+// reportFlags collects the repeatable --report format=path flag.
+type reportFlags []reportTarget
+
+func (f *reportFlags) String() string {
+    var parts []string
+    for _, target := range *f {
+        parts = append(parts, target.format+"="+target.path)
+    }
+    return strings.Join(parts, ",")
+}
+
+func (f *reportFlags) Set(value string) error {
+    format, path, ok := strings.Cut(value, "=")
+    if !ok || path == "" {
+        return fmt.Errorf("expected format=path, got %q", value)
+    }
+    switch format {
+    case "junit", "json":
+    default:
+        return fmt.Errorf("unsupported report format %q, expected junit or json", format)
+    }
+    *f = append(*f, reportTarget{format: format, path: path})
+    return nil
+}
+
+// runInfo describes the run a report belongs to, so reports from different
+// relay releases can be told apart.
+type runInfo struct {
+    endpoint string
+    chainId  string
+}
+
+func writeReports(targets reportFlags, info runInfo, r *runner) error {
+    for _, target := range targets {
+        var data []byte
+        var err error
+        switch target.format {
+        case "junit":
+            data, err = junitReport(info, r)
+        case "json":
+            data, err = jsonReport(info, r)
+        }
+        if err != nil {
+            return fmt.Errorf("Failed to build %s report: %v", target.format, err)
+        }
+        if err := os.WriteFile(target.path, data, 0644); err != nil {
+            return fmt.Errorf("Failed to write %s report: %v", target.format, err)
+        }
+        fmt.Printf("Wrote %s report to %s\n", target.format, target.path)
+    }
+    return nil
+}
+
+type jsonCase struct {
+    Name       string    `json:"name"`
+    Method     string    `json:"method"`
+    Status     string    `json:"status"`
+    DurationMs int64     `json:"durationMs"`
+    Error      string    `json:"error,omitempty"`
+    Calls      []rpcCall `json:"calls"`
+}
+
+type jsonRun struct {
+    Suite     string     `json:"suite"`
+    Endpoint  string     `json:"endpoint"`
+    ChainId   string     `json:"chainId"`
+    StartedAt time.Time  `json:"startedAt"`
+    Passed    int        `json:"passed"`
+    Failed    int        `json:"failed"`
+    Skipped   int        `json:"skipped"`
+    Cases     []jsonCase `json:"cases"`
+}
+
+func jsonReport(info runInfo, r *runner) ([]byte, error) {
+    run := jsonRun{
+        Suite:     reportSuiteName,
+        Endpoint:  info.endpoint,
+        ChainId:   info.chainId,
+        StartedAt: r.startedAt.UTC(),
+        Cases:     []jsonCase{},
+    }
+    for _, result := range r.results {
+        c := jsonCase{
+            Name:       result.name,
+            Method:     result.method,
+            Status:     string(result.status),
+            DurationMs: result.duration.Milliseconds(),
+            Calls:      reportCalls(result),
+        }
+        if result.err != nil {
+            c.Error = result.err.Error()
+        }
+        switch result.status {
+        case statusPass:
+            run.Passed++
+        case statusFail:
+            run.Failed++
+        case statusSkip:
+            run.Skipped++
+        }
+        run.Cases = append(run.Cases, c)
+    }
+    return json.MarshalIndent(run, "", "  ")
+}
+
+// reportCalls returns the inputs of every call made by the case. Raw
+// responses are only kept for failed cases.
+func reportCalls(result caseResult) []rpcCall {
+    calls := make([]rpcCall, 0, len(result.calls))
+    for _, call := range result.calls {
+        if result.status != statusFail {
+            call.Response = nil
+        }
+        calls = append(calls, call)
+    }
+    return calls
+}
+
+type junitTestSuites struct {
+    XMLName xml.Name         `xml:"testsuites"`
+    Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+    Name       string          `xml:"name,attr"`
+    Tests      int             `xml:"tests,attr"`
+    Failures   int             `xml:"failures,attr"`
+    Skipped    int             `xml:"skipped,attr"`
+    Time       string          `xml:"time,attr"`
+    Timestamp  string          `xml:"timestamp,attr"`
+    Properties []junitProperty `xml:"properties>property"`
+    Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+    Name  string `xml:"name,attr"`
+    Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+    Name      string        `xml:"name,attr"`
+    ClassName string        `xml:"classname,attr"`
+    Time      string        `xml:"time,attr"`
+    Failure   *junitMessage `xml:"failure,omitempty"`
+    Skipped   *junitMessage `xml:"skipped,omitempty"`
+    SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+    Message string `xml:"message,attr"`
+    Body    string `xml:",chardata"`
+}
+
+func junitReport(info runInfo, r *runner) ([]byte, error) {
+    suite := junitTestSuite{
+        Name:      reportSuiteName,
+        Timestamp: r.startedAt.UTC().Format(time.RFC3339),
+        Properties: []junitProperty{
+            {Name: "endpoint", Value: info.endpoint},
+            {Name: "chainId", Value: info.chainId},
+        },
+    }
+    var total time.Duration
+    for _, result := range r.results {
+        total += result.duration
+        c := junitTestCase{
+            Name:      result.name,
+            ClassName: result.method,
+            Time:      junitSeconds(result.duration),
+        }
+        calls := reportCalls(result)
+        switch result.status {
+        case statusFail:
+            suite.Failures++
+            c.Failure = &junitMessage{Message: result.err.Error(), Body: formatCalls(calls, true)}
+        case statusSkip:
+            suite.Skipped++
+            c.Skipped = &junitMessage{Message: result.err.Error()}
+        default:
+            c.SystemOut = formatCalls(calls, false)
+        }
+        suite.Cases = append(suite.Cases, c)
+    }
+    suite.Tests = len(r.results)
+    suite.Time = junitSeconds(total)
+
+    data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append([]byte(xml.Header), data...), nil
+}
+
+func junitSeconds(d time.Duration) string {
+    return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func formatCalls(calls []rpcCall, withResponse bool) string {
+    var b strings.Builder
+    for _, call := range calls {
+        fmt.Fprintf(&b, "%s %s\n", call.Method, string(call.Params))
+        if withResponse && len(call.Response) > 0 {
+            fmt.Fprintf(&b, "  -> %s\n", string(call.Response))
+        }
+    }
+    return b.String()
+}
@@ -0,0 +1,167 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+type caseStatus string
+
+const (
+    statusPass caseStatus = "PASS"
+    statusFail caseStatus = "FAIL"
+    statusSkip caseStatus = "SKIP"
+)
+
+// skipError marks a case that could not run, usually because a case it
+// depends on did not produce the state it needs.
+type skipError struct {
+    reason string
+}
+
+func (e *skipError) Error() string {
+    return e.reason
+}
+
+func skipf(format string, args ...interface{}) error {
+    return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+type testCase struct {
+    name   string
+    method string
+    run    func() error
+}
+
+type caseResult struct {
+    name     string
+    method   string
+    status   caseStatus
+    err      error
+    duration time.Duration
+    calls    []rpcCall
+}
+
+// runner executes every registered case in registration order and records
+// the outcome of each one instead of aborting on the first failure.
+type runner struct {
+    cases     []testCase
+    results   []caseResult
+    capture   *rpcCapture
+    startedAt time.Time
+    // methods, when set, drops the cases of any other method.
+    methods map[string]bool
+}
+
+func newRunner(capture *rpcCapture) *runner {
+    return &runner{capture: capture}
+}
+
+// add registers a case. Case names start with the JSON-RPC method they
+// check, optionally followed by a qualifier such as "(transfer)".
+func (r *runner) add(name string, run func() error) {
+    method := name
+    if fields := strings.Fields(name); len(fields) > 0 {
+        method = fields[0]
+    }
+    if r.methods != nil && !r.methods[method] {
+        return
+    }
+    r.cases = append(r.cases, testCase{name: name, method: method, run: run})
+}
+
+func (r *runner) run() {
+    r.startedAt = time.Now()
+    for _, c := range r.cases {
+        if r.capture != nil {
+            r.capture.take()
+        }
+        fmt.Printf("=== RUN   %s\n", c.name)
+        start := time.Now()
+        err := runCase(c)
+        result := caseResult{
+            name:     c.name,
+            method:   c.method,
+            status:   statusPass,
+            err:      err,
+            duration: time.Since(start),
+        }
+        var skip *skipError
+        switch {
+        case errors.As(err, &skip):
+            result.status = statusSkip
+        case err != nil:
+            result.status = statusFail
+        }
+        if r.capture != nil {
+            result.calls = r.capture.take()
+        }
+        r.results = append(r.results, result)
+
+        if err != nil {
+            fmt.Printf("--- %s: %s (%s): %v\n", result.status, c.name, result.duration.Round(time.Millisecond), err)
+        } else {
+            fmt.Printf("--- %s: %s (%s)\n", result.status, c.name, result.duration.Round(time.Millisecond))
+        }
+    }
+}
+
+// runCase turns a panicking case into a failed one so the remaining cases
+// still get to run.
+func runCase(c testCase) (err error) {
+    defer func() {
+        if p := recover(); p != nil {
+            err = fmt.Errorf("panic: %v", p)
+        }
+    }()
+    return c.run()
+}
+
+func (r *runner) failed() bool {
+    for _, result := range r.results {
+        if result.status == statusFail {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *runner) printSummary(out io.Writer) {
+    counts := map[caseStatus]int{}
+    w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "\nSTATUS\tCASE\tDURATION\tERROR")
+    for _, result := range r.results {
+        counts[result.status]++
+        errMsg := ""
+        if result.err != nil {
+            errMsg = result.err.Error()
+        }
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.status, result.name, result.duration.Round(time.Millisecond), errMsg)
+    }
+    w.Flush()
+    fmt.Fprintf(out, "\n%d passed, %d failed, %d skipped\n", counts[statusPass], counts[statusFail], counts[statusSkip])
+}
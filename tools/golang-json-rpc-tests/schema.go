@@ -0,0 +1,309 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+const (
+    violationMissing   = "missing"
+    violationWrongType = "wrong type"
+    violationExtra     = "extra"
+    violationInvalid   = "invalid value"
+    violationNoMatch   = "no alternative matches"
+    violationAmbiguous = "several alternatives match"
+)
+
+// schemaViolation is a single difference between a JSON value and the
+// schema it was validated against.
+type schemaViolation struct {
+    path   string
+    kind   string
+    detail string
+}
+
+func (v schemaViolation) String() string {
+    if v.detail == "" {
+        return fmt.Sprintf("%s: %s", v.path, v.kind)
+    }
+    return fmt.Sprintf("%s: %s (%s)", v.path, v.kind, v.detail)
+}
+
+// schemaValidator checks JSON values against the subset of JSON Schema used
+// by docs/openrpc.json: $ref, type, required, properties,
+// additionalProperties, items, enum, pattern, allOf, anyOf and oneOf.
+//
+// Object fields that no schema declares are reported as extra, since the
+// document is meant to describe every field the relay returns.
+type schemaValidator struct {
+    schemas  map[string]interface{}
+    patterns map[string]*regexp.Regexp
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+func newSchemaValidator(schemas map[string]interface{}) *schemaValidator {
+    return &schemaValidator{schemas: schemas, patterns: map[string]*regexp.Regexp{}}
+}
+
+// decodeJSON decodes raw JSON keeping numbers as json.Number, so integers
+// can be told apart from fractions.
+func decodeJSON(raw []byte) (interface{}, error) {
+    decoder := json.NewDecoder(strings.NewReader(string(raw)))
+    decoder.UseNumber()
+    var value interface{}
+    if err := decoder.Decode(&value); err != nil {
+        return nil, err
+    }
+    return value, nil
+}
+
+func (v *schemaValidator) validate(schema interface{}, value interface{}, path string) []schemaViolation {
+    return v.validateNode(schema, value, path, true)
+}
+
+// resolve follows $ref pointers and unwraps OpenRPC content descriptors,
+// which nest the actual schema under a "schema" key.
+func (v *schemaValidator) resolve(schema interface{}) map[string]interface{} {
+    for i := 0; i < 32; i++ {
+        node, ok := schema.(map[string]interface{})
+        if !ok {
+            return nil
+        }
+        if ref, ok := node["$ref"].(string); ok {
+            schema = v.schemas[strings.TrimPrefix(ref, schemaRefPrefix)]
+            continue
+        }
+        if inner, ok := node["schema"]; ok && node["type"] == nil {
+            schema = inner
+            continue
+        }
+        return node
+    }
+    return nil
+}
+
+func (v *schemaValidator) validateNode(schema interface{}, value interface{}, path string, checkExtra bool) []schemaViolation {
+    node := v.resolve(schema)
+    if node == nil {
+        return nil
+    }
+
+    if expected, ok := node["type"].(string); ok && !hasJSONType(value, expected) {
+        return []schemaViolation{{path: path, kind: violationWrongType, detail: fmt.Sprintf("expected %s, got %s", expected, jsonType(value))}}
+    }
+
+    var violations []schemaViolation
+    if branches, ok := node["allOf"].([]interface{}); ok {
+        for _, branch := range branches {
+            violations = append(violations, v.validateNode(branch, value, path, false)...)
+        }
+    }
+    for _, keyword := range []string{"anyOf", "oneOf"} {
+        if branches, ok := node[keyword].([]interface{}); ok {
+            violations = append(violations, v.validateBranches(keyword, branches, value, path, checkExtra)...)
+            // The alternatives already reported their extra fields.
+            checkExtra = false
+        }
+    }
+
+    if enum, ok := node["enum"].([]interface{}); ok && !inEnum(enum, value) {
+        violations = append(violations, schemaViolation{path: path, kind: violationInvalid, detail: fmt.Sprintf("%v is not one of %v", value, enum)})
+    }
+
+    switch value := value.(type) {
+    case string:
+        if pattern, ok := node["pattern"].(string); ok && !v.matches(pattern, value) {
+            violations = append(violations, schemaViolation{path: path, kind: violationInvalid, detail: fmt.Sprintf("%q does not match %s", value, pattern)})
+        }
+    case []interface{}:
+        if items, ok := node["items"]; ok {
+            for i, item := range value {
+                violations = append(violations, v.validateNode(items, item, fmt.Sprintf("%s[%d]", path, i), true)...)
+            }
+        }
+    case map[string]interface{}:
+        violations = append(violations, v.validateObject(node, value, path, checkExtra)...)
+    }
+    return violations
+}
+
+func (v *schemaValidator) validateObject(node map[string]interface{}, value map[string]interface{}, path string, checkExtra bool) []schemaViolation {
+    var violations []schemaViolation
+    if required, ok := node["required"].([]interface{}); ok {
+        for _, name := range required {
+            if _, ok := value[name.(string)]; !ok {
+                violations = append(violations, schemaViolation{path: path + "." + name.(string), kind: violationMissing})
+            }
+        }
+    }
+    properties, _ := node["properties"].(map[string]interface{})
+    for _, name := range sortedKeys(value) {
+        if property, ok := properties[name]; ok {
+            violations = append(violations, v.validateNode(property, value[name], path+"."+name, true)...)
+        }
+    }
+
+    closed := false
+    if additional, ok := node["additionalProperties"].(bool); ok && !additional {
+        closed = true
+    }
+    if checkExtra || closed {
+        declared := map[string]bool{}
+        v.declaredProperties(node, declared)
+        if len(declared) == 0 && !closed {
+            // A bare object schema does not describe its fields at all.
+            return violations
+        }
+        for _, name := range sortedKeys(value) {
+            if !declared[name] {
+                violations = append(violations, schemaViolation{path: path + "." + name, kind: violationExtra})
+            }
+        }
+    }
+    return violations
+}
+
+// validateBranches validates value against every alternative of an anyOf
+// or oneOf. anyOf needs one alternative to match and oneOf exactly one.
+// When none matches, the violations of every alternative are reported
+// together, since which one the value was meant to be is not known.
+func (v *schemaValidator) validateBranches(keyword string, branches []interface{}, value interface{}, path string, checkExtra bool) []schemaViolation {
+    var matched, failures []string
+    for i, branch := range branches {
+        name := v.branchName(branch, i)
+        violations := v.validateNode(branch, value, path, checkExtra)
+        if len(violations) == 0 {
+            matched = append(matched, name)
+            continue
+        }
+        messages := make([]string, len(violations))
+        for j, violation := range violations {
+            messages[j] = violation.String()
+        }
+        failures = append(failures, fmt.Sprintf("%s: %s", name, strings.Join(messages, ", ")))
+    }
+    switch {
+    case len(matched) == 0:
+        return []schemaViolation{{path: path, kind: violationNoMatch, detail: fmt.Sprintf("%s: %s", keyword, strings.Join(failures, "; "))}}
+    case keyword == "oneOf" && len(matched) > 1:
+        return []schemaViolation{{path: path, kind: violationAmbiguous, detail: fmt.Sprintf("oneOf: %s", strings.Join(matched, ", "))}}
+    }
+    return nil
+}
+
+// branchName names an alternative by its title, the schema it refers to, or
+// its position.
+func (v *schemaValidator) branchName(branch interface{}, index int) string {
+    if node, ok := branch.(map[string]interface{}); ok {
+        if title, ok := node["title"].(string); ok {
+            return title
+        }
+        if ref, ok := node["$ref"].(string); ok {
+            return strings.TrimPrefix(ref, schemaRefPrefix)
+        }
+    }
+    return fmt.Sprintf("alternative %d", index+1)
+}
+
+// declaredProperties collects the properties an object schema declares,
+// including those declared by its allOf, anyOf and oneOf branches.
+func (v *schemaValidator) declaredProperties(schema interface{}, declared map[string]bool) {
+    node := v.resolve(schema)
+    if node == nil {
+        return
+    }
+    if properties, ok := node["properties"].(map[string]interface{}); ok {
+        for name := range properties {
+            declared[name] = true
+        }
+    }
+    for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+        if branches, ok := node[keyword].([]interface{}); ok {
+            for _, branch := range branches {
+                v.declaredProperties(branch, declared)
+            }
+        }
+    }
+}
+
+func (v *schemaValidator) matches(pattern string, value string) bool {
+    re, ok := v.patterns[pattern]
+    if !ok {
+        var err error
+        re, err = regexp.Compile(pattern)
+        if err != nil {
+            // A pattern Go cannot compile is not the relay's fault.
+            re = nil
+        }
+        v.patterns[pattern] = re
+    }
+    return re == nil || re.MatchString(value)
+}
+
+func jsonType(value interface{}) string {
+    switch value := value.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case string:
+        return "string"
+    case json.Number:
+        if strings.ContainsAny(value.String(), ".eE") {
+            return "number"
+        }
+        return "integer"
+    case []interface{}:
+        return "array"
+    case map[string]interface{}:
+        return "object"
+    }
+    return fmt.Sprintf("%T", value)
+}
+
+func hasJSONType(value interface{}, expected string) bool {
+    actual := jsonType(value)
+    return actual == expected || (expected == "number" && actual == "integer")
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+    for _, candidate := range enum {
+        if fmt.Sprint(candidate) == fmt.Sprint(value) {
+            return true
+        }
+    }
+    return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+    keys := make([]string, 0, len(m))
+    for key := range m {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,642 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "sync"
+    "time"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/gorilla/websocket"
+)
+
+const (
+    // The relay polls the mirror node for new blocks and logs, so
+    // notifications trail the transactions that cause them by a few seconds.
+    subscriptionTimeout = 30 * time.Second
+    // subscriptionGrace is how long a subscription is watched for stray
+    // notifications once a control subscription received everything.
+    subscriptionGrace = 2 * time.Second
+
+    subscriptionHeads = 3
+)
+
+// Fields every notification payload must carry. newHeads notifications are
+// full blocks on the relay and headers on Ethereum, so only the fields both
+// have are required.
+var (
+    newHeadsFields = []string{"hash", "parentHash", "number", "timestamp", "miner", "gasLimit", "gasUsed", "logsBloom",
+        "stateRoot", "transactionsRoot", "receiptsRoot"}
+    logFields = []string{"address", "topics", "data", "blockNumber", "blockHash", "transactionHash", "transactionIndex",
+        "logIndex", "removed"}
+)
+
+// subscriptionScenario subscribes to the events of a Logs contract deployed
+// for the scenario.
+type subscriptionScenario struct {
+    h           *harness
+    endpointUrl string
+
+    logs        *contract
+    logsAddress common.Address
+}
+
+func registerSubscriptionCases(r *runner, h *harness, endpointUrl string) {
+    s := &subscriptionScenario{h: h, endpointUrl: endpointUrl}
+    r.add("eth_subscribe (newHeads)", s.testNewHeads)
+    r.add("eth_subscribe (logs by address)", s.testLogsByAddress)
+    r.add("eth_subscribe (logs by topic)", s.testLogsByTopic)
+    r.add("eth_unsubscribe", s.testUnsubscribe)
+}
+
+// deployLogs deploys the Logs contract the first time a case needs it. The
+// relay only accepts log subscriptions for addresses that exist, so it is
+// deployed before subscribing.
+func (s *subscriptionScenario) deployLogs() error {
+    if s.logs != nil {
+        return nil
+    }
+    logs, err := loadContract("Logs")
+    if err != nil {
+        return err
+    }
+    address, _, err := logs.deploy(s.h)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Logs contract: %s\n", address.Hex())
+    s.logs = logs
+    s.logsAddress = address
+    return nil
+}
+
+// testNewHeads sends a few transfers and checks a head arrives for new
+// blocks in ascending order. The relay polls the latest block, so blocks may
+// be skipped, but the chain must link up wherever they are consecutive.
+func (s *subscriptionScenario) testNewHeads() error {
+    session, err := dialWebsocket(s.endpointUrl)
+    if err != nil {
+        return err
+    }
+    defer session.close()
+
+    id, err := session.subscribe("newHeads")
+    if err != nil {
+        return err
+    }
+    for i := 0; i < subscriptionHeads; i++ {
+        tx, err := testSendDummyTransaction(s.h.client, s.h.fromAddress, s.h.privateKey, s.h.chainId)
+        if err != nil {
+            return err
+        }
+        if _, err := waitForTransaction(s.h.client, tx); err != nil {
+            return err
+        }
+    }
+
+    type head struct {
+        Hash       common.Hash    `json:"hash"`
+        ParentHash common.Hash    `json:"parentHash"`
+        Number     hexutil.Uint64 `json:"number"`
+    }
+    var previous *head
+    for i := 0; i < subscriptionHeads; i++ {
+        result, err := session.next(id, subscriptionTimeout)
+        if err != nil {
+            return err
+        }
+        if err := requireFields(result, newHeadsFields); err != nil {
+            return fmt.Errorf("Head %d: %v", i, err)
+        }
+        var current head
+        if err := json.Unmarshal(result, &current); err != nil {
+            return fmt.Errorf("Failed to decode head: %v", err)
+        }
+        if previous != nil {
+            if current.Number <= previous.Number {
+                return fmt.Errorf("Head %d arrived after head %d", current.Number, previous.Number)
+            }
+            if current.Number == previous.Number+1 && current.ParentHash != previous.Hash {
+                return fmt.Errorf("Head %d has parent %s, expected %s", current.Number, current.ParentHash.Hex(), previous.Hash.Hex())
+            }
+        }
+        fmt.Printf("Head %d: %s\n", current.Number, current.Hash.Hex())
+        previous = &current
+    }
+    return session.expectUnsubscribed(id, true)
+}
+
+// testLogsByAddress emits an event of each kind from the Logs contract and
+// checks they arrive once each, in order, as they appear in the receipts.
+func (s *subscriptionScenario) testLogsByAddress() error {
+    if err := s.deployLogs(); err != nil {
+        return err
+    }
+    session, err := dialWebsocket(s.endpointUrl)
+    if err != nil {
+        return err
+    }
+    defer session.close()
+
+    id, err := session.subscribe("logs", map[string]interface{}{"address": s.logsAddress})
+    if err != nil {
+        return err
+    }
+    var expected []*types.Log
+    for _, call := range []struct {
+        method string
+        args   []interface{}
+    }{
+        {"log0", []interface{}{big.NewInt(1)}},
+        {"log1", []interface{}{big.NewInt(2)}},
+        {"log2", []interface{}{big.NewInt(3), big.NewInt(4)}},
+        {"log3", []interface{}{big.NewInt(5), big.NewInt(6), big.NewInt(7)}},
+        {"log4", []interface{}{big.NewInt(8), big.NewInt(9), big.NewInt(10), big.NewInt(11)}},
+    } {
+        receipt, err := s.logs.transact(s.h, s.logsAddress, call.method, call.args...)
+        if err != nil {
+            return err
+        }
+        expected = append(expected, receipt.Logs...)
+    }
+
+    received, err := session.receiveLogs(id, len(expected))
+    if err != nil {
+        return err
+    }
+    if err := compareLogs(received, expected); err != nil {
+        return err
+    }
+    if err := session.expectNone(id, subscriptionGrace); err != nil {
+        return err
+    }
+    fmt.Printf("Received %d logs of %s in order\n", len(received), s.logsAddress.Hex())
+    return session.expectUnsubscribed(id, true)
+}
+
+// testLogsByTopic subscribes to Log2 events with a random first argument and
+// checks only the matching event arrives. A subscription to every event of
+// the contract tells when the others were delivered.
+func (s *subscriptionScenario) testLogsByTopic() error {
+    if err := s.deployLogs(); err != nil {
+        return err
+    }
+    session, err := dialWebsocket(s.endpointUrl)
+    if err != nil {
+        return err
+    }
+    defer session.close()
+
+    marker, err := randomWord()
+    if err != nil {
+        return err
+    }
+    other, err := randomWord()
+    if err != nil {
+        return err
+    }
+    log2Topic := s.logs.abi.Events["Log2"].ID
+    id, err := session.subscribe("logs", map[string]interface{}{
+        "topics": []interface{}{log2Topic, common.BigToHash(marker)},
+    })
+    if err != nil {
+        return err
+    }
+    controlId, err := session.subscribe("logs", map[string]interface{}{"address": s.logsAddress})
+    if err != nil {
+        return err
+    }
+
+    var all []*types.Log
+    var matching []*types.Log
+    for _, call := range []struct {
+        method  string
+        args    []interface{}
+        matches bool
+    }{
+        {"log1", []interface{}{marker}, false},
+        {"log2", []interface{}{other, marker}, false},
+        {"log2", []interface{}{marker, other}, true},
+        {"log3", []interface{}{marker, other, other}, false},
+    } {
+        receipt, err := s.logs.transact(s.h, s.logsAddress, call.method, call.args...)
+        if err != nil {
+            return err
+        }
+        all = append(all, receipt.Logs...)
+        if call.matches {
+            matching = append(matching, receipt.Logs...)
+        }
+    }
+
+    if _, err := session.receiveLogs(controlId, len(all)); err != nil {
+        return err
+    }
+    received, err := session.receiveLogs(id, len(matching))
+    if err != nil {
+        return err
+    }
+    if err := compareLogs(received, matching); err != nil {
+        return err
+    }
+    if err := session.expectNone(id, subscriptionGrace); err != nil {
+        return err
+    }
+    fmt.Printf("Received only the log matching topic %s\n", common.BigToHash(marker).Hex())
+    return nil
+}
+
+// testUnsubscribe checks no more notifications arrive once a subscription
+// was cancelled, and that it cannot be cancelled twice.
+func (s *subscriptionScenario) testUnsubscribe() error {
+    if err := s.deployLogs(); err != nil {
+        return err
+    }
+    session, err := dialWebsocket(s.endpointUrl)
+    if err != nil {
+        return err
+    }
+    defer session.close()
+
+    criteria := map[string]interface{}{"address": s.logsAddress}
+    id, err := session.subscribe("logs", criteria)
+    if err != nil {
+        return err
+    }
+    controlId, err := session.subscribe("logs", criteria)
+    if err != nil {
+        return err
+    }
+    if _, err := s.logs.transact(s.h, s.logsAddress, "log1", big.NewInt(1)); err != nil {
+        return err
+    }
+    if _, err := session.receiveLogs(id, 1); err != nil {
+        return err
+    }
+    if _, err := session.receiveLogs(controlId, 1); err != nil {
+        return err
+    }
+
+    if err := session.expectUnsubscribed(id, true); err != nil {
+        return err
+    }
+    // Notifications read before the unsubscribe response were sent before
+    // the subscription was cancelled.
+    session.drain(id)
+    if _, err := s.logs.transact(s.h, s.logsAddress, "log1", big.NewInt(2)); err != nil {
+        return err
+    }
+    if _, err := session.receiveLogs(controlId, 1); err != nil {
+        return err
+    }
+    if err := session.expectNone(id, subscriptionGrace); err != nil {
+        return err
+    }
+    if err := session.expectUnsubscribed(id, false); err != nil {
+        return err
+    }
+    fmt.Printf("No notifications after unsubscribing %s\n", id)
+    return session.expectUnsubscribed(controlId, true)
+}
+
+// compareLogs checks logs arrived in the order they were emitted and match
+// the logs of the receipts.
+func compareLogs(received []types.Log, expected []*types.Log) error {
+    if len(received) != len(expected) {
+        return fmt.Errorf("Received %d logs, expected %d", len(received), len(expected))
+    }
+    for i, log := range received {
+        want := expected[i]
+        key := fmt.Sprintf("%s:%d", want.TxHash.Hex(), want.Index)
+        if log.TxHash != want.TxHash || log.Index != want.Index {
+            return fmt.Errorf("Log %d is %s:%d, expected %s", i, log.TxHash.Hex(), log.Index, key)
+        }
+        if log.Address != want.Address || log.BlockNumber != want.BlockNumber || log.BlockHash != want.BlockHash ||
+            log.TxIndex != want.TxIndex || !bytes.Equal(log.Data, want.Data) || !topicsEqual(log.Topics, want.Topics) {
+            return fmt.Errorf("Log %s does not match the receipt: got %+v, expected %+v", key, log, *want)
+        }
+        if log.Removed {
+            return fmt.Errorf("Log %s is marked as removed", key)
+        }
+    }
+    return nil
+}
+
+func topicsEqual(a, b []common.Hash) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func requireFields(raw json.RawMessage, fields []string) error {
+    var payload map[string]interface{}
+    if err := json.Unmarshal(raw, &payload); err != nil {
+        return fmt.Errorf("Payload is not an object: %s", string(raw))
+    }
+    for _, field := range fields {
+        if _, ok := payload[field]; !ok {
+            return fmt.Errorf("Payload is missing %s", field)
+        }
+    }
+    return nil
+}
+
+func randomWord() (*big.Int, error) {
+    word := make([]byte, 32)
+    if _, err := rand.Read(word); err != nil {
+        return nil, fmt.Errorf("Failed to generate random value: %v", err)
+    }
+    return new(big.Int).SetBytes(word), nil
+}
+
+// wsSession is a raw JSON-RPC WebSocket connection. Unlike rpc.Client it
+// exposes subscription IDs, the eth_unsubscribe result and the envelope of
+// every notification.
+type wsSession struct {
+    conn *websocket.Conn
+
+    writeMu sync.Mutex
+    nextId  int
+
+    mu            sync.Mutex
+    responses     map[int]chan wsResponse
+    notifications map[string]chan json.RawMessage
+    readErr       error
+    done          chan struct{}
+}
+
+type wsResponse struct {
+    Result json.RawMessage `json:"result"`
+    Error  *wsError        `json:"error"`
+}
+
+// wsError implements rpc.Error, so expectRpcError works on it.
+type wsError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *wsError) Error() string {
+    return e.Message
+}
+
+func (e *wsError) ErrorCode() int {
+    return e.Code
+}
+
+type wsMessage struct {
+    JSONRPC string          `json:"jsonrpc"`
+    Id      *int            `json:"id"`
+    Method  string          `json:"method"`
+    Params  json.RawMessage `json:"params"`
+    wsResponse
+}
+
+type wsNotificationParams struct {
+    Subscription string          `json:"subscription"`
+    Result       json.RawMessage `json:"result"`
+}
+
+func dialWebsocket(url string) (*wsSession, error) {
+    conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to connect to %s: %v", url, err)
+    }
+    s := &wsSession{
+        conn:          conn,
+        responses:     map[int]chan wsResponse{},
+        notifications: map[string]chan json.RawMessage{},
+        done:          make(chan struct{}),
+    }
+    go s.read()
+    return s, nil
+}
+
+func (s *wsSession) close() {
+    s.conn.Close()
+    <-s.done
+}
+
+// read routes responses to their callers and notifications to the queue of
+// their subscription, rejecting notifications that are not well formed.
+func (s *wsSession) read() {
+    defer close(s.done)
+    for {
+        var message wsMessage
+        if err := s.conn.ReadJSON(&message); err != nil {
+            s.fail(err)
+            return
+        }
+        if message.JSONRPC != "2.0" {
+            s.fail(fmt.Errorf("Message has jsonrpc %q, expected 2.0", message.JSONRPC))
+            return
+        }
+        if message.Id != nil {
+            s.mu.Lock()
+            ch := s.responses[*message.Id]
+            delete(s.responses, *message.Id)
+            s.mu.Unlock()
+            if ch != nil {
+                ch <- message.wsResponse
+            }
+            continue
+        }
+        if message.Method != "eth_subscription" {
+            s.fail(fmt.Errorf("Unexpected notification method %q", message.Method))
+            return
+        }
+        var params wsNotificationParams
+        if err := json.Unmarshal(message.Params, &params); err != nil || params.Subscription == "" {
+            s.fail(fmt.Errorf("Notification params are not well formed: %s", string(message.Params)))
+            return
+        }
+        s.queue(params.Subscription) <- params.Result
+    }
+}
+
+func (s *wsSession) fail(err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.readErr == nil {
+        s.readErr = err
+    }
+    for id, ch := range s.responses {
+        close(ch)
+        delete(s.responses, id)
+    }
+}
+
+func (s *wsSession) err() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.readErr == nil {
+        return fmt.Errorf("WebSocket connection closed")
+    }
+    return fmt.Errorf("WebSocket connection failed: %v", s.readErr)
+}
+
+func (s *wsSession) queue(id string) chan json.RawMessage {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    ch, ok := s.notifications[id]
+    if !ok {
+        ch = make(chan json.RawMessage, 256)
+        s.notifications[id] = ch
+    }
+    return ch
+}
+
+func (s *wsSession) call(result interface{}, method string, params ...interface{}) error {
+    if params == nil {
+        params = []interface{}{}
+    }
+    ch := make(chan wsResponse, 1)
+    s.writeMu.Lock()
+    s.nextId++
+    id := s.nextId
+    s.mu.Lock()
+    if s.readErr != nil {
+        s.mu.Unlock()
+        s.writeMu.Unlock()
+        return s.err()
+    }
+    s.responses[id] = ch
+    s.mu.Unlock()
+    err := s.conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+    s.writeMu.Unlock()
+    if err != nil {
+        return fmt.Errorf("Failed to send %s: %v", method, err)
+    }
+
+    select {
+    case response, ok := <-ch:
+        if !ok {
+            return s.err()
+        }
+        if response.Error != nil {
+            return response.Error
+        }
+        if err := json.Unmarshal(response.Result, result); err != nil {
+            return fmt.Errorf("Failed to decode %s result: %v", method, err)
+        }
+        return nil
+    case <-time.After(subscriptionTimeout):
+        return fmt.Errorf("No response to %s within %s", method, subscriptionTimeout)
+    }
+}
+
+func (s *wsSession) subscribe(params ...interface{}) (string, error) {
+    var id string
+    if err := s.call(&id, "eth_subscribe", params...); err != nil {
+        return "", fmt.Errorf("Failed to subscribe: %v", err)
+    }
+    if id == "" {
+        return "", fmt.Errorf("Subscription ID is empty")
+    }
+    fmt.Printf("Subscribed to %v: %s\n", params[0], id)
+    return id, nil
+}
+
+func (s *wsSession) expectUnsubscribed(id string, expected bool) error {
+    var unsubscribed bool
+    if err := s.call(&unsubscribed, "eth_unsubscribe", id); err != nil {
+        return fmt.Errorf("Failed to unsubscribe: %v", err)
+    }
+    if unsubscribed != expected {
+        return fmt.Errorf("eth_unsubscribe of %s returned %t, expected %t", id, unsubscribed, expected)
+    }
+    return nil
+}
+
+// next waits for the next notification of a subscription.
+func (s *wsSession) next(id string, timeout time.Duration) (json.RawMessage, error) {
+    select {
+    case result := <-s.queue(id):
+        return result, nil
+    case <-s.done:
+        return nil, s.err()
+    case <-time.After(timeout):
+        return nil, fmt.Errorf("No notification for subscription %s within %s", id, timeout)
+    }
+}
+
+// receiveLogs waits for count logs of a subscription, checking their shape
+// and that they arrive in chain order without repeats.
+func (s *wsSession) receiveLogs(id string, count int) ([]types.Log, error) {
+    var logs []types.Log
+    for len(logs) < count {
+        result, err := s.next(id, subscriptionTimeout)
+        if err != nil {
+            return nil, err
+        }
+        if err := requireFields(result, logFields); err != nil {
+            return nil, fmt.Errorf("Log %d: %v", len(logs), err)
+        }
+        var log types.Log
+        if err := json.Unmarshal(result, &log); err != nil {
+            return nil, fmt.Errorf("Failed to decode log: %v", err)
+        }
+        if len(logs) > 0 {
+            previous := logs[len(logs)-1]
+            if log.BlockNumber < previous.BlockNumber || (log.BlockNumber == previous.BlockNumber && log.Index <= previous.Index) {
+                return nil, fmt.Errorf("Log %d:%d arrived after log %d:%d", log.BlockNumber, log.Index, previous.BlockNumber, previous.Index)
+            }
+        }
+        logs = append(logs, log)
+    }
+    return logs, nil
+}
+
+// expectNone checks a subscription receives nothing for a while.
+func (s *wsSession) expectNone(id string, wait time.Duration) error {
+    select {
+    case result := <-s.queue(id):
+        return fmt.Errorf("Unexpected notification for subscription %s: %s", id, string(result))
+    case <-s.done:
+        return s.err()
+    case <-time.After(wait):
+        return nil
+    }
+}
+
+func (s *wsSession) drain(id string) {
+    ch := s.queue(id)
+    for {
+        select {
+        case <-ch:
+        default:
+            return
+        }
+    }
+}
@@ -0,0 +1,251 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// tinybarValue is a transfer value and the whole tinybars of it Hedera
+// moves, in weibars.
+type tinybarValue struct {
+    name        string
+    value       *big.Int
+    transferred *big.Int
+}
+
+func weibars(tinybars int64, extra int64) *big.Int {
+    value := new(big.Int).Mul(big.NewInt(tinybars), big.NewInt(hedera.WeibarsPerTinybar))
+    return value.Add(value, big.NewInt(extra))
+}
+
+// tinybarValues sit on and around tinybar boundaries. Hedera truncates a
+// value to whole tinybars.
+var tinybarValues = []tinybarValue{
+    {"zero", weibars(0, 0), weibars(0, 0)},
+    {"1 tinybar", weibars(1, 0), weibars(1, 0)},
+    {"1 tinybar plus 1 weibar", weibars(1, 1), weibars(1, 0)},
+    {"2 tinybars minus 1 weibar", weibars(2, -1), weibars(1, 0)},
+    {"2 tinybars", weibars(2, 0), weibars(2, 0)},
+}
+
+// subTinybarValues are non-zero values below a tinybar, which the relay
+// rejects.
+var subTinybarValues = []tinybarValue{
+    {"1 weibar", weibars(0, 1), nil},
+    {"1 tinybar minus 1 weibar", weibars(1, -1), nil},
+}
+
+// tinybarReceiverKeyOffset keeps the keys derived for tinybar receivers
+// apart from those of the load senders and spending plans.
+const tinybarReceiverKeyOffset = 1 << 40
+
+// tinybarScenario sends values to a fresh account and checks the balances
+// of both sides move by whole tinybars.
+type tinybarScenario struct {
+    h        *harness
+    receiver *common.Address
+}
+
+func registerTinybarCases(r *runner, h *harness) {
+    s := &tinybarScenario{h: h}
+    r.add("eth_sendRawTransaction (tinybar receiver)", func() error {
+        // The receiver is derived from the operator's nonce, so it is fresh
+        // on every run but the same when a recording is replayed.
+        nonce, err := h.client.PendingNonceAt(context.Background(), h.fromAddress)
+        if err != nil {
+            return fmt.Errorf("Failed to get transaction count: %v", err)
+        }
+        privateKey, err := deriveKey(h.privateKey, tinybarReceiverKeyOffset+int(nonce))
+        if err != nil {
+            return fmt.Errorf("Failed to derive key: %v", err)
+        }
+        receiver := crypto.PubkeyToAddress(privateKey.PublicKey)
+        if err := s.checkTransfer(receiver, weibars(1, 0), weibars(1, 0)); err != nil {
+            return err
+        }
+        s.receiver = &receiver
+        return nil
+    })
+    for _, v := range tinybarValues {
+        v := v
+        r.add(fmt.Sprintf("eth_getBalance (delta, %s)", v.name), func() error {
+            if s.receiver == nil {
+                return skipf("tinybar receiver was not created")
+            }
+            return s.checkTransfer(*s.receiver, v.value, v.transferred)
+        })
+    }
+    for _, v := range subTinybarValues {
+        v := v
+        r.add(fmt.Sprintf("eth_sendRawTransaction (value of %s)", v.name), func() error {
+            if s.receiver == nil {
+                return skipf("tinybar receiver was not created")
+            }
+            return s.checkRejected(*s.receiver, v.value)
+        })
+    }
+}
+
+// calculateTransactionCost is the most a transaction can be charged for
+// gas, its gas limit at its gas price.
+func calculateTransactionCost(gasLimit uint64, gasPrice *big.Int) *big.Int {
+    return new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+}
+
+func (s *tinybarScenario) send(to common.Address, value *big.Int) (*types.Transaction, error) {
+    ctx := context.Background()
+    nonce, err := s.h.client.PendingNonceAt(ctx, s.h.fromAddress)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := s.h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    txData := dummyTransactionData(to, s.h.chainId, nonce, gasPrice)
+    txData.Value = value
+    signedTx, err := signDummyTransaction(txData, s.h.privateKey)
+    if err != nil {
+        return nil, err
+    }
+    return signedTx, s.h.client.SendTransaction(ctx, signedTx)
+}
+
+// checkTransfer sends a value and compares the balances of both sides at
+// the latest block before sending with those in the block of the
+// transaction. The balances before are not read at the block before the
+// transaction: the relay answers eth_getBalance for blocks within
+// latestBlockTolerance of the head with the latest balance. The receiver
+// must gain exactly the transferred tinybars and the sender must lose them
+// plus a gas fee between the gas used and the gas limit at the effective
+// gas price.
+func (s *tinybarScenario) checkTransfer(to common.Address, value, transferred *big.Int) error {
+    senderBefore, err := s.balanceAt(s.h.fromAddress, nil)
+    if err != nil {
+        return err
+    }
+    receiverBefore, err := s.balanceAt(to, nil)
+    if err != nil {
+        return err
+    }
+    signedTx, err := s.send(to, value)
+    if err != nil {
+        return fmt.Errorf("Failed to send transaction: %v", err)
+    }
+    receipt, err := waitForTransaction(s.h.client, signedTx)
+    if err != nil {
+        return err
+    }
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return fmt.Errorf("Transaction %s failed", signedTx.Hash().Hex())
+    }
+
+    senderAfter, err := s.balanceAt(s.h.fromAddress, receipt.BlockNumber)
+    if err != nil {
+        return err
+    }
+    receiverAfter, err := s.balanceAt(to, receipt.BlockNumber)
+    if err != nil {
+        return err
+    }
+    for _, balance := range []*big.Int{senderBefore, senderAfter, receiverBefore, receiverAfter} {
+        if err := requireWholeTinybars("Balance", balance); err != nil {
+            return err
+        }
+    }
+
+    if received := new(big.Int).Sub(receiverAfter, receiverBefore); received.Cmp(transferred) != 0 {
+        return fmt.Errorf("Receiver gained %s weibars for a value of %s, expected %s", received.String(), value.String(), transferred.String())
+    }
+    fee := new(big.Int).Sub(senderBefore, senderAfter)
+    fee.Sub(fee, transferred)
+    if err := requireWholeTinybars("Gas fee", fee); err != nil {
+        return err
+    }
+    minFee := calculateTransactionCost(receipt.GasUsed, receipt.EffectiveGasPrice)
+    maxFee := calculateTransactionCost(signedTx.Gas(), signedTx.GasPrice())
+    if fee.Cmp(minFee) < 0 || fee.Cmp(maxFee) > 0 {
+        return fmt.Errorf("Sender paid %s weibars of gas, expected between %s and %s", fee.String(), minFee.String(), maxFee.String())
+    }
+    fmt.Printf("Sent %s weibars: receiver gained %s, sender paid %s of gas\n", value.String(), transferred.String(), fee.String())
+    return nil
+}
+
+// checkRejected checks a value is rejected before it is submitted and no
+// balance changes.
+func (s *tinybarScenario) checkRejected(to common.Address, value *big.Int) error {
+    ctx := context.Background()
+    senderBefore, err := s.h.client.BalanceAt(ctx, s.h.fromAddress, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    receiverBefore, err := s.h.client.BalanceAt(ctx, to, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    _, err = s.send(to, value)
+    if err == nil {
+        return fmt.Errorf("Transaction with a value of %s weibars was accepted", value.String())
+    }
+    if err := expectRpcError(err, -32602, valueTooLowMessage); err != nil {
+        return err
+    }
+    senderAfter, err := s.h.client.BalanceAt(ctx, s.h.fromAddress, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    receiverAfter, err := s.h.client.BalanceAt(ctx, to, nil)
+    if err != nil {
+        return fmt.Errorf("Failed to get balance: %v", err)
+    }
+    if senderAfter.Cmp(senderBefore) != 0 || receiverAfter.Cmp(receiverBefore) != 0 {
+        return fmt.Errorf("Balances changed after a rejected transaction: sender %s to %s, receiver %s to %s", senderBefore.String(), senderAfter.String(), receiverBefore.String(), receiverAfter.String())
+    }
+    return nil
+}
+
+// balanceAt reads a balance at a block, or at the latest block for nil.
+func (s *tinybarScenario) balanceAt(address common.Address, block *big.Int) (*big.Int, error) {
+    balance, err := s.h.client.BalanceAt(context.Background(), address, block)
+    if err != nil {
+        if block == nil {
+            return nil, fmt.Errorf("Failed to get balance of %s: %v", address.Hex(), err)
+        }
+        return nil, fmt.Errorf("Failed to get balance of %s at block %s: %v", address.Hex(), block.String(), err)
+    }
+    return balance, nil
+}
+
+func requireWholeTinybars(name string, weibars *big.Int) error {
+    if new(big.Int).Rem(weibars, big.NewInt(hedera.WeibarsPerTinybar)).Sign() != 0 {
+        return fmt.Errorf("%s of %s weibars is not a whole number of tinybars", name, weibars.String())
+    }
+    return nil
+}
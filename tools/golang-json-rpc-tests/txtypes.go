@@ -0,0 +1,254 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+// txType is a way of signing a transaction the relay accepts.
+type txType struct {
+    name string
+    // build returns the transaction data and the signer for it.
+    build func(h *harness, fees txFees, nonce uint64, to *common.Address, value *big.Int, data []byte, gas uint64) (types.TxData, types.Signer)
+}
+
+// txFees are the fees the network asks for: its gas price, and the priority
+// fee, which is always 0 on Hedera.
+type txFees struct {
+    gasPrice *big.Int
+    tip      *big.Int
+}
+
+var txTypes = []txType{
+    {
+        name: "legacy",
+        build: func(h *harness, fees txFees, nonce uint64, to *common.Address, value *big.Int, data []byte, gas uint64) (types.TxData, types.Signer) {
+            return &types.LegacyTx{Nonce: nonce, GasPrice: fees.gasPrice, Gas: gas, To: to, Value: value, Data: data},
+                types.NewEIP155Signer(h.chainId)
+        },
+    },
+    {
+        name: "legacy unprotected",
+        build: func(h *harness, fees txFees, nonce uint64, to *common.Address, value *big.Int, data []byte, gas uint64) (types.TxData, types.Signer) {
+            return &types.LegacyTx{Nonce: nonce, GasPrice: fees.gasPrice, Gas: gas, To: to, Value: value, Data: data},
+                types.HomesteadSigner{}
+        },
+    },
+    {
+        name: "EIP-2930",
+        build: func(h *harness, fees txFees, nonce uint64, to *common.Address, value *big.Int, data []byte, gas uint64) (types.TxData, types.Signer) {
+            return &types.AccessListTx{ChainID: h.chainId, Nonce: nonce, GasPrice: fees.gasPrice, Gas: gas, To: to, Value: value, Data: data},
+                types.NewEIP2930Signer(h.chainId)
+        },
+    },
+    {
+        name: "EIP-1559",
+        build: func(h *harness, fees txFees, nonce uint64, to *common.Address, value *big.Int, data []byte, gas uint64) (types.TxData, types.Signer) {
+            return &types.DynamicFeeTx{ChainID: h.chainId, Nonce: nonce, GasTipCap: fees.tip, GasFeeCap: fees.gasPrice, Gas: gas, To: to, Value: value, Data: data},
+                types.NewLondonSigner(h.chainId)
+        },
+    },
+}
+
+// rpcSignedTransaction holds the fields of eth_getTransactionByHash that
+// must come back exactly as they were signed.
+type rpcSignedTransaction struct {
+    Type                 hexutil.Uint64  `json:"type"`
+    ChainId              *hexutil.Big    `json:"chainId"`
+    GasPrice             *hexutil.Big    `json:"gasPrice"`
+    MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+    MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+    V                    *hexutil.Big    `json:"v"`
+    R                    *hexutil.Big    `json:"r"`
+    S                    *hexutil.Big    `json:"s"`
+    YParity              *hexutil.Uint64 `json:"yParity"`
+}
+
+// registerTxTypeCases adds a transfer and a deployment for every transaction
+// type.
+func registerTxTypeCases(r *runner, h *harness) {
+    for _, t := range txTypes {
+        t := t
+        r.add(fmt.Sprintf("eth_sendRawTransaction (%s, transfer)", t.name), func() error {
+            return testTxType(h, t, &h.fromAddress, big.NewInt(hedera.WeibarsPerTinybar), nil, 21000)
+        })
+        r.add(fmt.Sprintf("eth_sendRawTransaction (%s, contract creation)", t.name), func() error {
+            bytecode, err := sampleContractBytecode()
+            if err != nil {
+                return err
+            }
+            return testTxType(h, t, nil, big.NewInt(0), bytecode, contractDeployGas)
+        })
+    }
+}
+
+func testTxType(h *harness, t txType, to *common.Address, value *big.Int, data []byte, gas uint64) error {
+    ctx := context.Background()
+    nonce, err := h.client.PendingNonceAt(ctx, h.fromAddress)
+    if err != nil {
+        return fmt.Errorf("Failed to get transaction count: %v", err)
+    }
+    gasPrice, err := h.client.SuggestGasPrice(ctx)
+    if err != nil {
+        return fmt.Errorf("Failed to get gas price: %v", err)
+    }
+    tip, err := h.client.SuggestGasTipCap(ctx)
+    if err != nil {
+        return fmt.Errorf("Failed to get max priority fee per gas: %v", err)
+    }
+    txData, signer := t.build(h, txFees{gasPrice: gasPrice, tip: tip}, nonce, to, value, data, gas)
+    signedTx, err := types.SignNewTx(h.privateKey, signer, txData)
+    if err != nil {
+        return fmt.Errorf("Failed to sign transaction: %v", err)
+    }
+    if err := h.client.SendTransaction(ctx, signedTx); err != nil {
+        return fmt.Errorf("Failed to send transaction: %v", err)
+    }
+    receipt, err := waitForTransaction(h.client, signedTx)
+    if err != nil {
+        return err
+    }
+
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        return fmt.Errorf("Transaction %s failed", signedTx.Hash().Hex())
+    }
+    if receipt.Type != signedTx.Type() {
+        return fmt.Errorf("Receipt type is %d, expected %d", receipt.Type, signedTx.Type())
+    }
+    if to == nil {
+        if expected := crypto.CreateAddress(h.fromAddress, nonce); receipt.ContractAddress != expected {
+            return fmt.Errorf("Receipt contract address is %s, expected %s", receipt.ContractAddress.Hex(), expected.Hex())
+        }
+    }
+    if err := checkEffectiveGasPrice(h, signedTx, receipt); err != nil {
+        return err
+    }
+    if err := checkSignedFields(h, signedTx); err != nil {
+        return err
+    }
+    fmt.Printf("%s transaction %s: type %d, effective gas price %s\n", t.name, signedTx.Hash().Hex(), receipt.Type, receipt.EffectiveGasPrice.String())
+    return nil
+}
+
+// checkEffectiveGasPrice checks the receipt charges the gas price for legacy
+// and EIP-2930 transactions, and the base fee of the block plus the tip,
+// capped by the fee cap, for EIP-1559 transactions.
+func checkEffectiveGasPrice(h *harness, tx *types.Transaction, receipt *types.Receipt) error {
+    if receipt.EffectiveGasPrice == nil {
+        return fmt.Errorf("Receipt has no effective gas price")
+    }
+    expected := tx.GasPrice()
+    if tx.Type() == types.DynamicFeeTxType {
+        header, err := h.client.HeaderByHash(context.Background(), receipt.BlockHash)
+        if err != nil {
+            return fmt.Errorf("Failed to get block by hash: %v", err)
+        }
+        if header.BaseFee == nil {
+            return fmt.Errorf("Block %s has no base fee", receipt.BlockHash.Hex())
+        }
+        expected = new(big.Int).Add(header.BaseFee, tx.GasTipCap())
+        if expected.Cmp(tx.GasFeeCap()) > 0 {
+            expected = tx.GasFeeCap()
+        }
+    }
+    if receipt.EffectiveGasPrice.Cmp(expected) != 0 {
+        return fmt.Errorf("Effective gas price is %s, expected %s", receipt.EffectiveGasPrice.String(), expected.String())
+    }
+    return nil
+}
+
+// checkSignedFields checks eth_getTransactionByHash returns the type, fees
+// and signature as signed. Typed transactions must report yParity equal to
+// v, while legacy v carries the chain ID unless the transaction is
+// unprotected.
+func checkSignedFields(h *harness, tx *types.Transaction) error {
+    var result rpcSignedTransaction
+    if err := h.client.Client().CallContext(context.Background(), &result, "eth_getTransactionByHash", tx.Hash()); err != nil {
+        return fmt.Errorf("Failed to get transaction by hash: %v", err)
+    }
+    if uint8(result.Type) != tx.Type() {
+        return fmt.Errorf("Transaction type is %d, expected %d", result.Type, tx.Type())
+    }
+
+    v, r, s := tx.RawSignatureValues()
+    for _, field := range []struct {
+        name     string
+        actual   *hexutil.Big
+        expected *big.Int
+    }{
+        {"v", result.V, v},
+        {"r", result.R, r},
+        {"s", result.S, s},
+    } {
+        if field.actual == nil {
+            return fmt.Errorf("Transaction %s is missing", field.name)
+        }
+        if field.actual.ToInt().Cmp(field.expected) != 0 {
+            return fmt.Errorf("Transaction %s is %s, expected %s", field.name, field.actual.String(), hexutil.EncodeBig(field.expected))
+        }
+    }
+    if tx.Type() != types.LegacyTxType {
+        if result.YParity == nil {
+            return fmt.Errorf("Transaction yParity is missing")
+        }
+        if uint64(*result.YParity) != v.Uint64() {
+            return fmt.Errorf("Transaction yParity is %d, expected %d", *result.YParity, v.Uint64())
+        }
+    }
+    if tx.Protected() {
+        if result.ChainId == nil || result.ChainId.ToInt().Cmp(h.chainId) != 0 {
+            return fmt.Errorf("Transaction chain ID is %v, expected %s", result.ChainId, h.chainId.String())
+        }
+    }
+
+    fees := []struct {
+        name     string
+        actual   *hexutil.Big
+        expected *big.Int
+    }{
+        {"gasPrice", result.GasPrice, tx.GasPrice()},
+    }
+    if tx.Type() == types.DynamicFeeTxType {
+        fees = []struct {
+            name     string
+            actual   *hexutil.Big
+            expected *big.Int
+        }{
+            {"maxFeePerGas", result.MaxFeePerGas, tx.GasFeeCap()},
+            {"maxPriorityFeePerGas", result.MaxPriorityFeePerGas, tx.GasTipCap()},
+        }
+    }
+    for _, fee := range fees {
+        if fee.actual == nil || fee.actual.ToInt().Cmp(fee.expected) != 0 {
+            return fmt.Errorf("Transaction %s is %v, expected %s", fee.name, fee.actual, fee.expected.String())
+        }
+    }
+    return nil
+}
@@ -0,0 +1,201 @@
+/*-
+ *
+ * Hedera Golang JSON RPC tests
+ *
+ * Copyright (C) 2022-2024 Hedera Hashgraph, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/rpc"
+    "hedera-json-rpc-golang-tests-project/hedera"
+)
+
+const (
+    unsupportedMethodCode    = -32601
+    unsupportedMethodMessage = "Unsupported JSON-RPC method"
+)
+
+// rejectedMethod is a method the relay deliberately does not implement,
+// since it holds no keys and does not mine.
+type rejectedMethod struct {
+    method  string
+    params  []interface{}
+    code    int
+    message string
+    // result is the fixed value expected for methods the relay answers
+    // instead of rejecting.
+    result interface{}
+}
+
+func rejectedMethods(from common.Address) []rejectedMethod {
+    zeroHash := common.Hash{}
+    tx := map[string]interface{}{"from": from, "to": from, "value": "0x2540be400"}
+    return []rejectedMethod{
+        {method: "eth_sendTransaction", params: []interface{}{tx}, code: unsupportedMethodCode, message: unsupportedMethodMessage},
+        {method: "eth_sign", params: []interface{}{from, "0xdeadbeef"}, code: unsupportedMethodCode, message: unsupportedMethodMessage},
+        {method: "eth_signTransaction", params: []interface{}{tx}, code: unsupportedMethodCode, message: unsupportedMethodMessage},
+        {method: "eth_getWork", code: unsupportedMethodCode, message: unsupportedMethodMessage},
+        // There is no proof of work to submit, so the relay answers false.
+        {method: "eth_submitWork", params: []interface{}{"0x0000000000000001", zeroHash, zeroHash}, result: false},
+        {method: "eth_submitHashrate", params: []interface{}{zeroHash, zeroHash}, code: unsupportedMethodCode, message: unsupportedMethodMessage},
+    }
+}
+
+func registerRejectedMethodCases(r *runner, h *harness) {
+    for _, c := range rejectedMethods(h.fromAddress) {
+        c := c
+        r.add(c.method+" (rejected)", func() error {
+            return testRejectedMethod(h, c)
+        })
+    }
+}
+
+func testRejectedMethod(h *harness, c rejectedMethod) error {
+    var result json.RawMessage
+    err := h.client.Client().CallContext(context.Background(), &result, c.method, c.params...)
+    if c.result != nil {
+        if err != nil {
+            return fmt.Errorf("Failed to call %s: %v", c.method, err)
+        }
+        expected, _ := json.Marshal(c.result)
+        if string(result) != string(expected) {
+            return fmt.Errorf("Expected %s to return %s, got %s", c.method, string(expected), string(result))
+        }
+        fmt.Printf("%s: %s\n", c.method, string(result))
+        return nil
+    }
+    if err == nil {
+        return fmt.Errorf("Expected %s to be rejected, got %s", c.method, string(result))
+    }
+    if err := h.expectRelayError(err, c.code, c.message); err != nil {
+        return err
+    }
+    fmt.Printf("%s: %d %s\n", c.method, c.code, c.message)
+    return nil
+}
+
+// rpcError is a JSON-RPC error as the relay answers it, together with the
+// HTTP status of the response that carried it. The status is 0 for errors
+// that came inside a successful response, such as a batch element or a
+// WebSocket message.
+type rpcError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+    status  int
+}
+
+func (e *rpcError) Error() string {
+    return e.Message
+}
+
+func (e *rpcError) ErrorCode() int {
+    return e.Code
+}
+
+// asRpcError extracts the JSON-RPC error from err. The relay answers a
+// failing single request with a non-200 status, which go-ethereum's client
+// returns as an rpc.HTTPError with the error object in its body.
+func asRpcError(err error) (*rpcError, bool) {
+    var httpErr rpc.HTTPError
+    if errors.As(err, &httpErr) {
+        var body struct {
+            Error *rpcError `json:"error"`
+        }
+        if json.Unmarshal(httpErr.Body, &body) != nil || body.Error == nil {
+            return nil, false
+        }
+        body.Error.status = httpErr.StatusCode
+        return body.Error, true
+    }
+    var rpcErr rpc.Error
+    if errors.As(err, &rpcErr) {
+        return &rpcError{Code: rpcErr.ErrorCode(), Message: rpcErr.Error()}, true
+    }
+    return nil, false
+}
+
+// expectRpcError checks that err is a JSON-RPC error with exactly the given
+// code and message.
+func expectRpcError(err error, code int, message string) error {
+    rpcErr, ok := asRpcError(err)
+    if !ok {
+        return fmt.Errorf("Expected JSON-RPC error %d %q, got %v", code, message, err)
+    }
+    if rpcErr.Code != code || rpcErr.Message != message {
+        return fmt.Errorf("Expected JSON-RPC error %d %q, got %d %q", code, message, rpcErr.Code, rpcErr.Message)
+    }
+    return nil
+}
+
+// expectRpcErrorCode checks the error code, and that the message contains
+// the given text, case-insensitively, for errors whose wording differs
+// between clients.
+func expectRpcErrorCode(err error, code int, contains string) error {
+    rpcErr, ok := asRpcError(err)
+    if !ok {
+        return fmt.Errorf("Expected JSON-RPC error %d, got %v", code, err)
+    }
+    if rpcErr.Code != code || !strings.Contains(strings.ToLower(rpcErr.Message), strings.ToLower(contains)) {
+        return fmt.Errorf("Expected JSON-RPC error %d containing %q, got %d %q", code, contains, rpcErr.Code, rpcErr.Message)
+    }
+    return nil
+}
+
+// expectRelayError checks the error of a single request like expectRpcError,
+// and that it came with the HTTP status the relay maps its code to.
+func (h *harness) expectRelayError(err error, code int, message string) error {
+    if err := expectRpcError(err, code, message); err != nil {
+        return err
+    }
+    return h.expectErrorStatus(err)
+}
+
+// expectRelayErrorCode checks the error of a single request like
+// expectRpcErrorCode, and that it came with the HTTP status the relay maps
+// its code to.
+func (h *harness) expectRelayErrorCode(err error, code int, contains string) error {
+    if err := expectRpcErrorCode(err, code, contains); err != nil {
+        return err
+    }
+    return h.expectErrorStatus(err)
+}
+
+// expectErrorStatus checks the HTTP status of a single request's error.
+// WebSocket messages have no status of their own.
+func (h *harness) expectErrorStatus(err error) error {
+    if h.ws {
+        return nil
+    }
+    rpcErr, _ := asRpcError(err)
+    status := rpcErr.status
+    if status == 0 {
+        status = http.StatusOK
+    }
+    if expected := hedera.HttpStatus(rpcErr.Code); status != expected {
+        return fmt.Errorf("Expected JSON-RPC error %d with HTTP status %d, got %d", rpcErr.Code, expected, status)
+    }
+    return nil
+}